@@ -0,0 +1,44 @@
+// Package apperr holds the errors clients are told about. Each one carries a
+// code that the GraphQL error presenter puts in extensions.code, anything
+// else is reported as an INTERNAL error without its details.
+package apperr
+
+import "fmt"
+
+type Code string
+
+const (
+	NotFound        Code = "NOT_FOUND"
+	Unauthenticated Code = "UNAUTHENTICATED"
+	Forbidden       Code = "FORBIDDEN"
+	Validation      Code = "VALIDATION"
+	Conflict        Code = "CONFLICT"
+	// Unavailable errors are worth retrying after a while
+	Unavailable Code = "UNAVAILABLE"
+	Internal    Code = "INTERNAL"
+)
+
+// Error is an error whose message is safe to show to the client. Extensions
+// are added to the ones of the GraphQL error.
+type Error struct {
+	Code       Code
+	Message    string
+	Extensions map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+var (
+	ErrUnauthenticated = New(Unauthenticated, "not logged in")
+	ErrForbidden       = New(Forbidden, "access denied")
+)
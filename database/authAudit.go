@@ -0,0 +1,48 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Auth audit events
+const (
+	AuditAccountLocked   = "account_locked"
+	AuditIpLocked        = "ip_locked"
+	AuditAccountUnlocked = "account_unlocked"
+	AuditPasswordReset   = "password_reset"
+	AuditTotpEnabled     = "totp_enabled"
+	AuditTotpDisabled    = "totp_disabled"
+	AuditRecoveryCode    = "recovery_code_used"
+	AuditInvitation      = "invitation_created"
+)
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// InsertAuthAudit records a security relevant event. The actor is the user
+// who caused it and is empty for events triggered by anonymous callers. The
+// row is tied to the account holding username at the time, if there is one.
+func InsertAuthAudit(ctx context.Context, conn *sql.DB, event string, username string, ip string, actorId string) error {
+	_, err := conn.ExecContext(ctx, `INSERT INTO auth_audit (
+		event,
+		username,
+		ip,
+		actor_id,
+		user_id
+	)
+	VALUES ($1, $2, $3, $4, (SELECT id FROM users WHERE username = $2))`,
+		event,
+		nullString(username),
+		nullString(ip),
+		nullString(actorId),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not insert auth audit: %w", err)
+	}
+
+	return nil
+}
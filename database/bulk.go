@@ -0,0 +1,108 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+// BulkResult is the outcome of one item of a bulk mutation. With an atomic
+// batch that was rolled back Player is what the item would have written.
+type BulkResult struct {
+	Player *model.Player
+	Err    error
+}
+
+// errBulkRolledBack makes withOrg roll back an atomic batch with failed items
+var errBulkRolledBack = errors.New("bulk mutation rolled back")
+
+// runBulk applies fn to every item of a batch in one transaction. Each item
+// runs in a savepoint, so a failed item is undone on its own and the rest of
+// the batch still runs and reports its own errors. An atomic batch is rolled
+// back as a whole when any item failed, otherwise the items that succeeded
+// are committed. It reports whether the transaction was committed.
+func runBulk(ctx context.Context, conn *sql.DB, orgId string, atomic bool, n int, fn func(tx *sql.Tx, i int) (*model.Player, error)) ([]BulkResult, bool, error) {
+	results := make([]BulkResult, n)
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		failed := false
+
+		for i := 0; i < n; i++ {
+			if _, err := tx.ExecContext(ctx, `SAVEPOINT bulk_item`); err != nil {
+				return fmt.Errorf("could not create savepoint: %w", err)
+			}
+
+			player, err := fn(tx, i)
+			results[i] = BulkResult{Player: player, Err: err}
+
+			if err != nil {
+				failed = true
+				if _, err := tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT bulk_item`); err != nil {
+					return fmt.Errorf("could not roll back savepoint: %w", err)
+				}
+				continue
+			}
+
+			if _, err := tx.ExecContext(ctx, `RELEASE SAVEPOINT bulk_item`); err != nil {
+				return fmt.Errorf("could not release savepoint: %w", err)
+			}
+		}
+
+		if atomic && failed {
+			return errBulkRolledBack
+		}
+
+		return nil
+	})
+
+	if errors.Is(err, errBulkRolledBack) {
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return results, true, nil
+}
+
+// CreatePlayers creates a batch of players.
+func CreatePlayers(ctx context.Context, conn *sql.DB, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error) {
+	return runBulk(ctx, conn, orgId, atomic, len(players), func(tx *sql.Tx, i int) (*model.Player, error) {
+		return createPlayerTx(ctx, tx, orgId, actorId, *players[i])
+	})
+}
+
+// UpsertPlayers creates the players of a batch that do not exist yet and
+// overwrites the ones that do, whatever their version.
+func UpsertPlayers(ctx context.Context, conn *sql.DB, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error) {
+	return runBulk(ctx, conn, orgId, atomic, len(players), func(tx *sql.Tx, i int) (*model.Player, error) {
+		player := players[i]
+
+		before, err := selectPlayerForUpdate(ctx, tx, player.Name)
+		if err != nil {
+			return nil, err
+		}
+		if before == nil {
+			return createPlayerTx(ctx, tx, orgId, actorId, *player)
+		}
+
+		return updatePlayerTx(ctx, tx, orgId, actorId, before, validate.Overwrite(*player))
+	})
+}
+
+// DeletePlayers moves a batch of players to the trash, each only if it is
+// still at its expected version.
+func DeletePlayers(ctx context.Context, conn *sql.DB, orgId string, actorId string, players []*model.InputDeletePlayer, atomic bool) ([]BulkResult, bool, error) {
+	return runBulk(ctx, conn, orgId, atomic, len(players), func(tx *sql.Tx, i int) (*model.Player, error) {
+		before, err := lockPlayer(ctx, tx, players[i].Name, players[i].ExpectedVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		return deletePlayerTx(ctx, tx, orgId, actorId, before)
+	})
+}
@@ -0,0 +1,222 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// careerStats are the stats a career high is looked for in, named like the
+// fields of Stats
+const careerStats = `(VALUES
+	('points', points, 1),
+	('threePt', threept, 2),
+	('rebounds', rebounds, 3),
+	('assists', assists, 4),
+	('steals', steals, 5),
+	('blocks', blocks, 6),
+	('turnOvers', turnovers, 7),
+	('mp', mp, 8)
+) AS s (stat, value, position)`
+
+// maxCachedCareers bounds the career cache, it is emptied once it grows past
+// this
+const maxCachedCareers = 10000
+
+type cachedCareer struct {
+	version int
+	career  *model.Career
+}
+
+// careers caches career lines by player and season type. Every change to a
+// player's stats bumps its version, so an entry for an older version is
+// simply recomputed, on this instance and on any other.
+var careers = struct {
+	sync.Mutex
+	m map[string]cachedCareer
+}{m: map[string]cachedCareer{}}
+
+// GetPlayerCareer returns the career line of a player over its stored lines
+// of one season type.
+func GetPlayerCareer(ctx context.Context, conn *sql.DB, orgId string, player *model.Player, seasonType model.SeasonType) (*model.Career, error) {
+	key := player.ID + "/" + string(seasonType)
+
+	careers.Lock()
+	cached, ok := careers.m[key]
+	careers.Unlock()
+
+	if ok && cached.version == player.Version {
+		return cached.career, nil
+	}
+
+	var career *model.Career
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		var err error
+		career, err = getCareerTx(ctx, tx, player.ID, seasonType)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	careers.Lock()
+	if len(careers.m) >= maxCachedCareers {
+		careers.m = map[string]cachedCareer{}
+	}
+	careers.m[key] = cachedCareer{version: player.Version, career: career}
+	careers.Unlock()
+
+	return career, nil
+}
+
+func getCareerTx(ctx context.Context, tx *sql.Tx, playerId string, seasonType model.SeasonType) (*model.Career, error) {
+	career := &model.Career{
+		SeasonType: seasonType,
+		Totals:     &model.StatLine{},
+		Averages:   &model.StatLine{},
+	}
+	totals := career.Totals
+	averages := career.Averages
+	var complete bool
+	var gamesPlayed int
+
+	// The stats are per game averages, so totals take every line's games
+	// played and are only known when every line has them. Averages are
+	// weighted by minutes, a line without minutes does not count towards them.
+	err := tx.QueryRowContext(ctx, `SELECT
+		count(*),
+		min(season),
+		max(season),
+		count(games_played) = count(*),
+		COALESCE(sum(games_played), 0),
+		COALESCE(sum(points * games_played), 0),
+		COALESCE(sum(threept * games_played), 0),
+		COALESCE(sum(rebounds * games_played), 0),
+		COALESCE(sum(assists * games_played), 0),
+		COALESCE(sum(steals * games_played), 0),
+		COALESCE(sum(blocks * games_played), 0),
+		COALESCE(sum(turnovers * games_played), 0),
+		COALESCE(sum(mp * games_played), 0),
+		COALESCE(sum(points * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(threept * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(rebounds * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(assists * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(steals * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(blocks * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(sum(turnovers * mp) / NULLIF(sum(mp), 0), 0),
+		COALESCE(avg(mp), 0)
+		FROM player_stats
+		WHERE player_id = $1 AND season_type = $2;`,
+		playerId,
+		seasonType,
+	).Scan(
+		&career.Seasons,
+		&career.FirstSeason,
+		&career.LastSeason,
+		&complete,
+		&gamesPlayed,
+		&totals.Points,
+		&totals.ThreePt,
+		&totals.Rebounds,
+		&totals.Assists,
+		&totals.Steals,
+		&totals.Blocks,
+		&totals.TurnOvers,
+		&totals.Mp,
+		&averages.Points,
+		&averages.ThreePt,
+		&averages.Rebounds,
+		&averages.Assists,
+		&averages.Steals,
+		&averages.Blocks,
+		&averages.TurnOvers,
+		&averages.Mp,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player career: %w", err)
+	}
+	if complete {
+		career.GamesPlayed = &gamesPlayed
+	} else {
+		career.Totals = nil
+	}
+
+	// Ties go to the latest season
+	rows, err := tx.QueryContext(ctx, `SELECT DISTINCT ON (s.position)
+		s.stat, s.value, season, season_type
+		FROM player_stats, LATERAL `+careerStats+`
+		WHERE player_id = $1 AND season_type = $2
+		ORDER BY s.position, s.value DESC, season DESC;`,
+		playerId,
+		seasonType,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get career highs: %w", err)
+	}
+
+	career.CareerHighs, err = getStatHighRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get career high rows: %w", err)
+	}
+
+	rows, err = tx.QueryContext(ctx, `SELECT DISTINCT ON (s.position)
+		s.stat, s.value, season, season_type
+		FROM player_stats, LATERAL `+careerStats+`
+		WHERE
+		player_id = $1
+		AND season = (SELECT max(season) FROM player_stats WHERE player_id = $1)
+		ORDER BY s.position, s.value DESC, season_type;`,
+		playerId,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get season highs: %w", err)
+	}
+
+	career.SeasonHighs, err = getStatHighRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get season high rows: %w", err)
+	}
+
+	return career, nil
+}
+
+func getStatHighRows(rows *sql.Rows) ([]*model.StatHigh, error) {
+	var stat string
+	var value float64
+	var season string
+	var seasonType string
+
+	highs := []*model.StatHigh{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&stat, &value, &season, &seasonType,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan stat high: %w", err)
+		}
+		highs = append(highs, &model.StatHigh{
+			Stat:       stat,
+			Value:      value,
+			Season:     season,
+			SeasonType: model.SeasonType(seasonType),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return highs, nil
+}
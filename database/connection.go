@@ -9,30 +9,32 @@ import (
 	_ "github.com/lib/pq"
 )
 
-var Db *sql.DB
-
-func InitDB() {
+// Connect opens the database and migrates it. The functions of this package
+// take the connection it returns, so tests can pass one of their own.
+func Connect() (*sql.DB, error) {
 
 	password := os.Getenv("PASSWORD")
 	if password == "" {
-		log.Fatal("PASSWORD environment variable not set")
+		return nil, fmt.Errorf("PASSWORD environment variable not set")
 	}
 
-	db, err := sql.Open("postgres", fmt.Sprintf("user=postgres password=%s host=db.xozmizrgnjdmofvmjvvs.supabase.co port=5432 dbname=postgres", password))
+	conn, err := sql.Open("postgres", fmt.Sprintf("user=postgres password=%s host=db.xozmizrgnjdmofvmjvvs.supabase.co port=5432 dbname=postgres", password))
 
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+
+	if err = conn.Ping(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("could not connect to database: %w", err)
 	}
 
 	log.Printf("connected to database")
 
-	if err = db.Ping(); err != nil {
-		log.Panic(err)
+	if err = Migrate(conn); err != nil {
+		conn.Close()
+		return nil, err
 	}
 
-	Db = db
-}
-
-func CloseDB() error {
-	return Db.Close()
+	return conn, nil
 }
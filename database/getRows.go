@@ -3,15 +3,53 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
+	"github.com/lib/pq"
 	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
 )
 
 func getRows(rows *sql.Rows, name string) (*model.Player, error) {
+	players, err := getPlayerListRows(rows)
+	if err != nil || len(players) == 0 {
+		return nil, err
+	}
+
+	return players[len(players)-1], nil
+}
+
+func getPlayerListRows(rows *sql.Rows) ([]*model.Player, error) {
+	players := []*model.Player{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		player, err := scanPlayer(rows)
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, player)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return players, nil
+}
+
+// scanPlayer scans the playerColumns of the current row.
+func scanPlayer(rows *sql.Rows) (*model.Player, error) {
+	var id string
+	var name string
 	var position string
-	var age int
-	var experience int
+	var primaryPosition *model.LineupPosition
+	var eligiblePositions pq.StringArray
+	var age sql.NullInt64
+	var experience sql.NullInt64
 	var season string
+	var seasonType string
 	var points float64
 	var threept float64
 	var rebounds float64
@@ -20,49 +58,81 @@ func getRows(rows *sql.Rows, name string) (*model.Player, error) {
 	var blocks float64
 	var turnovers float64
 	var mp float64
+	var gamesPlayed *int
+	var birthDate sql.NullTime
+	profile := &model.Profile{}
+	var version int
+	var deletedAt sql.NullTime
 
-	var player *model.Player
-
-	defer rows.Close()
-
-	for rows.Next() {
-		if err := rows.Scan(
-			&name,
-			&position,
-			&age,
-			&experience,
-			&season,
-			&points,
-			&threept,
-			&rebounds,
-			&assists,
-			&steals,
-			&blocks,
-			&turnovers,
-			&mp,
-		); err != nil {
-			return nil, fmt.Errorf("could not scan player stats: %w", err)
-		}
-		player = &model.Player{
-			Pos:        model.Position(position),
-			Name:       name,
-			Age:        age,
-			Experience: experience,
-			Stats: &model.Stats{
-				Season:    season,
-				Points:    points,
-				ThreePt:   threept,
-				Rebounds:  rebounds,
-				Assists:   assists,
-				Steals:    steals,
-				Blocks:    blocks,
-				TurnOvers: turnovers,
-				Mp:        mp},
-		}
+	if err := rows.Scan(
+		&id,
+		&name,
+		&position,
+		&primaryPosition,
+		&eligiblePositions,
+		&age,
+		&experience,
+		&season,
+		&seasonType,
+		&points,
+		&threept,
+		&rebounds,
+		&assists,
+		&steals,
+		&blocks,
+		&turnovers,
+		&mp,
+		&gamesPlayed,
+		&birthDate,
+		&profile.Height,
+		&profile.Weight,
+		&profile.College,
+		&profile.Country,
+		&profile.DraftYear,
+		&profile.DraftRound,
+		&profile.DraftPick,
+		&profile.DebutSeason,
+		&profile.JerseyNumber,
+		&profile.Handedness,
+		&version,
+		&deletedAt,
+	); err != nil {
+		return nil, fmt.Errorf("could not scan player stats: %w", err)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("rows error: %w", err)
+	player := &model.Player{
+		ID:                id,
+		Pos:               model.Position(position),
+		PrimaryPosition:   primaryPosition,
+		EligiblePositions: make([]model.LineupPosition, len(eligiblePositions)),
+		Name:              name,
+		Age:               int(age.Int64),
+		Experience:        int(experience.Int64),
+		Profile:           profile,
+		Stats: &model.Stats{
+			Season:      season,
+			SeasonType:  model.SeasonType(seasonType),
+			Points:      points,
+			ThreePt:     threept,
+			Rebounds:    rebounds,
+			Assists:     assists,
+			Steals:      steals,
+			Blocks:      blocks,
+			TurnOvers:   turnovers,
+			Mp:          mp,
+			GamesPlayed: gamesPlayed},
+		Version: version,
+	}
+	for i, eligible := range eligiblePositions {
+		player.EligiblePositions[i] = model.LineupPosition(eligible)
+	}
+	if birthDate.Valid {
+		formatted := birthDate.Time.Format(validate.DateLayout)
+		profile.BirthDate = &formatted
+	}
+	if deletedAt.Valid {
+		formatted := deletedAt.Time.UTC().Format(time.RFC3339)
+		player.DeletedAt = &formatted
 	}
 
 	return player, nil
@@ -91,32 +161,55 @@ func getUserIdRows(rows *sql.Rows) (string, error) {
 }
 
 func getUserRows(rows *sql.Rows) (*model.User, error) {
+	users, err := getUserListRows(rows)
+	if err != nil || len(users) == 0 {
+		return nil, err
+	}
+
+	return users[len(users)-1], nil
+}
+
+func getUserListRows(rows *sql.Rows) ([]*model.User, error) {
 	var id string
 	var username string
-	var password string
+	var role string
+	var email *string
+	var totpEnabled bool
+	var createdAt time.Time
+	var lastLoginAt sql.NullTime
+	var disabled bool
 
-	var user *model.User
+	users := []*model.User{}
 
 	defer rows.Close()
 
 	for rows.Next() {
 		if err := rows.Scan(
-			&id, &username, &password,
+			&id, &username, &role, &email, &totpEnabled, &createdAt, &lastLoginAt, &disabled,
 		); err != nil {
 			return nil, fmt.Errorf("could not scan user: %w", err)
 		}
-		user = &model.User{
-			ID:       id,
-			Password: password,
-			Username: username,
+		user := &model.User{
+			ID:               id,
+			Username:         username,
+			Role:             model.Role(role),
+			Email:            email,
+			TwoFactorEnabled: &totpEnabled,
+			CreatedAt:        createdAt.UTC().Format(time.RFC3339),
+			Disabled:         &disabled,
+		}
+		if lastLoginAt.Valid {
+			formatted := lastLoginAt.Time.UTC().Format(time.RFC3339)
+			user.LastLoginAt = &formatted
 		}
+		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("rows error: %w", err)
 	}
 
-	return user, nil
+	return users, nil
 }
 
 func getUserPassword(rows *sql.Rows) (string, error) {
@@ -140,3 +233,65 @@ func getUserPassword(rows *sql.Rows) (string, error) {
 
 	return hashedPassword, nil
 }
+
+func getOrganizationRows(rows *sql.Rows) ([]*model.Organization, error) {
+	var id string
+	var name string
+	var role *string
+
+	organizations := []*model.Organization{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&id, &name, &role,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan organization: %w", err)
+		}
+		organization := &model.Organization{
+			ID:   id,
+			Name: name,
+		}
+		if role != nil {
+			orgRole := model.OrgRole(*role)
+			organization.Role = &orgRole
+		}
+		organizations = append(organizations, organization)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return organizations, nil
+}
+
+func getOrganizationMemberRows(rows *sql.Rows) ([]*model.OrganizationMember, error) {
+	var userId string
+	var username string
+	var role string
+
+	members := []*model.OrganizationMember{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := rows.Scan(
+			&userId, &username, &role,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan organization member: %w", err)
+		}
+		members = append(members, &model.OrganizationMember{
+			UserID:   userId,
+			Username: username,
+			Role:     model.OrgRole(role),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return members, nil
+}
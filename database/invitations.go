@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// ErrInvalidInvitation is returned when an invitation code is unknown,
+// expired or was used already.
+var ErrInvalidInvitation = apperr.New(apperr.Validation, "invalid or expired invitation")
+
+// ErrNoOrganization is returned when there is no organization for a new
+// user to join.
+var ErrNoOrganization = apperr.New(apperr.Validation, "there is no organization to join")
+
+// CreateInvitation stores the hash of a new invitation code. The invited
+// user joins organization orgId with orgRole.
+func CreateInvitation(ctx context.Context, conn *sql.DB, codeHash string, role model.Role, orgId string, orgRole model.OrgRole, createdBy string, expiresAt time.Time) error {
+	_, err := conn.ExecContext(ctx, `INSERT INTO invitations (
+		code_hash,
+		role,
+		org_id,
+		org_role,
+		created_by,
+		expires_at
+	)
+	VALUES ($1, $2, $3, $4, $5, $6)`,
+		codeHash,
+		role,
+		orgId,
+		orgRole,
+		createdBy,
+		expiresAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not create invitation: %w", err)
+	}
+
+	return nil
+}
+
+// InvitationValid reports whether an invitation can still be used, so the
+// password is only hashed for invitations that will be accepted.
+func InvitationValid(ctx context.Context, conn *sql.DB, codeHash string) (bool, error) {
+	var valid bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM invitations
+		WHERE code_hash = $1 AND used_at IS NULL AND expires_at > now()
+	)`, codeHash).Scan(&valid)
+
+	if err != nil {
+		return false, fmt.Errorf("could not get invitation: %w", err)
+	}
+
+	return valid, nil
+}
@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// AccountLockThreshold failures for one username from one address lock
+	// that account for the address
+	AccountLockThreshold = 10
+	// IpLockThreshold failures from one address lock that address
+	IpLockThreshold = 50
+	// FailureWindow is how long a failure counts against a key
+	FailureWindow = 15 * time.Minute
+	// LockoutDuration is how long a locked account or address stays locked
+	LockoutDuration = 15 * time.Minute
+	// AttemptTimeout is how long a reserved attempt counts against a key
+	// when its outcome is never recorded
+	AttemptTimeout = time.Minute
+	// maxLoginDelay caps the progressive delay between attempts
+	maxLoginDelay = 30 * time.Second
+)
+
+// LoginThrottledError is returned when an account or address has to wait
+// before it may try to log in again.
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *LoginThrottledError) Error() string {
+	retry := e.RetryAfter.Round(time.Second)
+	if retry < time.Second {
+		retry = time.Second
+	}
+
+	if e.Locked {
+		return fmt.Sprintf("account temporarily locked, try again in %s", retry)
+	}
+	return fmt.Sprintf("too many failed login attempts, try again in %s", retry)
+}
+
+func accountKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+// pairKey counts the failures of an account from one address, so a client
+// guessing at an account can only lock it for itself.
+func pairKey(username string, ip string) string {
+	return ipKey(ip) + "|" + accountKey(username)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+// LoginDelay is how long a key must wait after its last failure. The first
+// few failures are free, after that the delay doubles with every failure.
+func LoginDelay(failures int) time.Duration {
+	if failures < 3 {
+		return 0
+	}
+	if failures-3 >= 5 {
+		return maxLoginDelay
+	}
+
+	delay := time.Second << (failures - 3)
+	if delay > maxLoginDelay {
+		return maxLoginDelay
+	}
+	return delay
+}
+
+// loginKey is a key failures are counted under and the number of failures
+// that locks it.
+type loginKey struct {
+	key       string
+	threshold int
+	event     string
+}
+
+func loginKeys(username string, ip string) []loginKey {
+	keys := []loginKey{{pairKey(username, ip), AccountLockThreshold, AuditAccountLocked}}
+	if ip != "" {
+		keys = append(keys, loginKey{ipKey(ip), IpLockThreshold, AuditIpLocked})
+	}
+	return keys
+}
+
+// ReserveLoginAttempt returns a LoginThrottledError if the account from this
+// address, or the address, is locked or still inside its progressive delay.
+// Otherwise it reserves the attempt in the same transaction as the check.
+// Attempts in flight count like failures until their outcome is recorded, or
+// for AttemptTimeout, so concurrent guesses cannot all pass the check.
+func ReserveLoginAttempt(ctx context.Context, conn *sql.DB, username string, ip string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+
+	for _, key := range loginKeys(username, ip) {
+		_, err := tx.ExecContext(ctx, `INSERT INTO login_failures (key, failures, last_failure)
+		VALUES ($1, 0, now())
+		ON CONFLICT (key) DO NOTHING`, key.key)
+
+		if err != nil {
+			return fmt.Errorf("could not reserve login attempt: %w", err)
+		}
+
+		var failures int
+		var lastFailure time.Time
+		var lockedUntil *time.Time
+		var pending int
+		var lastAttempt time.Time
+
+		err = tx.QueryRowContext(ctx, `SELECT failures, last_failure, locked_until, pending, last_attempt
+		FROM login_failures
+		WHERE key = $1
+		FOR UPDATE;`, key.key).Scan(&failures, &lastFailure, &lockedUntil, &pending, &lastAttempt)
+
+		if err != nil {
+			return fmt.Errorf("could not get login failures: %w", err)
+		}
+
+		if lockedUntil != nil && lockedUntil.After(now) {
+			return &LoginThrottledError{RetryAfter: lockedUntil.Sub(now), Locked: true}
+		}
+
+		if wait := AttemptWait(now, failures, lastFailure, pending, lastAttempt); wait > 0 {
+			return &LoginThrottledError{RetryAfter: wait}
+		}
+
+		if now.Sub(lastAttempt) > AttemptTimeout {
+			pending = 0
+		}
+
+		_, err = tx.ExecContext(ctx, `
+		UPDATE login_failures
+			SET
+			pending = $2,
+			last_attempt = now()
+			WHERE
+			key = $1`,
+			key.key,
+			pending+1,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not reserve login attempt: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not reserve login attempt: %w", err)
+	}
+
+	return nil
+}
+
+// AttemptWait is how long a key has to wait before its next attempt, counting
+// the failures inside the window and the attempts still in flight.
+func AttemptWait(now time.Time, failures int, lastFailure time.Time, pending int, lastAttempt time.Time) time.Duration {
+	last := time.Time{}
+	count := 0
+
+	if now.Sub(lastFailure) <= FailureWindow {
+		count += failures
+		last = lastFailure
+	}
+	if now.Sub(lastAttempt) <= AttemptTimeout && pending > 0 {
+		count += pending
+		if lastAttempt.After(last) {
+			last = lastAttempt
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return last.Add(LoginDelay(count)).Sub(now)
+}
+
+// RecordLoginFailure counts a failed login against the account from this
+// address and against the address, locking either one once it reaches its
+// threshold, and ends the attempt ReserveLoginAttempt reserved. Keys with
+// nothing left inside their windows are purged on the way, which keeps
+// guesses at unknown usernames from piling up.
+func RecordLoginFailure(ctx context.Context, conn *sql.DB, username string, ip string) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM login_failures
+	WHERE last_failure < now() - make_interval(secs => $1)
+	AND last_attempt < now() - make_interval(secs => $2)
+	AND (locked_until IS NULL OR locked_until < now())`,
+		FailureWindow.Seconds(),
+		AttemptTimeout.Seconds(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not purge login failures: %w", err)
+	}
+
+	for _, key := range loginKeys(username, ip) {
+		if err := recordFailure(ctx, conn, key, username, ip); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func recordFailure(ctx context.Context, conn *sql.DB, key loginKey, username string, ip string) error {
+	var failures int
+
+	err := conn.QueryRowContext(ctx, `INSERT INTO login_failures (key, failures, last_failure)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE
+		SET
+		failures = CASE
+			WHEN login_failures.last_failure < now() - make_interval(secs => $2) THEN 1
+			ELSE login_failures.failures + 1
+		END,
+		last_failure = now(),
+		pending = GREATEST(login_failures.pending - 1, 0)
+	RETURNING failures`,
+		key.key,
+		FailureWindow.Seconds(),
+	).Scan(&failures)
+
+	if err != nil {
+		return fmt.Errorf("could not record login failure: %w", err)
+	}
+
+	if failures < key.threshold {
+		return nil
+	}
+
+	_, err = conn.ExecContext(ctx, `
+	UPDATE login_failures
+		SET
+		locked_until = now() + make_interval(secs => $2)
+		WHERE
+		key = $1`,
+		key.key,
+		LockoutDuration.Seconds(),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not lock login: %w", err)
+	}
+
+	// Only the failure that crosses the threshold is audited, later ones just
+	// extend the lock
+	if failures == key.threshold {
+		return InsertAuthAudit(ctx, conn, key.event, username, ip, "")
+	}
+
+	return nil
+}
+
+// RecordLoginSuccess clears the failures of the account from this address,
+// ends the attempt ReserveLoginAttempt reserved for the address and records
+// when the account logged in.
+func RecordLoginSuccess(ctx context.Context, conn *sql.DB, username string, ip string) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1;`,
+		pairKey(username, ip),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not clear login failures: %w", err)
+	}
+
+	if ip != "" {
+		_, err = conn.ExecContext(ctx, `
+		UPDATE login_failures
+			SET
+			pending = GREATEST(pending - 1, 0)
+			WHERE
+			key = $1`,
+			ipKey(ip),
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not clear login failures: %w", err)
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, `UPDATE users SET last_login_at = now() WHERE username = $1`,
+		username,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not record login: %w", err)
+	}
+
+	return nil
+}
+
+// ClearLoginFailures lifts the lockouts of an account from every address.
+func ClearLoginFailures(ctx context.Context, conn *sql.DB, username string) error {
+	// The address part of a key never contains a |
+	_, err := conn.ExecContext(ctx, `DELETE FROM login_failures
+	WHERE key LIKE 'ip:%' AND substr(key, strpos(key, '|') + 1) = $1;`,
+		accountKey(username),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not clear login failures: %w", err)
+	}
+
+	return nil
+}
+
+// UnlockAccount lifts the lockouts of an account and audits who lifted them.
+func UnlockAccount(ctx context.Context, conn *sql.DB, username string, actorId string) error {
+	if err := ClearLoginFailures(ctx, conn, username); err != nil {
+		return fmt.Errorf("could not unlock account: %w", err)
+	}
+
+	return InsertAuthAudit(ctx, conn, AuditAccountUnlocked, username, "", actorId)
+}
@@ -0,0 +1,392 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// migrations are applied in order when the server starts and each one is
+// recorded in schema_migrations, so new migrations are only ever appended.
+// The early ones are idempotent because they predate that bookkeeping.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS players (
+		name text PRIMARY KEY,
+		position text NOT NULL,
+		age integer NOT NULL,
+		experience integer NOT NULL,
+		season text NOT NULL,
+		points double precision NOT NULL,
+		threept double precision NOT NULL,
+		rebounds double precision NOT NULL,
+		assists double precision NOT NULL,
+		steals double precision NOT NULL,
+		blocks double precision NOT NULL,
+		turnovers double precision NOT NULL,
+		mp double precision NOT NULL
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS users (
+		id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+		username text NOT NULL UNIQUE,
+		password text NOT NULL
+	)`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS role text NOT NULL DEFAULT 'user'`,
+
+	`CREATE TABLE IF NOT EXISTS login_failures (
+		key text PRIMARY KEY,
+		failures integer NOT NULL DEFAULT 0,
+		last_failure timestamptz NOT NULL DEFAULT now(),
+		locked_until timestamptz
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS auth_audit (
+		id bigserial PRIMARY KEY,
+		event text NOT NULL,
+		username text,
+		ip text,
+		actor_id uuid,
+		created_at timestamptz NOT NULL DEFAULT now()
+	)`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS email text`,
+
+	`CREATE TABLE IF NOT EXISTS password_resets (
+		token_hash text PRIMARY KEY,
+		user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		expires_at timestamptz NOT NULL,
+		used_at timestamptz
+	)`,
+
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_secret text`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_enabled boolean NOT NULL DEFAULT false`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS totp_last_step bigint`,
+
+	`CREATE TABLE IF NOT EXISTS recovery_codes (
+		user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		code_hash text NOT NULL,
+		used_at timestamptz,
+		PRIMARY KEY (user_id, code_hash)
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS login_challenges (
+		token_hash text PRIMARY KEY,
+		user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		expires_at timestamptz NOT NULL,
+		attempts integer NOT NULL DEFAULT 0
+	)`,
+
+	`CREATE TABLE IF NOT EXISTS settings (
+		key text PRIMARY KEY,
+		value text NOT NULL
+	)`,
+
+	`CREATE TABLE organizations (
+		id uuid PRIMARY KEY DEFAULT gen_random_uuid(),
+		name text NOT NULL UNIQUE,
+		created_at timestamptz NOT NULL DEFAULT now()
+	)`,
+
+	`CREATE TABLE organization_members (
+		org_id uuid NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+		user_id uuid NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+		role text NOT NULL,
+		joined_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (org_id, user_id)
+	)`,
+
+	// Everything that exists before organizations moves into a default one,
+	// and every existing user keeps editing it
+	`INSERT INTO organizations (id, name) VALUES ('` + DefaultOrganizationId + `', 'Default')`,
+
+	`INSERT INTO organization_members (org_id, user_id, role)
+	SELECT '` + DefaultOrganizationId + `', id, 'editor' FROM users`,
+
+	`ALTER TABLE players ADD COLUMN org_id uuid REFERENCES organizations (id) ON DELETE CASCADE`,
+
+	`UPDATE players SET org_id = '` + DefaultOrganizationId + `'`,
+
+	`ALTER TABLE players ALTER COLUMN org_id SET NOT NULL`,
+
+	// Player names are only unique within an organization
+	`ALTER TABLE players DROP CONSTRAINT players_pkey,
+		ADD PRIMARY KEY (org_id, name)`,
+
+	// Player queries run as app_user, which unlike the connecting role is not
+	// the table owner and cannot bypass row level security, with app.org_id
+	// set to the caller's organization
+	`DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = 'app_user') THEN
+			CREATE ROLE app_user NOLOGIN NOBYPASSRLS;
+		END IF;
+		EXECUTE format('GRANT app_user TO %I', current_user);
+	END
+	$$`,
+
+	`GRANT SELECT, INSERT, UPDATE, DELETE ON players TO app_user`,
+
+	`ALTER TABLE players ENABLE ROW LEVEL SECURITY`,
+
+	`CREATE POLICY players_org ON players
+		USING (org_id = current_setting('app.org_id', true)::uuid)
+		WITH CHECK (org_id = current_setting('app.org_id', true)::uuid)`,
+
+	`CREATE TABLE invitations (
+		code_hash text PRIMARY KEY,
+		role text NOT NULL,
+		created_by uuid REFERENCES users (id) ON DELETE SET NULL,
+		created_at timestamptz NOT NULL DEFAULT now(),
+		expires_at timestamptz NOT NULL,
+		used_at timestamptz,
+		used_by uuid REFERENCES users (id) ON DELETE SET NULL
+	)`,
+
+	`ALTER TABLE users
+		ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+		ADD COLUMN last_login_at timestamptz,
+		ADD COLUMN disabled boolean NOT NULL DEFAULT false,
+		ADD COLUMN tokens_valid_after timestamptz`,
+
+	`ALTER TABLE players ADD COLUMN id uuid NOT NULL DEFAULT gen_random_uuid() UNIQUE`,
+
+	// Organizations with audit rows cannot be deleted, the history outlives
+	// the players it is about
+	`CREATE TABLE player_audit (
+		id bigserial PRIMARY KEY,
+		org_id uuid NOT NULL REFERENCES organizations (id),
+		player_id uuid NOT NULL,
+		player_name text NOT NULL,
+		action text NOT NULL,
+		actor_id uuid,
+		changes jsonb NOT NULL,
+		created_at timestamptz NOT NULL DEFAULT now()
+	)`,
+
+	`CREATE INDEX player_audit_player ON player_audit (player_id, id)`,
+
+	`CREATE FUNCTION player_audit_append_only() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'player_audit is append only';
+	END
+	$$ LANGUAGE plpgsql`,
+
+	`CREATE TRIGGER player_audit_append_only
+		BEFORE UPDATE OR DELETE ON player_audit
+		FOR EACH ROW EXECUTE FUNCTION player_audit_append_only()`,
+
+	`CREATE TRIGGER player_audit_no_truncate
+		BEFORE TRUNCATE ON player_audit
+		FOR EACH STATEMENT EXECUTE FUNCTION player_audit_append_only()`,
+
+	`GRANT SELECT, INSERT ON player_audit TO app_user`,
+
+	`GRANT USAGE ON SEQUENCE player_audit_id_seq TO app_user`,
+
+	// Player history shows who made each change
+	`GRANT SELECT (id, username) ON users TO app_user`,
+
+	`ALTER TABLE player_audit ENABLE ROW LEVEL SECURITY`,
+
+	`CREATE POLICY player_audit_org ON player_audit
+		USING (org_id = current_setting('app.org_id', true)::uuid)
+		WITH CHECK (org_id = current_setting('app.org_id', true)::uuid)`,
+
+	`ALTER TABLE players ADD COLUMN version integer NOT NULL DEFAULT 1`,
+
+	`ALTER TABLE players
+		ADD COLUMN deleted_at timestamptz,
+		ADD COLUMN deleted_by uuid`,
+
+	// Names only have to be unique among players that are not in the trash
+	`ALTER TABLE players DROP CONSTRAINT players_pkey,
+		ADD PRIMARY KEY (id)`,
+
+	`CREATE UNIQUE INDEX players_org_name ON players (org_id, name) WHERE deleted_at IS NULL`,
+
+	`CREATE INDEX players_deleted ON players (deleted_at) WHERE deleted_at IS NOT NULL`,
+
+	// Every stat line so far was a regular season one
+	`ALTER TABLE players ADD COLUMN season_type text NOT NULL DEFAULT 'REGULAR'`,
+
+	`CREATE TABLE player_stats (
+		player_id uuid NOT NULL REFERENCES players (id) ON DELETE CASCADE,
+		org_id uuid NOT NULL REFERENCES organizations (id) ON DELETE CASCADE,
+		season text NOT NULL,
+		season_type text NOT NULL,
+		points double precision NOT NULL,
+		threept double precision NOT NULL,
+		rebounds double precision NOT NULL,
+		assists double precision NOT NULL,
+		steals double precision NOT NULL,
+		blocks double precision NOT NULL,
+		turnovers double precision NOT NULL,
+		mp double precision NOT NULL,
+		updated_at timestamptz NOT NULL DEFAULT now(),
+		PRIMARY KEY (player_id, season, season_type)
+	)`,
+
+	`INSERT INTO player_stats (
+		player_id, org_id, season, season_type,
+		points, threept, rebounds, assists, steals, blocks, turnovers, mp
+	)
+	SELECT
+		id, org_id, season, season_type,
+		points, threept, rebounds, assists, steals, blocks, turnovers, mp
+	FROM players`,
+
+	`GRANT SELECT, INSERT, UPDATE, DELETE ON player_stats TO app_user`,
+
+	`ALTER TABLE player_stats ENABLE ROW LEVEL SECURITY`,
+
+	`CREATE POLICY player_stats_org ON player_stats
+		USING (org_id = current_setting('app.org_id', true)::uuid)
+		WITH CHECK (org_id = current_setting('app.org_id', true)::uuid)`,
+
+	// Age and experience are computed from the profile, the stored values
+	// are only kept for players without the profile fields they come from
+	`ALTER TABLE players
+		ALTER COLUMN age DROP NOT NULL,
+		ALTER COLUMN experience DROP NOT NULL,
+		ADD COLUMN birth_date date,
+		ADD COLUMN height_cm integer,
+		ADD COLUMN weight_kg integer,
+		ADD COLUMN college text,
+		ADD COLUMN country text,
+		ADD COLUMN draft_year integer,
+		ADD COLUMN draft_round integer,
+		ADD COLUMN draft_pick integer,
+		ADD COLUMN debut_season text,
+		ADD COLUMN jersey_number integer,
+		ADD COLUMN handedness text`,
+
+	// The position column keeps the group, players stored before lineup
+	// positions are eligible for every position in theirs
+	`ALTER TABLE players
+		ADD COLUMN primary_position text,
+		ADD COLUMN eligible_positions text[] NOT NULL DEFAULT '{}'`,
+
+	`UPDATE players SET eligible_positions = CASE position
+		WHEN 'guard' THEN ARRAY['PG', 'SG']
+		WHEN 'forward' THEN ARRAY['SF', 'PF']
+		ELSE ARRAY['C']
+	END`,
+
+	`CREATE INDEX players_eligible_positions ON players USING gin (eligible_positions)`,
+
+	// Audit rows name the account they are about by id, so they follow it
+	// through renames and can be told apart from rows about other people who
+	// used the same username. Older rows are matched on the username they
+	// were logged under.
+	`ALTER TABLE auth_audit ADD COLUMN user_id uuid`,
+
+	`UPDATE auth_audit a SET user_id = u.id FROM users u WHERE a.username = u.username`,
+
+	`CREATE INDEX auth_audit_user_id ON auth_audit (user_id)`,
+
+	`CREATE INDEX auth_audit_actor_id ON auth_audit (actor_id)`,
+
+	// Stats are per game averages, games played turns them into totals. It
+	// is unknown for the lines stored before.
+	`ALTER TABLE players ADD COLUMN games_played integer`,
+
+	`ALTER TABLE player_stats ADD COLUMN games_played integer`,
+
+	// Invitations name the organization the invited user joins. The ones
+	// made before join the default organization as viewers.
+	`ALTER TABLE invitations
+		ADD COLUMN org_id uuid REFERENCES organizations (id) ON DELETE CASCADE,
+		ADD COLUMN org_role text`,
+
+	`UPDATE invitations SET org_id = o.id, org_role = 'viewer'
+	FROM organizations o
+	WHERE o.id = '` + DefaultOrganizationId + `'`,
+
+	// Account failures are counted per address now, the old account wide
+	// counts would never be cleared
+	`DELETE FROM login_failures WHERE key LIKE 'user:%'`,
+
+	`CREATE INDEX login_failures_last_failure ON login_failures (last_failure)`,
+
+	// Attempts whose password is still being checked
+	`ALTER TABLE login_failures
+		ADD COLUMN pending integer NOT NULL DEFAULT 0,
+		ADD COLUMN last_attempt timestamptz NOT NULL DEFAULT now()`,
+
+	`CREATE TABLE password_reset_requests (
+		key text PRIMARY KEY,
+		requests integer NOT NULL,
+		window_start timestamptz NOT NULL
+	)`,
+
+	`CREATE INDEX password_reset_requests_window_start ON password_reset_requests (window_start)`,
+}
+
+// DefaultOrganizationId is the organization players and users that predate
+// organizations were moved into
+const DefaultOrganizationId = "00000000-0000-0000-0000-000000000001"
+
+// migrationLock is the advisory lock key held while a migration is applied
+const migrationLock = 7428301
+
+func Migrate(conn *sql.DB) error {
+	_, err := conn.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version integer PRIMARY KEY,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+
+	if err != nil {
+		return fmt.Errorf("could not create schema_migrations: %w", err)
+	}
+
+	var applied int
+	err = conn.QueryRow(`SELECT COALESCE(MAX(version) + 1, 0) FROM schema_migrations`).Scan(&applied)
+
+	if err != nil {
+		return fmt.Errorf("could not get schema version: %w", err)
+	}
+
+	for version := applied; version < len(migrations); version++ {
+		if err := applyMigration(conn, version); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("applied %d migrations", len(migrations)-applied)
+
+	return nil
+}
+
+func applyMigration(conn *sql.DB, version int) error {
+	tx, err := conn.Begin()
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Several instances can start at once, the lock makes sure only one of
+	// them applies each migration
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLock); err != nil {
+		return fmt.Errorf("could not lock migrations: %w", err)
+	}
+
+	var done bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)`, version).Scan(&done)
+	if err != nil {
+		return fmt.Errorf("could not get schema version: %w", err)
+	}
+	if done {
+		return nil
+	}
+
+	if _, err := tx.Exec(migrations[version]); err != nil {
+		return fmt.Errorf("could not apply migration %d: %w", version, err)
+	}
+
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES ($1)`, version); err != nil {
+		return fmt.Errorf("could not record migration %d: %w", version, err)
+	}
+
+	return tx.Commit()
+}
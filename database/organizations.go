@@ -0,0 +1,257 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// CreateOrganization creates an organization and makes its creator an admin
+// of it.
+func CreateOrganization(ctx context.Context, conn *sql.DB, name string, creatorId string) (*model.Organization, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `INSERT INTO organizations (name)
+	VALUES ($1)
+	RETURNING id`,
+		name,
+	).Scan(&id)
+
+	if isUniqueViolation(err) {
+		return nil, apperr.Errorf(apperr.Conflict, "organization %s already exists", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not create organization: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (
+		org_id,
+		user_id,
+		role
+	)
+	VALUES ($1, $2, $3)`,
+		id,
+		creatorId,
+		model.OrgRoleAdmin,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not add organization member: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("could not commit organization: %w", err)
+	}
+
+	role := model.OrgRoleAdmin
+	return &model.Organization{ID: id, Name: name, Role: &role}, nil
+}
+
+// OrganizationExists reports whether an organization with the id exists.
+func OrganizationExists(ctx context.Context, conn *sql.DB, orgId string) (bool, error) {
+	var exists bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM organizations WHERE id = $1
+	)`, orgId).Scan(&exists)
+
+	if err != nil {
+		return false, fmt.Errorf("could not get organization: %w", err)
+	}
+
+	return exists, nil
+}
+
+// GetMembership returns a user's role in an organization, or an empty role if
+// they are not a member.
+func GetMembership(ctx context.Context, conn *sql.DB, userId string, orgId string) (model.OrgRole, error) {
+	var role string
+
+	err := conn.QueryRowContext(ctx, `SELECT role FROM organization_members
+	WHERE user_id = $1 AND org_id = $2;`, userId, orgId).Scan(&role)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not get membership: %w", err)
+	}
+
+	return model.OrgRole(role), nil
+}
+
+// GetDefaultMembership returns the organization a user joined first, which is
+// the one their token is issued for at login. Both are empty for users that
+// are not in any organization.
+func GetDefaultMembership(ctx context.Context, conn *sql.DB, userId string) (string, model.OrgRole, error) {
+	var orgId string
+	var role string
+
+	err := conn.QueryRowContext(ctx, `SELECT org_id, role FROM organization_members
+	WHERE user_id = $1
+	ORDER BY joined_at, org_id
+	LIMIT 1;`, userId).Scan(&orgId, &role)
+
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("could not get membership: %w", err)
+	}
+
+	return orgId, model.OrgRole(role), nil
+}
+
+// CanEditAnyOrganization reports whether a user is an editor or admin of any
+// organization, which makes them privileged like the global roles are.
+func CanEditAnyOrganization(ctx context.Context, conn *sql.DB, userId string) (bool, error) {
+	var editor bool
+
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM organization_members
+		WHERE user_id = $1 AND role IN ('editor', 'admin')
+	)`, userId).Scan(&editor)
+
+	if err != nil {
+		return false, fmt.Errorf("could not get memberships: %w", err)
+	}
+
+	return editor, nil
+}
+
+// GetOrganizations returns the organizations a user belongs to, or every
+// organization when all is set.
+func GetOrganizations(ctx context.Context, conn *sql.DB, userId string, all bool) ([]*model.Organization, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT o.id, o.name, m.role
+	FROM organizations o
+	LEFT JOIN organization_members m ON m.org_id = o.id AND m.user_id = $1
+	WHERE $2 OR m.user_id IS NOT NULL
+	ORDER BY o.name;`, userId, all)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get organizations: %w", err)
+	}
+
+	organizations, err := getOrganizationRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get organization rows: %w", err)
+	}
+
+	return organizations, nil
+}
+
+func GetOrganizationMembers(ctx context.Context, conn *sql.DB, orgId string) ([]*model.OrganizationMember, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT u.id, u.username, m.role
+	FROM organization_members m
+	JOIN users u ON u.id = m.user_id
+	WHERE m.org_id = $1
+	ORDER BY u.username;`, orgId)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get organization members: %w", err)
+	}
+
+	members, err := getOrganizationMemberRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get organization member rows: %w", err)
+	}
+
+	return members, nil
+}
+
+// SetOrganizationMember adds a user to an organization or changes their role
+// in it. Tokens carry the role they were issued with, so changing the role of
+// a member ends their sessions.
+func SetOrganizationMember(ctx context.Context, conn *sql.DB, orgId string, userId string, role model.OrgRole) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT role FROM organization_members
+	WHERE org_id = $1 AND user_id = $2
+	FOR UPDATE;`, orgId, userId).Scan(&previous)
+
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("could not get membership: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (
+		org_id,
+		user_id,
+		role
+	)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (org_id, user_id) DO UPDATE SET role = $3`,
+		orgId,
+		userId,
+		role,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not set organization member: %w", err)
+	}
+
+	if previous != "" && previous != string(role) {
+		if err := endSessionsTx(ctx, tx, userId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RemoveOrganizationMember takes a user out of an organization and ends their
+// sessions, which may still be acting in it.
+func RemoveOrganizationMember(ctx context.Context, conn *sql.DB, orgId string, userId string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM organization_members
+	WHERE org_id = $1 AND user_id = $2;`,
+		orgId,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not remove organization member: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("could not remove organization member: %w", err)
+	}
+
+	if removed > 0 {
+		if err := endSessionsTx(ctx, tx, userId); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// endSessionsTx rejects every token issued to a user so far.
+func endSessionsTx(ctx context.Context, tx *sql.Tx, userId string) error {
+	_, err := tx.ExecContext(ctx, `UPDATE users SET tokens_valid_after = now() WHERE id = $1;`, userId)
+
+	if err != nil {
+		return fmt.Errorf("could not end sessions: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// CreatePasswordReset stores a reset token hash for a user, replacing any
+// reset the user still had outstanding.
+func CreatePasswordReset(ctx context.Context, conn *sql.DB, userId string, tokenHash string, expiresAt time.Time) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM password_resets WHERE user_id = $1;`,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not delete old password resets: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO password_resets (
+		token_hash,
+		user_id,
+		expires_at
+	)
+	VALUES ($1, $2, $3)`,
+		tokenHash,
+		userId,
+		expiresAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not create password reset: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// PasswordResetValid reports whether a reset token can still be used, so the
+// new password is only hashed for tokens that will be accepted.
+func PasswordResetValid(ctx context.Context, conn *sql.DB, tokenHash string) (bool, error) {
+	var valid bool
+	err := conn.QueryRowContext(ctx, `SELECT EXISTS (
+		SELECT 1 FROM password_resets
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > now()
+	)`, tokenHash).Scan(&valid)
+
+	if err != nil {
+		return false, fmt.Errorf("could not get password reset: %w", err)
+	}
+
+	return valid, nil
+}
+
+// ResetPassword uses up a reset token and sets the user's password in one
+// transaction. It also ends the user's sessions, so whoever may have taken
+// over the account is logged out. It returns the id and username of the user
+// the password was reset for, both empty if the token is unknown, expired or
+// already used.
+func ResetPassword(ctx context.Context, conn *sql.DB, tokenHash string, hashedPassword string) (string, string, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", "", fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Marking the token used in the same statement that checks it keeps two
+	// concurrent resets from both succeeding
+	var userId string
+	err = tx.QueryRowContext(ctx, `
+	UPDATE password_resets
+		SET
+		used_at = now()
+		WHERE
+		token_hash = $1 AND used_at IS NULL AND expires_at > now()
+	RETURNING user_id`,
+		tokenHash,
+	).Scan(&userId)
+
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("could not use password reset: %w", err)
+	}
+
+	var username string
+	err = tx.QueryRowContext(ctx, `
+	UPDATE users
+		SET
+		password = $2,
+		tokens_valid_after = now()
+		WHERE
+		id = $1
+	RETURNING username`,
+		userId,
+		hashedPassword,
+	).Scan(&username)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not update user password: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", "", fmt.Errorf("could not commit password reset: %w", err)
+	}
+
+	return userId, username, nil
+}
@@ -0,0 +1,283 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+type playerField struct {
+	path  string
+	value func(p *model.Player) string
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Profile fields that are not set are audited as empty strings
+func formatOptional(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// playerFields are the audited fields of a player, in the order changes are
+// listed.
+var playerFields = []playerField{
+	{"name", func(p *model.Player) string { return p.Name }},
+	{"pos", func(p *model.Player) string { return string(p.Pos) }},
+	{"primaryPosition", func(p *model.Player) string {
+		if p.PrimaryPosition == nil {
+			return ""
+		}
+		return string(*p.PrimaryPosition)
+	}},
+	{"eligiblePositions", func(p *model.Player) string {
+		positions := make([]string, len(p.EligiblePositions))
+		for i, position := range p.EligiblePositions {
+			positions[i] = string(position)
+		}
+		return strings.Join(positions, ",")
+	}},
+	{"age", func(p *model.Player) string { return strconv.Itoa(p.Age) }},
+	{"experience", func(p *model.Player) string { return strconv.Itoa(p.Experience) }},
+	{"profile.birthDate", func(p *model.Player) string { return formatOptional(p.Profile.BirthDate) }},
+	{"profile.height", func(p *model.Player) string { return formatOptionalInt(p.Profile.Height) }},
+	{"profile.weight", func(p *model.Player) string { return formatOptionalInt(p.Profile.Weight) }},
+	{"profile.college", func(p *model.Player) string { return formatOptional(p.Profile.College) }},
+	{"profile.country", func(p *model.Player) string { return formatOptional(p.Profile.Country) }},
+	{"profile.draftYear", func(p *model.Player) string { return formatOptionalInt(p.Profile.DraftYear) }},
+	{"profile.draftRound", func(p *model.Player) string { return formatOptionalInt(p.Profile.DraftRound) }},
+	{"profile.draftPick", func(p *model.Player) string { return formatOptionalInt(p.Profile.DraftPick) }},
+	{"profile.debutSeason", func(p *model.Player) string { return formatOptional(p.Profile.DebutSeason) }},
+	{"profile.jerseyNumber", func(p *model.Player) string { return formatOptionalInt(p.Profile.JerseyNumber) }},
+	{"profile.handedness", func(p *model.Player) string {
+		if p.Profile.Handedness == nil {
+			return ""
+		}
+		return string(*p.Profile.Handedness)
+	}},
+	{"stats.season", func(p *model.Player) string { return p.Stats.Season }},
+	{"stats.seasonType", func(p *model.Player) string { return string(p.Stats.SeasonType) }},
+	{"stats.points", func(p *model.Player) string { return formatFloat(p.Stats.Points) }},
+	{"stats.threePt", func(p *model.Player) string { return formatFloat(p.Stats.ThreePt) }},
+	{"stats.rebounds", func(p *model.Player) string { return formatFloat(p.Stats.Rebounds) }},
+	{"stats.assists", func(p *model.Player) string { return formatFloat(p.Stats.Assists) }},
+	{"stats.steals", func(p *model.Player) string { return formatFloat(p.Stats.Steals) }},
+	{"stats.blocks", func(p *model.Player) string { return formatFloat(p.Stats.Blocks) }},
+	{"stats.turnOvers", func(p *model.Player) string { return formatFloat(p.Stats.TurnOvers) }},
+	{"stats.mp", func(p *model.Player) string { return formatFloat(p.Stats.Mp) }},
+	{"stats.gamesPlayed", func(p *model.Player) string { return formatOptionalInt(p.Stats.GamesPlayed) }},
+}
+
+// DiffPlayers lists the fields that differ between two states of a player.
+// before is nil for a created player and after for a deleted one, in which
+// case every field is listed.
+func DiffPlayers(before *model.Player, after *model.Player) []*model.FieldChange {
+	changes := []*model.FieldChange{}
+
+	for _, field := range playerFields {
+		change := &model.FieldChange{Field: field.path}
+		if before != nil {
+			value := field.value(before)
+			change.Before = &value
+		}
+		if after != nil {
+			value := field.value(after)
+			change.After = &value
+		}
+
+		if change.Before != nil && change.After != nil && *change.Before == *change.After {
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+// insertPlayerAudit records a change to a player in the transaction that made
+// it. Updates that did not change anything are not recorded.
+func insertPlayerAudit(ctx context.Context, tx *sql.Tx, orgId string, actorId string, action model.AuditAction, before *model.Player, after *model.Player) error {
+	changes := DiffPlayers(before, after)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	player := after
+	if player == nil {
+		player = before
+	}
+
+	changesJSON, err := json.Marshal(changes)
+	if err != nil {
+		return fmt.Errorf("could not marshal player changes: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO player_audit (
+		org_id,
+		player_id,
+		player_name,
+		action,
+		actor_id,
+		changes
+	)
+	VALUES ($1, $2, $3, $4, $5, $6)`,
+		orgId,
+		player.ID,
+		player.Name,
+		action,
+		nullString(actorId),
+		changesJSON,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not insert player audit: %w", err)
+	}
+
+	return nil
+}
+
+// playerAuditColumns are the columns getPlayerAuditRows scans. Users that no
+// longer exist show up under the same pseudonym as in auth_audit.
+const playerAuditColumns = `a.id,
+	a.player_id,
+	a.player_name,
+	a.org_id,
+	a.action,
+	a.actor_id,
+	COALESCE(u.username, 'erased-' || a.actor_id),
+	a.changes,
+	a.created_at`
+
+func getPlayerAuditRows(rows *sql.Rows) ([]*model.PlayerAuditEntry, error) {
+	entries := []*model.PlayerAuditEntry{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry model.PlayerAuditEntry
+		var changes []byte
+		var createdAt time.Time
+
+		if err := rows.Scan(
+			&entry.ID,
+			&entry.PlayerID,
+			&entry.PlayerName,
+			&entry.OrganizationID,
+			&entry.Action,
+			&entry.ActorID,
+			&entry.ActorUsername,
+			&changes,
+			&createdAt,
+		); err != nil {
+			return nil, fmt.Errorf("could not scan player audit: %w", err)
+		}
+
+		if err := json.Unmarshal(changes, &entry.Changes); err != nil {
+			return nil, fmt.Errorf("could not unmarshal player changes: %w", err)
+		}
+		entry.CreatedAt = createdAt.UTC().Format(time.RFC3339)
+
+		entries = append(entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return entries, nil
+}
+
+// pageAudit trims the extra row fetched to find out whether there is another
+// page.
+func pageAudit(entries []*model.PlayerAuditEntry, first int) ([]*model.PlayerAuditEntry, bool) {
+	if len(entries) > first {
+		return entries[:first], true
+	}
+	return entries, false
+}
+
+// GetPlayerHistory returns up to first changes to a player of an organization,
+// newest first, starting before the entry with id before (0 for the newest).
+func GetPlayerHistory(ctx context.Context, conn *sql.DB, orgId string, playerId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	var entries []*model.PlayerAuditEntry
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerAuditColumns+`
+		FROM player_audit a
+		LEFT JOIN users u ON u.id = a.actor_id
+		WHERE a.player_id = $1 AND ($2::bigint = 0 OR a.id < $2)
+		ORDER BY a.id DESC
+		LIMIT $3`, playerId, before, first+1)
+
+		if err != nil {
+			return fmt.Errorf("could not get player history: %w", err)
+		}
+
+		entries, err = getPlayerAuditRows(rows)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries, hasNext := pageAudit(entries, first)
+	return entries, hasNext, nil
+}
+
+// GetAuditLog returns player changes across organizations, or of one when
+// orgId is set, for admins. It does not go through withOrg.
+func GetAuditLog(ctx context.Context, conn *sql.DB, orgId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT `+playerAuditColumns+`
+	FROM player_audit a
+	LEFT JOIN users u ON u.id = a.actor_id
+	WHERE ($1 = '' OR a.org_id::text = $1) AND ($2::bigint = 0 OR a.id < $2)
+	ORDER BY a.id DESC
+	LIMIT $3`, orgId, before, first+1)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get audit log: %w", err)
+	}
+
+	entries, err := getPlayerAuditRows(rows)
+	if err != nil {
+		return nil, false, err
+	}
+
+	entries, hasNext := pageAudit(entries, first)
+	return entries, hasNext, nil
+}
+
+// GetPlayerChangesBy returns every player change a user made, for their data
+// export.
+func GetPlayerChangesBy(ctx context.Context, conn *sql.DB, actorId string) ([]*model.PlayerAuditEntry, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT `+playerAuditColumns+`
+	FROM player_audit a
+	LEFT JOIN users u ON u.id = a.actor_id
+	WHERE a.actor_id = $1
+	ORDER BY a.id`, actorId)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player changes: %w", err)
+	}
+
+	return getPlayerAuditRows(rows)
+}
@@ -0,0 +1,204 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// A player's stat lines are kept in player_stats, one per season and season
+// type. The players row holds a copy of the line written last, which is what
+// Player.stats, the player filters and the CSV export use.
+
+// playerStatsColumns are the player_stats columns scanStats scans, in order
+const playerStatsColumns = `season,
+	season_type,
+	points,
+	threept,
+	rebounds,
+	assists,
+	steals,
+	blocks,
+	turnovers,
+	mp,
+	games_played`
+
+// seasonType is the type of a stat line, lines written without one are for
+// the regular season.
+func seasonType(t model.SeasonType) model.SeasonType {
+	if t == "" {
+		return model.SeasonTypeRegular
+	}
+	return t
+}
+
+func scanStats(rows *sql.Rows) (*model.Stats, error) {
+	stats := &model.Stats{}
+	var seasonType string
+
+	if err := rows.Scan(
+		&stats.Season,
+		&seasonType,
+		&stats.Points,
+		&stats.ThreePt,
+		&stats.Rebounds,
+		&stats.Assists,
+		&stats.Steals,
+		&stats.Blocks,
+		&stats.TurnOvers,
+		&stats.Mp,
+		&stats.GamesPlayed,
+	); err != nil {
+		return nil, fmt.Errorf("could not scan player stats: %w", err)
+	}
+	stats.SeasonType = model.SeasonType(seasonType)
+
+	return stats, nil
+}
+
+func getStatsRows(rows *sql.Rows) ([]*model.Stats, error) {
+	lines := []*model.Stats{}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		stats, err := scanStats(rows)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, stats)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows error: %w", err)
+	}
+
+	return lines, nil
+}
+
+// savePlayerStatsTx stores the current line of a player that was just
+// written under its season and season type.
+func savePlayerStatsTx(ctx context.Context, tx *sql.Tx, orgId string, player *model.Player) error {
+	stats := player.Stats
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO player_stats (
+		player_id,
+		org_id,
+		`+playerStatsColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (player_id, season, season_type) DO UPDATE
+		SET
+		points = EXCLUDED.points,
+		threept = EXCLUDED.threept,
+		rebounds = EXCLUDED.rebounds,
+		assists = EXCLUDED.assists,
+		steals = EXCLUDED.steals,
+		blocks = EXCLUDED.blocks,
+		turnovers = EXCLUDED.turnovers,
+		mp = EXCLUDED.mp,
+		games_played = EXCLUDED.games_played,
+		updated_at = now()`,
+		player.ID,
+		orgId,
+		stats.Season,
+		stats.SeasonType,
+		stats.Points,
+		stats.ThreePt,
+		stats.Rebounds,
+		stats.Assists,
+		stats.Steals,
+		stats.Blocks,
+		stats.TurnOvers,
+		stats.Mp,
+		stats.GamesPlayed,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not save player stats: %w", err)
+	}
+
+	return nil
+}
+
+// updateBaseTx returns the player an update is applied to. An update that
+// moves to another season or season type starts from the stored line for it,
+// when there is one, rather than from the current line.
+func updateBaseTx(ctx context.Context, tx *sql.Tx, before *model.Player, update model.InputUpdatePlayer) (*model.Player, error) {
+	if update.Stats == nil {
+		return before, nil
+	}
+
+	season := before.Stats.Season
+	if update.Stats.Season != nil {
+		season = *update.Stats.Season
+	}
+	lineType := before.Stats.SeasonType
+	if update.Stats.SeasonType != nil {
+		lineType = seasonType(*update.Stats.SeasonType)
+	}
+	if season == before.Stats.Season && lineType == before.Stats.SeasonType {
+		return before, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+playerStatsColumns+` FROM player_stats
+	WHERE player_id = $1 AND season = $2 AND season_type = $3;`,
+		before.ID,
+		season,
+		lineType,
+	)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player stats: %w", err)
+	}
+
+	lines, err := getStatsRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player stats rows: %w", err)
+	}
+	if len(lines) == 0 {
+		return before, nil
+	}
+
+	base := *before
+	base.Stats = lines[0]
+	return &base, nil
+}
+
+// GetPlayerStats returns the stat lines of a player, newest season first and
+// in the order of the season within one. Season and seasonType narrow them
+// down when they are set.
+func GetPlayerStats(ctx context.Context, conn *sql.DB, orgId string, playerId string, season *string, seasonType *model.SeasonType) ([]*model.Stats, error) {
+	var lines []*model.Stats
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerStatsColumns+` FROM player_stats
+		WHERE
+		player_id = $1
+		AND ($2::text IS NULL OR season = $2)
+		AND ($3::text IS NULL OR season_type = $3)
+		ORDER BY
+		season DESC,
+		array_position(ARRAY['PRESEASON', 'REGULAR', 'PLAY_IN', 'PLAYOFFS'], season_type);`,
+			playerId,
+			season,
+			seasonType,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not get player stats: %w", err)
+		}
+
+		lines, err = getStatsRows(rows)
+
+		if err != nil {
+			return fmt.Errorf("could not get player stats rows: %w", err)
+		}
+
+		return nil
+	})
+
+	return lines, err
+}
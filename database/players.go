@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// playerFilterQuery builds the conditions for a players filter. Players in
+// the trash are never included.
+func playerFilterQuery(filter *model.PlayerFilter, args []interface{}) (string, []interface{}) {
+	conditions := []string{"deleted_at IS NULL"}
+
+	if filter != nil {
+		if filter.Name != nil {
+			args = append(args, "%"+escapeLike(*filter.Name)+"%")
+			conditions = append(conditions, fmt.Sprintf("name ILIKE $%d", len(args)))
+		}
+		// Positions match on eligibility, a group on any of its positions
+		if filter.Pos != nil {
+			args = append(args, pq.Array(filter.Pos.Positions()))
+			conditions = append(conditions, fmt.Sprintf("eligible_positions && $%d::text[]", len(args)))
+		}
+		if filter.Position != nil {
+			args = append(args, *filter.Position)
+			conditions = append(conditions, fmt.Sprintf("$%d = ANY (eligible_positions)", len(args)))
+		}
+		if filter.Season != nil {
+			args = append(args, *filter.Season)
+			conditions = append(conditions, fmt.Sprintf("season = $%d", len(args)))
+		}
+		if filter.SeasonType != nil {
+			args = append(args, *filter.SeasonType)
+			conditions = append(conditions, fmt.Sprintf("season_type = $%d", len(args)))
+		}
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetPlayers returns up to first players of an organization matching filter,
+// ordered by name, starting after the name after, and whether there are more.
+func GetPlayers(ctx context.Context, conn *sql.DB, orgId string, filter *model.PlayerFilter, first int, after string) ([]*model.Player, bool, error) {
+	var players []*model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		where, args := playerFilterQuery(filter, []interface{}{after})
+
+		// One extra row tells us whether there is another page
+		args = append(args, first+1)
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+		WHERE name > $1 AND `+where+`
+		ORDER BY name
+		LIMIT $`+fmt.Sprint(len(args)), args...)
+
+		if err != nil {
+			return fmt.Errorf("could not get players: %w", err)
+		}
+
+		players, err = getPlayerListRows(rows)
+
+		if err != nil {
+			return fmt.Errorf("could not not get player rows: %w", err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(players) > first {
+		return players[:first], true, nil
+	}
+
+	return players, false, nil
+}
+
+// StreamPlayers calls fn with every player of an organization matching
+// filter, ordered by name, without holding them all in memory. It stops at
+// the first error fn returns.
+func StreamPlayers(ctx context.Context, conn *sql.DB, orgId string, filter *model.PlayerFilter, fn func(*model.Player) error) error {
+	return withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		where, args := playerFilterQuery(filter, nil)
+
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+		WHERE `+where+`
+		ORDER BY name`, args...)
+
+		if err != nil {
+			return fmt.Errorf("could not get players: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			player, err := scanPlayer(rows)
+			if err != nil {
+				return err
+			}
+			if err := fn(player); err != nil {
+				return err
+			}
+		}
+
+		if err := rows.Err(); err != nil {
+			return fmt.Errorf("rows error: %w", err)
+		}
+
+		return nil
+	})
+}
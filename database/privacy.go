@@ -0,0 +1,234 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// AuditAccountErased is recorded under the pseudonym of an erased user.
+const AuditAccountErased = "account_erased"
+
+// ExportUserData collects a user's data, nil if there is no such user.
+func ExportUserData(ctx context.Context, conn *sql.DB, userId string) (*model.UserExport, error) {
+	user, err := GetUserById(ctx, conn, userId)
+	if err != nil || user == nil {
+		return nil, err
+	}
+
+	export := &model.UserExport{ExportedAt: time.Now().UTC(), Account: user}
+
+	export.Organizations, err = GetOrganizations(ctx, conn, userId, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var validAfter sql.NullTime
+	err = conn.QueryRowContext(ctx, `SELECT tokens_valid_after FROM users WHERE id = $1`, userId).Scan(&validAfter)
+	if err != nil {
+		return nil, fmt.Errorf("could not export sessions: %w", err)
+	}
+	if validAfter.Valid {
+		export.Sessions.TokensValidAfter = &validAfter.Time
+	}
+
+	export.Sessions.LoginChallenges, err = exportTimestamps(ctx, conn, `SELECT expires_at, NULL::timestamptz FROM login_challenges
+	WHERE user_id = $1 ORDER BY expires_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.Sessions.PasswordResets, err = exportTimestamps(ctx, conn, `SELECT expires_at, used_at FROM password_resets
+	WHERE user_id = $1 ORDER BY expires_at`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.QueryRowContext(ctx, `SELECT count(*) FROM recovery_codes
+	WHERE user_id = $1 AND used_at IS NULL`, userId).Scan(&export.Sessions.RecoveryCodes)
+	if err != nil {
+		return nil, fmt.Errorf("could not export recovery codes: %w", err)
+	}
+
+	export.Invitations, err = exportInvitations(ctx, conn, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.AuthoredChanges, err = exportAuditEvents(ctx, conn, `SELECT event, username, ip, created_at FROM auth_audit
+	WHERE actor_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.SecurityEvents, err = exportAuditEvents(ctx, conn, `SELECT event, username, ip, created_at FROM auth_audit
+	WHERE user_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	export.PlayerChanges, err = GetPlayerChangesBy(ctx, conn, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func exportTimestamps(ctx context.Context, conn *sql.DB, query string, userId string) ([]model.ExportTimestamp, error) {
+	rows, err := conn.QueryContext(ctx, query, userId)
+	if err != nil {
+		return nil, fmt.Errorf("could not export sessions: %w", err)
+	}
+	defer rows.Close()
+
+	timestamps := []model.ExportTimestamp{}
+	for rows.Next() {
+		var timestamp model.ExportTimestamp
+		var usedAt sql.NullTime
+		if err := rows.Scan(&timestamp.ExpiresAt, &usedAt); err != nil {
+			return nil, fmt.Errorf("could not scan session: %w", err)
+		}
+		if usedAt.Valid {
+			timestamp.UsedAt = &usedAt.Time
+		}
+		timestamps = append(timestamps, timestamp)
+	}
+
+	return timestamps, rows.Err()
+}
+
+func exportInvitations(ctx context.Context, conn *sql.DB, userId string) ([]model.ExportInvitation, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT role, created_by = $1, created_at, expires_at, used_at
+	FROM invitations
+	WHERE created_by = $1 OR used_by = $1
+	ORDER BY created_at`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("could not export invitations: %w", err)
+	}
+	defer rows.Close()
+
+	invitations := []model.ExportInvitation{}
+	for rows.Next() {
+		var invitation model.ExportInvitation
+		var created sql.NullBool
+		var usedAt sql.NullTime
+		if err := rows.Scan(&invitation.Role, &created, &invitation.CreatedAt, &invitation.ExpiresAt, &usedAt); err != nil {
+			return nil, fmt.Errorf("could not scan invitation: %w", err)
+		}
+		invitation.Created = created.Bool
+		if usedAt.Valid {
+			invitation.UsedAt = &usedAt.Time
+		}
+		invitations = append(invitations, invitation)
+	}
+
+	return invitations, rows.Err()
+}
+
+func exportAuditEvents(ctx context.Context, conn *sql.DB, query string, arg string) ([]model.ExportAuditEvent, error) {
+	rows, err := conn.QueryContext(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("could not export audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []model.ExportAuditEvent{}
+	for rows.Next() {
+		var event model.ExportAuditEvent
+		if err := rows.Scan(&event.Event, &event.Username, &event.Ip, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("could not scan audit event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ErasedUsername is the pseudonym audit rows of an erased user are kept
+// under. It is derived from the user id, which audit rows keep as actor_id,
+// so rows about and rows by the user still line up after erasure.
+func ErasedUsername(userId string) string {
+	return "erased-" + userId
+}
+
+// EraseUser deletes a user and everything that belongs to them. Audit rows
+// about the user are kept but pseudonymized, and every row about or by them
+// is stripped of client addresses. Rows are matched on the user's id, so rows
+// logged under a previous username are included and rows about other people
+// who used the same username are left alone. Login failures are dropped and
+// invitations forget who created or used them.
+func EraseUser(ctx context.Context, conn *sql.DB, userId string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var username string
+	err = tx.QueryRowContext(ctx, `SELECT username FROM users WHERE id = $1 FOR UPDATE`, userId).Scan(&username)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get user: %w", err)
+	}
+
+	pseudonym := ErasedUsername(userId)
+
+	// Rows by the user as an admin name someone else, only the address they
+	// acted from is theirs
+	_, err = tx.ExecContext(ctx, `
+	UPDATE auth_audit
+		SET
+		username = CASE WHEN user_id = $1 THEN $2 ELSE username END,
+		ip = NULL
+		WHERE
+		user_id = $1 OR actor_id = $1`,
+		userId,
+		pseudonym,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not pseudonymize audit rows: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM login_failures WHERE key = $1;`,
+		accountKey(username),
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not delete login failures: %w", err)
+	}
+
+	// Memberships, resets, recovery codes and challenges go with the user,
+	// invitations keep their row with the user set to null
+	_, err = tx.ExecContext(ctx, `DELETE FROM users WHERE id = $1;`,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not delete user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO auth_audit (
+		event,
+		username,
+		actor_id,
+		user_id
+	)
+	VALUES ($1, $2, $3, $3)`,
+		AuditAccountErased,
+		pseudonym,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not insert auth audit: %w", err)
+	}
+
+	return tx.Commit()
+}
@@ -2,15 +2,78 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 
+	"github.com/lib/pq"
+	"github.com/mattmazer1/graphql-api/apperr"
 	"github.com/mattmazer1/graphql-api/graph/model"
 	"github.com/mattmazer1/graphql-api/utils"
+	"github.com/mattmazer1/graphql-api/validate"
 )
 
-func GetPlayer(ctx context.Context, name string) (*model.Player, error) {
-	rows, err := Db.QueryContext(ctx, `SELECT * FROM players
-	WHERE name = $1;`, name)
+// playerColumns are the players columns getRows scans, in order
+const playerColumns = `id,
+	name,
+	position,
+	primary_position,
+	eligible_positions,
+	age,
+	experience,
+	season,
+	season_type,
+	points,
+	threept,
+	rebounds,
+	assists,
+	steals,
+	blocks,
+	turnovers,
+	mp,
+	games_played,
+	birth_date,
+	height_cm,
+	weight_kg,
+	college,
+	country,
+	draft_year,
+	draft_round,
+	draft_pick,
+	debut_season,
+	jersey_number,
+	handedness,
+	version,
+	deleted_at`
+
+// userColumns are the users columns getUserRows scans, in order
+const userColumns = `id, username, role, email, totp_enabled, created_at, last_login_at, disabled`
+
+// ErrPlayerNotFound is returned when a player does not exist in the
+// caller's organization.
+var ErrPlayerNotFound = apperr.New(apperr.NotFound, "player not found")
+
+// ErrUsernameTaken is returned when another user already has a username.
+var ErrUsernameTaken = apperr.New(apperr.Conflict, "username is already taken")
+
+// VersionConflictError is returned when a player was changed since the caller
+// read it. Current is the player as it is now.
+type VersionConflictError struct {
+	Current *model.Player
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("player %s was changed by someone else, it is at version %d", e.Current.Name, e.Current.Version)
+}
+
+// selectPlayerForUpdate reads a player that is not in the trash and locks its
+// row until the transaction ends, so the audited before state is the one that
+// gets overwritten. It returns nil if there is no such player.
+func selectPlayerForUpdate(ctx context.Context, tx *sql.Tx, name string) (*model.Player, error) {
+	rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+	WHERE name = $1 AND deleted_at IS NULL
+	FOR UPDATE;`, name)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not get player: %w", err)
@@ -25,38 +88,188 @@ func GetPlayer(ctx context.Context, name string) (*model.Player, error) {
 	return player, nil
 }
 
-func DeletePlayer(ctx context.Context, name string) error {
-	_, err := Db.ExecContext(ctx, `DELETE FROM players WHERE name = $1;`,
-		name,
+// lockPlayer reads a player for a change and checks it is still at the
+// version the caller expects.
+func lockPlayer(ctx context.Context, tx *sql.Tx, name string, expectedVersion int) (*model.Player, error) {
+	player, err := selectPlayerForUpdate(ctx, tx, name)
+	if err != nil {
+		return nil, err
+	}
+	if player == nil {
+		return nil, ErrPlayerNotFound
+	}
+	if player.Version != expectedVersion {
+		return nil, &VersionConflictError{Current: player}
+	}
+
+	return player, nil
+}
+
+// Every player query runs through withOrg, so it only ever sees the players
+// of the caller's organization. Changes are audited in the same transaction
+// under the acting user.
+
+// GetPlayer returns ErrPlayerNotFound for a name no live player has.
+func GetPlayer(ctx context.Context, conn *sql.DB, orgId string, name string) (*model.Player, error) {
+	var player *model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+		WHERE name = $1 AND deleted_at IS NULL;`, name)
+
+		if err != nil {
+			return fmt.Errorf("could not get player: %w", err)
+		}
+
+		player, err = getRows(rows, name)
+
+		if err != nil {
+			return fmt.Errorf("could not not get player rows: %w", err)
+		}
+		if player == nil {
+			return ErrPlayerNotFound
+		}
+
+		return nil
+	})
+
+	return player, err
+}
+
+func DeletePlayer(ctx context.Context, conn *sql.DB, orgId string, actorId string, name string, expectedVersion int) (*model.Player, error) {
+	var deleted *model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		before, err := lockPlayer(ctx, tx, name, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = deletePlayerTx(ctx, tx, orgId, actorId, before)
+		return err
+	})
+
+	return deleted, err
+}
+
+func CreatePlayer(ctx context.Context, conn *sql.DB, orgId string, actorId string, player model.InputPlayer) (*model.Player, error) {
+	var created *model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		var err error
+		created, err = createPlayerTx(ctx, tx, orgId, actorId, player)
+		return err
+	})
+
+	return created, err
+}
+
+func UpdatePlayer(ctx context.Context, conn *sql.DB, orgId string, actorId string, player model.InputUpdatePlayer, expectedVersion int) (*model.Player, error) {
+	var updated *model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		name := ""
+		if player.Name != nil {
+			name = *player.Name
+		}
+
+		before, err := lockPlayer(ctx, tx, name, expectedVersion)
+		if err != nil {
+			return err
+		}
+
+		updated, err = updatePlayerTx(ctx, tx, orgId, actorId, before, player)
+		return err
+	})
+
+	return updated, err
+}
+
+// The Tx functions make one change to a player inside a transaction set up
+// by withOrg and audit it. They are shared by the single and bulk mutations.
+
+// deletePlayerTx moves a locked player to the trash, PurgePlayers deletes it
+// for good later.
+func deletePlayerTx(ctx context.Context, tx *sql.Tx, orgId string, actorId string, before *model.Player) (*model.Player, error) {
+	rows, err := tx.QueryContext(ctx, `
+	UPDATE players
+		SET
+		deleted_at = now(),
+		deleted_by = $2,
+		version = version + 1
+		WHERE
+		id = $1
+		RETURNING `+playerColumns,
+		before.ID,
+		nullString(actorId),
 	)
 
 	if err != nil {
-		return fmt.Errorf("could not delete player: %w", err)
+		return nil, fmt.Errorf("could not delete player: %w", err)
 	}
-	return nil
+
+	deleted, err := getRows(rows, before.Name)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not not get player rows: %w", err)
+	}
+
+	return deleted, insertPlayerAudit(ctx, tx, orgId, actorId, model.AuditActionDelete, before, nil)
 }
 
-func CreatePlayer(ctx context.Context, player model.InputPlayer) error {
-	_, err := Db.ExecContext(ctx, `INSERT INTO players (
-			name,
-			position,
-			age,
-			experience,
-			season,
-			points,
-			threept,
-			rebounds,
-			assists,
-			steals,
-			blocks,
-			turnovers,
-			mp)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+func createPlayerTx(ctx context.Context, tx *sql.Tx, orgId string, actorId string, player model.InputPlayer) (*model.Player, error) {
+	if err := validate.Player(player); err != nil {
+		return nil, err
+	}
+
+	profile := player.Profile
+	if profile == nil {
+		profile = &model.InputProfile{}
+	}
+	pos, eligible := validate.Positions(player)
+
+	rows, err := tx.QueryContext(ctx, `INSERT INTO players (
+		org_id,
+		name,
+		position,
+		primary_position,
+		eligible_positions,
+		age,
+		experience,
+		season,
+		season_type,
+		points,
+		threept,
+		rebounds,
+		assists,
+		steals,
+		blocks,
+		turnovers,
+		mp,
+		birth_date,
+		height_cm,
+		weight_kg,
+		college,
+		country,
+		draft_year,
+		draft_round,
+		draft_pick,
+		debut_season,
+		jersey_number,
+		handedness,
+		games_played)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+		$16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29)
+		RETURNING `+playerColumns,
+		orgId,
 		player.Name,
-		player.Pos,
+		pos,
+		player.PrimaryPosition,
+		pq.Array(eligible),
 		player.Age,
 		player.Experience,
 		player.Stats.Season,
+		seasonType(player.Stats.SeasonType),
 		player.Stats.Points,
 		player.Stats.ThreePt,
 		player.Stats.Rebounds,
@@ -65,39 +278,99 @@ func CreatePlayer(ctx context.Context, player model.InputPlayer) error {
 		player.Stats.Blocks,
 		player.Stats.TurnOvers,
 		player.Stats.Mp,
+		profile.BirthDate,
+		profile.Height,
+		profile.Weight,
+		profile.College,
+		profile.Country,
+		profile.DraftYear,
+		profile.DraftRound,
+		profile.DraftPick,
+		profile.DebutSeason,
+		profile.JerseyNumber,
+		profile.Handedness,
+		player.Stats.GamesPlayed,
 	)
 
+	if isUniqueViolation(err) {
+		return nil, ErrPlayerNameTaken
+	}
 	if err != nil {
-		return fmt.Errorf("could not create player stats: %w", err)
+		return nil, fmt.Errorf("could not create player stats: %w", err)
 	}
 
-	return nil
+	created, err := getRows(rows, player.Name)
+
+	if isUniqueViolation(err) {
+		return nil, ErrPlayerNameTaken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not not get player rows: %w", err)
+	}
+
+	if err := savePlayerStatsTx(ctx, tx, orgId, created); err != nil {
+		return nil, err
+	}
+
+	return created, insertPlayerAudit(ctx, tx, orgId, actorId, model.AuditActionCreate, nil, created)
 }
 
-func UpdatePlayer(ctx context.Context, player model.InputUpdatePlayer) error {
-	_, err := Db.ExecContext(ctx,
+// updatePlayerTx changes the fields an update sets on a locked player and
+// leaves the rest as they are.
+func updatePlayerTx(ctx context.Context, tx *sql.Tx, orgId string, actorId string, before *model.Player, update model.InputUpdatePlayer) (*model.Player, error) {
+	base, err := updateBaseTx(ctx, tx, before, update)
+	if err != nil {
+		return nil, err
+	}
+
+	player := validate.MergeUpdate(base, update)
+	if err := validate.Player(player); err != nil {
+		return nil, err
+	}
+	pos, eligible := validate.Positions(player)
+
+	rows, err := tx.QueryContext(ctx,
 		`UPDATE players
 		SET
 		name = $1,
 		position = $2,
-		age = $3,
-		experience = $4,
-		season = $5,
-		points = $6,
-		threept = $7,
-		rebounds = $8,
-		assists = $9,
-		steals = $10,
-		blocks = $11,
-		turnovers = $12,
-		mp = $13
-		WHERE name = $1
-		`,
+		primary_position = $3,
+		eligible_positions = $4,
+		age = $5,
+		experience = $6,
+		season = $7,
+		season_type = $8,
+		points = $9,
+		threept = $10,
+		rebounds = $11,
+		assists = $12,
+		steals = $13,
+		blocks = $14,
+		turnovers = $15,
+		mp = $16,
+		birth_date = $17,
+		height_cm = $18,
+		weight_kg = $19,
+		college = $20,
+		country = $21,
+		draft_year = $22,
+		draft_round = $23,
+		draft_pick = $24,
+		debut_season = $25,
+		jersey_number = $26,
+		handedness = $27,
+		games_played = $28,
+		version = version + 1
+		WHERE id = $29
+		RETURNING `+playerColumns,
 		player.Name,
-		player.Pos,
+		pos,
+		player.PrimaryPosition,
+		pq.Array(eligible),
 		player.Age,
 		player.Experience,
 		player.Stats.Season,
+		seasonType(player.Stats.SeasonType),
 		player.Stats.Points,
 		player.Stats.ThreePt,
 		player.Stats.Rebounds,
@@ -106,17 +379,51 @@ func UpdatePlayer(ctx context.Context, player model.InputUpdatePlayer) error {
 		player.Stats.Blocks,
 		player.Stats.TurnOvers,
 		player.Stats.Mp,
+		player.Profile.BirthDate,
+		player.Profile.Height,
+		player.Profile.Weight,
+		player.Profile.College,
+		player.Profile.Country,
+		player.Profile.DraftYear,
+		player.Profile.DraftRound,
+		player.Profile.DraftPick,
+		player.Profile.DebutSeason,
+		player.Profile.JerseyNumber,
+		player.Profile.Handedness,
+		player.Stats.GamesPlayed,
+		before.ID,
 	)
 
 	if err != nil {
-		return fmt.Errorf("could not update player stats: %w", err)
+		return nil, fmt.Errorf("could not update player stats: %w", err)
 	}
 
-	return nil
+	updated, err := getRows(rows, before.Name)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not not get player rows: %w", err)
+	}
+
+	if err := savePlayerStatsTx(ctx, tx, orgId, updated); err != nil {
+		return nil, err
+	}
+
+	return updated, insertPlayerAudit(ctx, tx, orgId, actorId, model.AuditActionUpdate, before, updated)
+}
+
+// isUniqueViolation reports whether err is postgres rejecting a duplicate key.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
 }
 
-func GetUserId(username string) (string, error) {
-	rows, err := Db.Query(`SELECT id FROM users
+func GetUserId(conn *sql.DB, username string) (string, error) {
+	rows, err := conn.Query(`SELECT id FROM users
 	WHERE username = $1;`, username)
 
 	if err != nil {
@@ -132,8 +439,8 @@ func GetUserId(username string) (string, error) {
 	return id, nil
 }
 
-func GetUser(username string) (*model.User, error) {
-	rows, err := Db.Query(`SELECT * FROM users
+func GetUser(conn *sql.DB, username string) (*model.User, error) {
+	rows, err := conn.Query(`SELECT `+userColumns+` FROM users
 	WHERE username = $1;`, username)
 
 	if err != nil {
@@ -149,30 +456,126 @@ func GetUser(username string) (*model.User, error) {
 	return user, nil
 }
 
-func CreateUsr(ctx context.Context, user model.InputUser) error {
-	hashedPassword, pswerr := utils.HashPassword(user.Password)
+func GetUserById(ctx context.Context, conn *sql.DB, id string) (*model.User, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+	WHERE id = $1;`, id)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+
+	user, err := getUserRows(rows)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not not get user rows: %w", err)
+	}
+
+	return user, nil
+}
+
+// CreateUsr creates a user. With an invitation code hash the invitation is
+// used up in the same transaction and the user gets its role and joins its
+// organization, so an invitation is not lost when the username turns out to
+// be taken. Without one the user joins the default organization as a viewer.
+func CreateUsr(ctx context.Context, conn *sql.DB, user model.InputUser, invitationHash string) (string, error) {
+	hashedPassword, pswerr := utils.HashPasswordContext(ctx, user.Password)
 	if pswerr != nil {
-		return fmt.Errorf("could not hash user password: %w", pswerr)
+		return "", fmt.Errorf("could not hash user password: %w", pswerr)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("could not begin transaction: %w", err)
 	}
-	_, err := Db.ExecContext(ctx, `INSERT INTO users (
+	defer tx.Rollback()
+
+	role := model.RoleUser
+	orgId := sql.NullString{String: DefaultOrganizationId, Valid: true}
+	orgRole := sql.NullString{String: string(model.OrgRoleViewer), Valid: true}
+	if invitationHash != "" {
+		err := tx.QueryRowContext(ctx, `
+		UPDATE invitations
+			SET
+			used_at = now()
+			WHERE
+			code_hash = $1 AND used_at IS NULL AND expires_at > now()
+			RETURNING role, org_id, org_role`,
+			invitationHash,
+		).Scan(&role, &orgId, &orgRole)
+
+		if err == sql.ErrNoRows {
+			return "", ErrInvalidInvitation
+		}
+		if err != nil {
+			return "", fmt.Errorf("could not use invitation: %w", err)
+		}
+	}
+	if !orgId.Valid {
+		return "", ErrNoOrganization
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `INSERT INTO users (
 		id,
 		username,
-		password
+		password,
+		email,
+		role
 	)
-	VALUES (gen_random_uuid(), $1, $2)`,
+	VALUES (gen_random_uuid(), $1, $2, $3, $4)
+	RETURNING id`,
 		user.Username,
 		hashedPassword,
+		user.Email,
+		role,
+	).Scan(&id)
+
+	if isUniqueViolation(err) {
+		return "", ErrUsernameTaken
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not create user: %w", err)
+	}
+
+	if invitationHash != "" {
+		_, err = tx.ExecContext(ctx, `UPDATE invitations SET used_by = $2 WHERE code_hash = $1`,
+			invitationHash,
+			id,
+		)
+
+		if err != nil {
+			return "", fmt.Errorf("could not use invitation: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO organization_members (
+		org_id,
+		user_id,
+		role
+	)
+	VALUES ($1, $2, $3)`,
+		orgId.String,
+		id,
+		orgRole.String,
 	)
 
+	// The default organization may have been deleted
+	if isForeignKeyViolation(err) {
+		return "", ErrNoOrganization
+	}
 	if err != nil {
-		return fmt.Errorf("could not create user: %w", err)
+		return "", fmt.Errorf("could not add organization member: %w", err)
 	}
 
-	return nil
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("could not create user: %w", err)
+	}
+
+	return id, nil
 }
 
-func UpdateUsername(ctx context.Context, user model.UpdateUsername) error {
-	_, err := Db.ExecContext(ctx, `
+func UpdateUsername(ctx context.Context, conn *sql.DB, user model.UpdateUsername) error {
+	_, err := conn.ExecContext(ctx, `
 	UPDATE users
 		SET
 		username = $2
@@ -182,6 +585,9 @@ func UpdateUsername(ctx context.Context, user model.UpdateUsername) error {
 		user.NewUsername,
 	)
 
+	if isUniqueViolation(err) {
+		return ErrUsernameTaken
+	}
 	if err != nil {
 		return fmt.Errorf("could not update username: %w", err)
 	}
@@ -189,16 +595,19 @@ func UpdateUsername(ctx context.Context, user model.UpdateUsername) error {
 	return nil
 }
 
-func UpdatePassword(ctx context.Context, user model.UpdatePassword) error {
-	hashedPassword, pswderr := utils.HashPassword(user.NewPassword)
+// UpdatePassword sets a user's password and ends their sessions, so tokens
+// issued under the old password stop working.
+func UpdatePassword(ctx context.Context, conn *sql.DB, user model.UpdatePassword) error {
+	hashedPassword, pswderr := utils.HashPasswordContext(ctx, user.NewPassword)
 	if pswderr != nil {
 		return fmt.Errorf("could not hash user password: %w", pswderr)
 	}
 
-	_, err := Db.ExecContext(ctx, `
+	_, err := conn.ExecContext(ctx, `
 	UPDATE users
 		SET
-		password = $2
+		password = $2,
+		tokens_valid_after = now()
 		WHERE
 		id = $1`,
 		user.ID,
@@ -212,8 +621,8 @@ func UpdatePassword(ctx context.Context, user model.UpdatePassword) error {
 	return nil
 }
 
-func DeleteUser(ctx context.Context, username string) error {
-	_, err := Db.ExecContext(ctx, `DELETE FROM users WHERE username = $1;`,
+func DeleteUser(ctx context.Context, conn *sql.DB, username string) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM users WHERE username = $1;`,
 		username,
 	)
 
@@ -224,8 +633,11 @@ func DeleteUser(ctx context.Context, username string) error {
 	return nil
 }
 
-func Authenticate(user *model.User) (bool, error) {
-	rows, err := Db.Query(`SELECT password FROM users
+// Authenticate checks a user's password. When it is correct but the stored
+// hash was made under an older hashing policy, the password is hashed again
+// with the current policy while we have it in plain text.
+func Authenticate(ctx context.Context, conn *sql.DB, user model.InputUser) (bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT password FROM users
 	WHERE username = $1;`, user.Username)
 
 	if err != nil {
@@ -238,5 +650,45 @@ func Authenticate(user *model.User) (bool, error) {
 		return false, fmt.Errorf("could not not get player rows: %w", err)
 	}
 
-	return utils.CheckPasswordHash(user.Password, dbHashedPassword), nil
+	correct, err := utils.CheckPasswordHashContext(ctx, user.Password, dbHashedPassword)
+	if err != nil {
+		return false, fmt.Errorf("could not check password: %w", err)
+	}
+	if !correct {
+		return false, nil
+	}
+
+	if utils.NeedsRehash(dbHashedPassword) {
+		if err := rehashPassword(ctx, conn, user, dbHashedPassword); err != nil {
+			log.Printf("could not rehash password for %s: %v", user.Username, err)
+		}
+	}
+
+	return true, nil
+}
+
+func rehashPassword(ctx context.Context, conn *sql.DB, user model.InputUser, oldHash string) error {
+	hashedPassword, err := utils.HashPasswordContext(ctx, user.Password)
+	if err != nil {
+		return fmt.Errorf("could not hash user password: %w", err)
+	}
+
+	// Only replace the hash we verified against, in case the password was
+	// changed in the meantime
+	_, err = conn.ExecContext(ctx, `
+	UPDATE users
+		SET
+		password = $3
+		WHERE
+		username = $1 AND password = $2`,
+		user.Username,
+		oldHash,
+		hashedPassword,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not update user password: %w", err)
+	}
+
+	return nil
 }
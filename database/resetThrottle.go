@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// ResetAccountLimit reset mails are sent to one account per window
+	ResetAccountLimit = 3
+	// ResetIpLimit resets can be requested from one address per window
+	ResetIpLimit = 10
+	// ResetWindow is how long a reset request counts against a key
+	ResetWindow = time.Hour
+)
+
+// ResetThrottledError is returned when an address has requested too many
+// password resets.
+type ResetThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e *ResetThrottledError) Error() string {
+	retry := e.RetryAfter.Round(time.Second)
+	if retry < time.Second {
+		retry = time.Second
+	}
+
+	return fmt.Sprintf("too many password reset requests, try again in %s", retry)
+}
+
+// ReservePasswordReset counts a reset request against the address and the
+// username, whether or not the account exists. It returns a
+// ResetThrottledError when the address is over ResetIpLimit, and false when
+// the account has been sent ResetAccountLimit mails in the window, so callers
+// answer the same for an account that was throttled and one that does not
+// exist.
+func ReservePasswordReset(ctx context.Context, conn *sql.DB, username string, ip string) (bool, error) {
+	_, err := conn.ExecContext(ctx, `DELETE FROM password_reset_requests
+	WHERE window_start < now() - make_interval(secs => $1)`,
+		ResetWindow.Seconds(),
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("could not purge password reset requests: %w", err)
+	}
+
+	if ip != "" {
+		requests, windowStart, err := countResetRequest(ctx, conn, ipKey(ip))
+		if err != nil {
+			return false, err
+		}
+		if requests > ResetIpLimit {
+			return false, &ResetThrottledError{RetryAfter: time.Until(windowStart.Add(ResetWindow))}
+		}
+	}
+
+	requests, _, err := countResetRequest(ctx, conn, accountKey(username))
+	if err != nil {
+		return false, err
+	}
+
+	return requests <= ResetAccountLimit, nil
+}
+
+// countResetRequest counts a request against key, starting a new window once
+// the last one has passed, and returns the requests in the window.
+func countResetRequest(ctx context.Context, conn *sql.DB, key string) (int, time.Time, error) {
+	var requests int
+	var windowStart time.Time
+	err := conn.QueryRowContext(ctx, `INSERT INTO password_reset_requests (key, requests, window_start)
+	VALUES ($1, 1, now())
+	ON CONFLICT (key) DO UPDATE SET
+		requests = CASE WHEN password_reset_requests.window_start < now() - make_interval(secs => $2)
+			THEN 1 ELSE password_reset_requests.requests + 1 END,
+		window_start = CASE WHEN password_reset_requests.window_start < now() - make_interval(secs => $2)
+			THEN now() ELSE password_reset_requests.window_start END
+	RETURNING requests, window_start`,
+		key,
+		ResetWindow.Seconds(),
+	).Scan(&requests, &windowStart)
+
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("could not count password reset request: %w", err)
+	}
+
+	return requests, windowStart, nil
+}
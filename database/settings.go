@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// Settings keys
+const (
+	SettingRequireTwoFactor = "require_two_factor_privileged"
+	SettingRegistrationMode = "registration_mode"
+)
+
+func GetSetting(ctx context.Context, conn *sql.DB, key string) (string, error) {
+	var value string
+
+	err := conn.QueryRowContext(ctx, `SELECT value FROM settings
+	WHERE key = $1;`, key).Scan(&value)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not get setting %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+func SetSetting(ctx context.Context, conn *sql.DB, key string, value string) error {
+	_, err := conn.ExecContext(ctx, `INSERT INTO settings (key, value)
+	VALUES ($1, $2)
+	ON CONFLICT (key) DO UPDATE SET value = $2`,
+		key,
+		value,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not set setting %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// RequireTwoFactor reports whether privileged roles must use two factor
+// authentication. It is off until an admin turns it on.
+func RequireTwoFactor(ctx context.Context, conn *sql.DB) (bool, error) {
+	value, err := GetSetting(ctx, conn, SettingRequireTwoFactor)
+	if err != nil || value == "" {
+		return false, err
+	}
+
+	return strconv.ParseBool(value)
+}
+
+// GetRegistrationMode returns who may create an account. Registration is open
+// until an admin changes it.
+func GetRegistrationMode(ctx context.Context, conn *sql.DB) (model.RegistrationMode, error) {
+	value, err := GetSetting(ctx, conn, SettingRegistrationMode)
+	if err != nil {
+		return "", err
+	}
+
+	mode := model.RegistrationMode(value)
+	if !mode.IsValid() {
+		return model.RegistrationModeOpen, nil
+	}
+
+	return mode, nil
+}
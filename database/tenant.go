@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// withOrg runs fn in a transaction that can only see and write the players of
+// one organization. The transaction switches to app_user, which is subject to
+// the row level security policy on players, and sets app.org_id for it.
+func withOrg(ctx context.Context, conn *sql.DB, orgId string, fn func(tx *sql.Tx) error) error {
+	if orgId == "" {
+		return fmt.Errorf("no organization")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SET LOCAL ROLE app_user`); err != nil {
+		return fmt.Errorf("could not set role: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `SELECT set_config('app.org_id', $1, true)`, orgId); err != nil {
+		return fmt.Errorf("could not set organization: %w", err)
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// ErrPlayerNameTaken is returned when a player cannot be restored because
+// another player has taken its name in the meantime.
+var ErrPlayerNameTaken = apperr.New(apperr.Conflict, "a player with this name already exists")
+
+// GetDeletedPlayers returns the players of an organization that are in the
+// trash, most recently deleted first.
+func GetDeletedPlayers(ctx context.Context, conn *sql.DB, orgId string) ([]*model.Player, error) {
+	var players []*model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+		WHERE deleted_at IS NOT NULL
+		ORDER BY deleted_at DESC;`)
+
+		if err != nil {
+			return fmt.Errorf("could not get deleted players: %w", err)
+		}
+
+		players, err = getPlayerListRows(rows)
+
+		if err != nil {
+			return fmt.Errorf("could not not get player rows: %w", err)
+		}
+
+		return nil
+	})
+
+	return players, err
+}
+
+// RestorePlayer takes a player out of the trash.
+func RestorePlayer(ctx context.Context, conn *sql.DB, orgId string, actorId string, id string) (*model.Player, error) {
+	var restored *model.Player
+
+	err := withOrg(ctx, conn, orgId, func(tx *sql.Tx) error {
+		rows, err := tx.QueryContext(ctx, `SELECT `+playerColumns+` FROM players
+		WHERE id = $1 AND deleted_at IS NOT NULL
+		FOR UPDATE;`, id)
+
+		if err != nil {
+			return fmt.Errorf("could not get player: %w", err)
+		}
+
+		deleted, err := getRows(rows, "")
+
+		if err != nil {
+			return fmt.Errorf("could not not get player rows: %w", err)
+		}
+		if deleted == nil {
+			return ErrPlayerNotFound
+		}
+
+		var taken bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (
+			SELECT 1 FROM players WHERE name = $1 AND deleted_at IS NULL
+		)`, deleted.Name).Scan(&taken)
+
+		if err != nil {
+			return fmt.Errorf("could not get player: %w", err)
+		}
+		if taken {
+			return ErrPlayerNameTaken
+		}
+
+		rows, err = tx.QueryContext(ctx, `
+		UPDATE players
+			SET
+			deleted_at = NULL,
+			deleted_by = NULL,
+			version = version + 1
+			WHERE
+			id = $1
+			RETURNING `+playerColumns,
+			id,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not restore player: %w", err)
+		}
+
+		restored, err = getRows(rows, "")
+
+		if err != nil {
+			return fmt.Errorf("could not not get player rows: %w", err)
+		}
+
+		return insertPlayerAudit(ctx, tx, orgId, actorId, model.AuditActionRestore, nil, restored)
+	})
+
+	return restored, err
+}
+
+// PurgePlayers deletes players that have been in the trash for longer than
+// retention, in every organization, and audits each purge. It does not go
+// through withOrg.
+func PurgePlayers(ctx context.Context, conn *sql.DB, retention time.Duration) (int64, error) {
+	result, err := conn.ExecContext(ctx, `WITH purged AS (
+		DELETE FROM players
+		WHERE deleted_at < now() - $1 * interval '1 second'
+		RETURNING id, org_id, name
+	)
+	INSERT INTO player_audit (org_id, player_id, player_name, action, changes)
+	SELECT org_id, id, name, $2, '[]' FROM purged`,
+		retention.Seconds(),
+		model.AuditActionPurge,
+	)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not purge players: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// StartPlayerPurge purges the trash in the background. PLAYER_TRASH_RETENTION
+// sets how long deleted players are kept, 30 days by default, and
+// PLAYER_PURGE_INTERVAL how often the trash is checked, every hour by default.
+// Running it on several instances at once is harmless. It stops once ctx is
+// done, cancelling a purge that is under way.
+func StartPlayerPurge(ctx context.Context, conn *sql.DB) {
+	retention := durationFromEnv("PLAYER_TRASH_RETENTION", 30*24*time.Hour)
+	interval := durationFromEnv("PLAYER_PURGE_INTERVAL", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			purged, err := PurgePlayers(ctx, conn, retention)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("could not purge deleted players: %v", err)
+			} else if purged > 0 {
+				log.Printf("purged %d deleted players", purged)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
@@ -0,0 +1,261 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// MaxChallengeAttempts attempts use up a login challenge
+const MaxChallengeAttempts = 5
+
+// SetTotpSecret stores a new secret for a user that has not enabled two factor
+// authentication yet. It returns false if two factor authentication is
+// already enabled, it has to be disabled before enrolling again.
+func SetTotpSecret(ctx context.Context, conn *sql.DB, userId string, secret string) (bool, error) {
+	result, err := conn.ExecContext(ctx, `
+	UPDATE users
+		SET
+		totp_secret = $2,
+		totp_last_step = NULL
+		WHERE
+		id = $1 AND NOT totp_enabled`,
+		userId,
+		secret,
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("could not set totp secret: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not set totp secret: %w", err)
+	}
+
+	return updated == 1, nil
+}
+
+// GetTotp returns a user's totp secret, empty if they never enrolled, and
+// whether it has been confirmed.
+func GetTotp(ctx context.Context, conn *sql.DB, userId string) (string, bool, error) {
+	var secret sql.NullString
+	var enabled bool
+
+	err := conn.QueryRowContext(ctx, `SELECT totp_secret, totp_enabled FROM users
+	WHERE id = $1;`, userId).Scan(&secret, &enabled)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("could not get totp: %w", err)
+	}
+
+	return secret.String, enabled, nil
+}
+
+// UseTotpStep records the time step of an accepted code. It returns false if
+// a code from this or a later step was already used, so a code that was seen
+// cannot be replayed.
+func UseTotpStep(ctx context.Context, conn *sql.DB, userId string, step int64) (bool, error) {
+	result, err := conn.ExecContext(ctx, `
+	UPDATE users
+		SET
+		totp_last_step = $2
+		WHERE
+		id = $1 AND (totp_last_step IS NULL OR totp_last_step < $2)`,
+		userId,
+		step,
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("could not use totp step: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not use totp step: %w", err)
+	}
+
+	return updated == 1, nil
+}
+
+// EnableTotp turns on two factor authentication once the user confirmed a
+// code, replacing any recovery codes with new ones.
+func EnableTotp(ctx context.Context, conn *sql.DB, userId string, step int64, codeHashes []string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE users
+		SET
+		totp_enabled = true,
+		totp_last_step = $2
+		WHERE
+		id = $1`,
+		userId,
+		step,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not enable totp: %w", err)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DisableTotp turns off two factor authentication and drops the secret and
+// recovery codes.
+func DisableTotp(ctx context.Context, conn *sql.DB, userId string) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE users
+		SET
+		totp_enabled = false,
+		totp_secret = NULL,
+		totp_last_step = NULL
+		WHERE
+		id = $1`,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not disable totp: %w", err)
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userId, nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sql.Tx, userId string, codeHashes []string) error {
+	_, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1;`,
+		userId,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not delete recovery codes: %w", err)
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes (
+			user_id,
+			code_hash
+		)
+		VALUES ($1, $2)`,
+			userId,
+			codeHash,
+		)
+
+		if err != nil {
+			return fmt.Errorf("could not create recovery code: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode uses up a recovery code. It returns false if the code is
+// unknown or was used before.
+func UseRecoveryCode(ctx context.Context, conn *sql.DB, userId string, codeHash string) (bool, error) {
+	result, err := conn.ExecContext(ctx, `
+	UPDATE recovery_codes
+		SET
+		used_at = now()
+		WHERE
+		user_id = $1 AND code_hash = $2 AND used_at IS NULL`,
+		userId,
+		codeHash,
+	)
+
+	if err != nil {
+		return false, fmt.Errorf("could not use recovery code: %w", err)
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("could not use recovery code: %w", err)
+	}
+
+	return updated == 1, nil
+}
+
+// CreateLoginChallenge stores the challenge handed out by the first login step
+// of a user with two factor authentication.
+func CreateLoginChallenge(ctx context.Context, conn *sql.DB, userId string, tokenHash string, expiresAt time.Time) error {
+	_, err := conn.ExecContext(ctx, `INSERT INTO login_challenges (
+		token_hash,
+		user_id,
+		expires_at
+	)
+	VALUES ($1, $2, $3)`,
+		tokenHash,
+		userId,
+		expiresAt,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not create login challenge: %w", err)
+	}
+
+	return nil
+}
+
+// ReserveChallengeAttempt counts an attempt at a challenge and returns the
+// user it was issued to, or an empty string if it is unknown, expired or used
+// up. The attempt is counted in the same statement that checks the limit,
+// before the code is verified, so codes sent at once cannot get past it.
+func ReserveChallengeAttempt(ctx context.Context, conn *sql.DB, tokenHash string) (string, error) {
+	var userId string
+
+	err := conn.QueryRowContext(ctx, `
+	UPDATE login_challenges
+		SET
+		attempts = attempts + 1
+		WHERE
+		token_hash = $1 AND expires_at > now() AND attempts < $2
+		RETURNING user_id`,
+		tokenHash,
+		MaxChallengeAttempts,
+	).Scan(&userId)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not update login challenge: %w", err)
+	}
+
+	return userId, nil
+}
+
+// DeleteLoginChallenge removes a challenge once it has been answered, along
+// with any challenges that have expired.
+func DeleteLoginChallenge(ctx context.Context, conn *sql.DB, tokenHash string) error {
+	_, err := conn.ExecContext(ctx, `DELETE FROM login_challenges
+	WHERE token_hash = $1 OR expires_at < now();`,
+		tokenHash,
+	)
+
+	if err != nil {
+		return fmt.Errorf("could not delete login challenge: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// Admin user management audit events
+const (
+	AuditUserDisabled = "user_disabled"
+	AuditUserEnabled  = "user_enabled"
+	AuditRoleChanged  = "role_changed"
+	AuditForcedLogout = "forced_logout"
+)
+
+// UserStatus is what AuthMiddleware needs to know about a token's user beyond
+// the token itself.
+type UserStatus struct {
+	Exists   bool
+	Disabled bool
+	// TokensValidAfter is set when the user's sessions were ended, tokens
+	// issued up to then are rejected
+	TokensValidAfter time.Time
+}
+
+func GetUserStatus(ctx context.Context, conn *sql.DB, id string) (*UserStatus, error) {
+	var disabled bool
+	var validAfter sql.NullTime
+
+	err := conn.QueryRowContext(ctx, `SELECT disabled, tokens_valid_after FROM users
+	WHERE id = $1;`, id).Scan(&disabled, &validAfter)
+
+	if err == sql.ErrNoRows {
+		return &UserStatus{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not get user status: %w", err)
+	}
+
+	return &UserStatus{Exists: true, Disabled: disabled, TokensValidAfter: validAfter.Time}, nil
+}
+
+// GetUsers returns up to first users ordered by username, starting after the
+// username after, and whether there are more.
+func GetUsers(ctx context.Context, conn *sql.DB, filter *model.UserFilter, first int, after string) ([]*model.User, bool, error) {
+	conditions := []string{"username > $1"}
+	args := []interface{}{after}
+
+	if filter != nil {
+		if filter.Username != nil {
+			args = append(args, "%"+escapeLike(*filter.Username)+"%")
+			conditions = append(conditions, fmt.Sprintf("username ILIKE $%d", len(args)))
+		}
+		if filter.Role != nil {
+			args = append(args, *filter.Role)
+			conditions = append(conditions, fmt.Sprintf("role = $%d", len(args)))
+		}
+		if filter.Disabled != nil {
+			args = append(args, *filter.Disabled)
+			conditions = append(conditions, fmt.Sprintf("disabled = $%d", len(args)))
+		}
+	}
+
+	// One extra row tells us whether there is another page
+	args = append(args, first+1)
+	rows, err := conn.QueryContext(ctx, `SELECT `+userColumns+` FROM users
+	WHERE `+strings.Join(conditions, " AND ")+`
+	ORDER BY username
+	LIMIT $`+fmt.Sprint(len(args)), args...)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("could not get users: %w", err)
+	}
+
+	users, err := getUserListRows(rows)
+
+	if err != nil {
+		return nil, false, fmt.Errorf("could not not get user rows: %w", err)
+	}
+
+	if len(users) > first {
+		return users[:first], true, nil
+	}
+
+	return users, false, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// SetUserDisabled disables or enables an account and audits who did it.
+// Disabling also ends the user's sessions. It returns the user's id, empty if
+// there is no such user.
+func SetUserDisabled(ctx context.Context, conn *sql.DB, username string, disabled bool, actorId string) (string, error) {
+	var id string
+	err := conn.QueryRowContext(ctx, `
+	UPDATE users
+		SET
+		disabled = $2,
+		tokens_valid_after = CASE WHEN $2 THEN now() ELSE tokens_valid_after END
+		WHERE
+		username = $1
+		RETURNING id`,
+		username,
+		disabled,
+	).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not update user: %w", err)
+	}
+
+	event := AuditUserEnabled
+	if disabled {
+		event = AuditUserDisabled
+	}
+
+	return id, InsertAuthAudit(ctx, conn, event, username, "", actorId)
+}
+
+// SetUserRole changes a user's role and ends their sessions, since their
+// tokens still carry the old role.
+func SetUserRole(ctx context.Context, conn *sql.DB, username string, role model.Role, actorId string) (string, error) {
+	var id string
+	err := conn.QueryRowContext(ctx, `
+	UPDATE users
+		SET
+		role = $2,
+		tokens_valid_after = now()
+		WHERE
+		username = $1
+		RETURNING id`,
+		username,
+		role,
+	).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not update user role: %w", err)
+	}
+
+	return id, InsertAuthAudit(ctx, conn, AuditRoleChanged, username, "", actorId)
+}
+
+// ForceLogout ends every session of a user by rejecting the tokens issued so
+// far.
+func ForceLogout(ctx context.Context, conn *sql.DB, username string, actorId string) (string, error) {
+	var id string
+	err := conn.QueryRowContext(ctx, `
+	UPDATE users
+		SET
+		tokens_valid_after = now()
+		WHERE
+		username = $1
+		RETURNING id`,
+		username,
+	).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("could not end sessions: %w", err)
+	}
+
+	return id, InsertAuthAudit(ctx, conn, AuditForcedLogout, username, "", actorId)
+}
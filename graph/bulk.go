@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+	nat "github.com/mattmazer1/graphql-api/nats"
+	"github.com/mattmazer1/graphql-api/repository"
+)
+
+// maxBulkItems keeps a bulk mutation to a transaction of reasonable size
+const maxBulkItems = 500
+
+func requireBulk(ctx context.Context, n int) (*auth.Membership, error) {
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 || n > maxBulkItems {
+		return nil, apperr.Errorf(apperr.Validation, "a bulk mutation takes between 1 and %d players", maxBulkItems)
+	}
+
+	return member, nil
+}
+
+// bulkPayload reports the result of every item. When publishOrg is set, the
+// players a committed batch wrote are published to its subscribers in one
+// message.
+func bulkPayload(publishOrg string, results []repository.BulkResult, committed bool) *model.BulkPlayerPayload {
+	payload := &model.BulkPlayerPayload{
+		Results:   make([]*model.BulkPlayerResult, len(results)),
+		Committed: committed,
+	}
+	written := []*model.Player{}
+
+	for i, result := range results {
+		item := &model.BulkPlayerResult{Index: i}
+		payload.Results[i] = item
+
+		if result.Err == nil {
+			item.Player = result.Player
+			payload.Succeeded++
+			written = append(written, result.Player)
+			continue
+		}
+
+		payload.Failed++
+		message, current := bulkItemError(i, result.Err)
+		item.Error = &message
+		item.Current = current
+		item.Fields = fieldErrors(result.Err)
+	}
+
+	if publishOrg != "" && committed {
+		publishPlayers(publishOrg, written)
+	}
+
+	return payload
+}
+
+// bulkItemError returns the message for an item that failed and, for a
+// version conflict, the player as it is now. Errors the client can act on are
+// passed on, anything else is logged and reported as an internal error.
+func bulkItemError(i int, err error) (string, *model.Player) {
+	var conflict *db.VersionConflictError
+	if errors.As(err, &conflict) {
+		return conflict.Error(), conflict.Current
+	}
+	if appErr := appError(err); appErr != nil {
+		return appErr.Message, nil
+	}
+
+	log.Printf("bulk player item %d failed: %v", i, err)
+	return "internal error", nil
+}
+
+// publishPlayers sends the players a batch wrote to the organization's
+// subscribers in one message.
+func publishPlayers(orgId string, players []*model.Player) {
+	if len(players) == 0 {
+		return
+	}
+
+	playersJSON, err := json.Marshal(players)
+	if err != nil {
+		log.Printf("could not marshal written players: %v", err)
+		return
+	}
+
+	nat.Nc.Publish(playerBatchSubject(orgId), playersJSON)
+}
@@ -0,0 +1,16 @@
+package graph
+
+import (
+	"github.com/mattmazer1/graphql-api/apperr"
+	db "github.com/mattmazer1/graphql-api/database"
+)
+
+// conflictError is a CONFLICT error that carries the player as it is now, so
+// the client can merge its change and retry with the current version.
+func conflictError(conflict *db.VersionConflictError) *apperr.Error {
+	return &apperr.Error{
+		Code:       apperr.Conflict,
+		Message:    conflict.Error(),
+		Extensions: map[string]interface{}{"current": conflict.Current},
+	}
+}
@@ -0,0 +1,17 @@
+package graph
+
+import (
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/playercsv"
+)
+
+// maxImportRows keeps a CSV import to a transaction of reasonable size
+const maxImportRows = 5000
+
+func csvRowError(rowError playercsv.RowError) *model.CSVRowError {
+	result := &model.CSVRowError{Row: rowError.Row, Message: rowError.Message}
+	if rowError.Column != "" {
+		result.Column = &rowError.Column
+	}
+	return result
+}
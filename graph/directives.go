@@ -0,0 +1,29 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+)
+
+// Private implements the @private directive. The field resolves only when the
+// caller is the user that owns the object or an admin, and is null otherwise.
+func Private(ctx context.Context, obj interface{}, next graphql.Resolver) (interface{}, error) {
+	owner, ok := obj.(model.UserInfo)
+	if !ok {
+		return nil, nil
+	}
+
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, nil
+	}
+
+	if user.ID != owner.GetID() && user.Role != model.RoleAdmin {
+		return nil, nil
+	}
+
+	return next(ctx)
+}
@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/mattmazer1/graphql-api/apperr"
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/utils"
+	"github.com/mattmazer1/graphql-api/validate"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// hashRetryAfter is the number of seconds clients are told to wait when the
+// password hashing queue is full, which drains within about a second.
+const hashRetryAfter = 1
+
+// ErrorPresenter puts the code of an apperr.Error in extensions.code. Errors
+// without a code are logged and reach the client as INTERNAL, so SQL and
+// other internal details never do.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	appErr := appError(err)
+	if appErr != nil {
+		extensions := map[string]interface{}{"code": appErr.Code}
+		for key, value := range appErr.Extensions {
+			extensions[key] = value
+		}
+		return &gqlerror.Error{
+			Message:    appErr.Message,
+			Path:       graphql.GetPath(ctx),
+			Extensions: extensions,
+		}
+	}
+
+	// Errors gqlgen raises itself, such as an argument that does not coerce,
+	// are about the request and are shown as they are. One wrapping another
+	// error, such as an unmarshaler's, could carry internal details in its
+	// message and is treated like that error.
+	var gqlErr *gqlerror.Error
+	if errors.As(err, &gqlErr) && errors.Unwrap(gqlErr) == nil {
+		if gqlErr.Extensions == nil {
+			gqlErr.Extensions = map[string]interface{}{}
+		}
+		if _, ok := gqlErr.Extensions["code"]; !ok {
+			gqlErr.Extensions["code"] = apperr.Validation
+		}
+		return gqlErr
+	}
+
+	log.Printf("internal error at %s: %v", graphql.GetPath(ctx), err)
+
+	return &gqlerror.Error{
+		Message:    "internal error",
+		Path:       graphql.GetPath(ctx),
+		Extensions: map[string]interface{}{"code": apperr.Internal},
+	}
+}
+
+// appError finds the error with a code in err's chain, including the domain
+// errors that are turned into one here.
+func appError(err error) *apperr.Error {
+	var appErr *apperr.Error
+	var invalid *validate.Error
+	var conflict *db.VersionConflictError
+	var throttled *db.LoginThrottledError
+	var resetThrottled *db.ResetThrottledError
+	switch {
+	case errors.Is(err, utils.ErrHashOverloaded):
+		return &apperr.Error{
+			Code:       apperr.Unavailable,
+			Message:    utils.ErrHashOverloaded.Message,
+			Extensions: map[string]interface{}{"retryAfter": hashRetryAfter},
+		}
+	case errors.As(err, &appErr):
+		return appErr
+	case errors.As(err, &invalid):
+		return validationError(invalid, "")
+	case errors.As(err, &conflict):
+		return conflictError(conflict)
+	case errors.As(err, &throttled):
+		return &apperr.Error{
+			Code:       apperr.Forbidden,
+			Message:    throttled.Error(),
+			Extensions: map[string]interface{}{"retryAfter": int(math.Ceil(throttled.RetryAfter.Seconds()))},
+		}
+	case errors.As(err, &resetThrottled):
+		return &apperr.Error{
+			Code:       apperr.Forbidden,
+			Message:    resetThrottled.Error(),
+			Extensions: map[string]interface{}{"retryAfter": int(math.Ceil(resetThrottled.RetryAfter.Seconds()))},
+		}
+	default:
+		return nil
+	}
+}
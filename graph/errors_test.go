@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/utils"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+func TestErrorPresenter(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantCode    apperr.Code
+		wantMessage string
+	}{
+		{"app error", apperr.New(apperr.NotFound, "player Ada not found"), apperr.NotFound, "player Ada not found"},
+		{"wrapped app error", fmt.Errorf("could not get player: %w", apperr.ErrForbidden), apperr.Forbidden, "access denied"},
+		{"request error", gqlerror.Errorf("unknown field"), apperr.Validation, "unknown field"},
+		{"request error wrapping an internal one", gqlerror.WrapPath(nil, errors.New("pq: connection refused")), apperr.Internal, "internal error"},
+		{"internal error", errors.New("pq: connection refused"), apperr.Internal, "internal error"},
+		{"hash queue full", fmt.Errorf("could not hash user password: %w", utils.ErrHashOverloaded), apperr.Unavailable, utils.ErrHashOverloaded.Message},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ErrorPresenter(context.Background(), tt.err)
+			if got.Extensions["code"] != tt.wantCode || got.Message != tt.wantMessage {
+				t.Fatalf("got %s %q, want %s %q", got.Extensions["code"], got.Message, tt.wantCode, tt.wantMessage)
+			}
+		})
+	}
+
+	got := ErrorPresenter(context.Background(), utils.ErrHashOverloaded)
+	if got.Extensions["retryAfter"] != hashRetryAfter {
+		t.Fatalf("got extensions %v, want retryAfter %d", got.Extensions, hashRetryAfter)
+	}
+}
@@ -0,0 +1,55 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+// loginResult issues the token for a user that passed every login step, for
+// the organization they joined first. When two factor authentication is
+// mandatory for privileged roles and the user has not set it up, the token
+// only carries the user role and the viewer role in the organization until
+// they do.
+func (r *Resolver) loginResult(ctx context.Context, user *model.User) (*model.LoginResult, error) {
+	orgId, orgRole, err := r.OrgRepo.GetDefaultMembership(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.issueToken(ctx, user, orgId, orgRole)
+}
+
+func (r *Resolver) issueToken(ctx context.Context, user *model.User, orgId string, orgRole model.OrgRole) (*model.LoginResult, error) {
+	role := user.Role
+	setupRequired := false
+
+	if isPrivileged(role, orgRole) && (user.TwoFactorEnabled == nil || !*user.TwoFactorEnabled) {
+		required, err := r.SettingsRepo.RequireTwoFactor(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if required {
+			role = model.RoleUser
+			if orgRole != "" {
+				orgRole = model.OrgRoleViewer
+			}
+			setupRequired = true
+		}
+	}
+
+	token, err := utils.GenerateToken(utils.TokenUser{
+		ID:             user.ID,
+		Username:       user.Username,
+		Role:           string(role),
+		Org:            orgId,
+		OrgRole:        string(orgRole),
+		TwoFactorSetup: setupRequired,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.LoginResult{Token: &token, TwoFactorSetupRequired: setupRequired}, nil
+}
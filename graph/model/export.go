@@ -0,0 +1,48 @@
+package model
+
+import "time"
+
+// UserExport is everything stored about a user, as returned by exportMyData.
+type UserExport struct {
+	ExportedAt    time.Time          `json:"exportedAt"`
+	Account       *User              `json:"account"`
+	Organizations []*Organization    `json:"organizations"`
+	Sessions      ExportSessions     `json:"sessions"`
+	Invitations   []ExportInvitation `json:"invitations"`
+	// AuthoredChanges are the changes the user made to other accounts and
+	// settings as an admin
+	AuthoredChanges []ExportAuditEvent `json:"authoredChanges"`
+	// SecurityEvents are the audit events about the user's own account
+	SecurityEvents []ExportAuditEvent `json:"securityEvents"`
+	// PlayerChanges are the changes the user made to players
+	PlayerChanges []*PlayerAuditEntry `json:"playerChanges"`
+}
+
+// ExportSessions describes the user's sessions. Tokens are not stored, so
+// this is what the server keeps about them.
+type ExportSessions struct {
+	TokensValidAfter *time.Time        `json:"tokensValidAfter"`
+	LoginChallenges  []ExportTimestamp `json:"loginChallenges"`
+	PasswordResets   []ExportTimestamp `json:"passwordResets"`
+	RecoveryCodes    int               `json:"unusedRecoveryCodes"`
+}
+
+type ExportTimestamp struct {
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+type ExportInvitation struct {
+	Role      string     `json:"role"`
+	Created   bool       `json:"createdByUser"`
+	CreatedAt time.Time  `json:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty"`
+}
+
+type ExportAuditEvent struct {
+	Event     string    `json:"event"`
+	Username  *string   `json:"username,omitempty"`
+	Ip        *string   `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -12,64 +12,328 @@ type UserInfo interface {
 	IsUserInfo()
 	GetID() string
 	GetUsername() string
-	GetPassword() string
+}
+
+type BulkPlayerPayload struct {
+	Results   []*BulkPlayerResult `json:"results"`
+	Succeeded int                 `json:"succeeded"`
+	Failed    int                 `json:"failed"`
+	// False when an atomic batch was rolled back because an item failed. The
+	// results of the other items then show what they would have written.
+	Committed bool `json:"committed"`
+}
+
+type BulkPlayerResult struct {
+	// Position of the item in the input list
+	Index int `json:"index"`
+	// The player as written, null when the item failed
+	Player *Player `json:"player"`
+	Error  *string `json:"error"`
+	// Every field of the item that failed validation
+	Fields []*FieldError `json:"fields"`
+	// The player as it is now, when the item failed with a version conflict
+	Current *Player `json:"current"`
+}
+
+type Career struct {
+	SeasonType SeasonType `json:"seasonType"`
+	// Number of seasons with a stored line
+	Seasons     int     `json:"seasons"`
+	FirstSeason *string `json:"firstSeason"`
+	LastSeason  *string `json:"lastSeason"`
+	// Games played over the stored lines, null when a line has no games played
+	GamesPlayed *int `json:"gamesPlayed"`
+	// Every line's per game values times its games played, added up. Null when
+	// a line has no games played.
+	Totals *StatLine `json:"totals"`
+	// Averages of the stored lines weighted by minutes played, so a season with
+	// more minutes counts for more. mp itself is the plain average.
+	Averages *StatLine `json:"averages"`
+	// Best value of every stat in any of the stored lines
+	CareerHighs []*StatHigh `json:"careerHighs"`
+	// Best value of every stat in the latest season, over all of its season types
+	SeasonHighs []*StatHigh `json:"seasonHighs"`
+}
+
+type CSVImportPayload struct {
+	// Players written, or that would have been for a batch that was rolled back
+	Imported int `json:"imported"`
+	// Rows that could not be imported, each counted once however many errors it has
+	Failed int `json:"failed"`
+	// False when an atomic import was rolled back because a row failed
+	Committed bool           `json:"committed"`
+	Errors    []*CSVRowError `json:"errors"`
+}
+
+type CSVRowError struct {
+	// Row in the file, the header is row 1
+	Row int `json:"row"`
+	// Column the error is about, null for errors about the whole row
+	Column  *string `json:"column"`
+	Message string  `json:"message"`
+}
+
+// A field that changed, with its values formatted as strings
+type FieldChange struct {
+	// Path of the field, such as age or stats.points
+	Field string `json:"field"`
+	// null when the player was created
+	Before *string `json:"before"`
+	// null when the player was deleted
+	After *string `json:"after"`
+}
+
+// A field of an input that failed validation
+type FieldError struct {
+	// Path of the field in the input, such as stats.mp
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+type InputDeletePlayer struct {
+	Name            string `json:"name"`
+	ExpectedVersion int    `json:"expectedVersion"`
 }
 
 type InputPlayer struct {
-	Pos        Position    `json:"pos"`
-	Name       string      `json:"name"`
-	Age        int         `json:"age"`
-	Experience int         `json:"experience"`
-	Stats      *InputStats `json:"stats"`
+	// Required unless primaryPosition is set, which it then has to be the group of
+	Pos             *Position       `json:"pos"`
+	PrimaryPosition *LineupPosition `json:"primaryPosition"`
+	// Has to include the primary position. Defaults to the primary position, or
+	// to the positions of pos for players without one
+	EligiblePositions []LineupPosition `json:"eligiblePositions"`
+	Name              string           `json:"name"`
+	// Required unless profile.birthDate is set
+	Age *int `json:"age"`
+	// Required unless profile.draftYear or profile.debutSeason is set
+	Experience *int          `json:"experience"`
+	Profile    *InputProfile `json:"profile"`
+	Stats      *InputStats   `json:"stats"`
+}
+
+type InputProfile struct {
+	BirthDate    *string     `json:"birthDate"`
+	Height       *int        `json:"height"`
+	Weight       *int        `json:"weight"`
+	College      *string     `json:"college"`
+	Country      *string     `json:"country"`
+	DraftYear    *int        `json:"draftYear"`
+	DraftRound   *int        `json:"draftRound"`
+	DraftPick    *int        `json:"draftPick"`
+	DebutSeason  *string     `json:"debutSeason"`
+	JerseyNumber *int        `json:"jerseyNumber"`
+	Handedness   *Handedness `json:"handedness"`
 }
 
+// A stat line, stored under its season and season type next to the player's
+// other lines. Writing a line that exists overwrites it.
 type InputStats struct {
-	Season    string  `json:"season"`
-	Points    float64 `json:"points"`
-	ThreePt   float64 `json:"threePt"`
-	Rebounds  float64 `json:"rebounds"`
-	Assists   float64 `json:"assists"`
-	Steals    float64 `json:"steals"`
-	Blocks    float64 `json:"blocks"`
-	TurnOvers float64 `json:"turnOvers"`
-	Mp        float64 `json:"mp"`
+	Season     string     `json:"season"`
+	SeasonType SeasonType `json:"seasonType"`
+	Points     float64    `json:"points"`
+	ThreePt    float64    `json:"threePt"`
+	Rebounds   float64    `json:"rebounds"`
+	Assists    float64    `json:"assists"`
+	Steals     float64    `json:"steals"`
+	Blocks     float64    `json:"blocks"`
+	TurnOvers  float64    `json:"turnOvers"`
+	Mp         float64    `json:"mp"`
+	// The stats are per game averages over this many games
+	GamesPlayed *int `json:"gamesPlayed"`
 }
 
 type InputUpdatePlayer struct {
-	Pos        *Position         `json:"pos"`
-	Name       *string           `json:"name"`
-	Age        *int              `json:"age"`
-	Experience *int              `json:"experience"`
-	Stats      *InputUpdateStats `json:"stats"`
+	// Clears primaryPosition unless it is in the group
+	Pos *Position `json:"pos"`
+	// Added to the eligible positions if they do not include it
+	PrimaryPosition *LineupPosition `json:"primaryPosition"`
+	// Replaces the eligible positions
+	EligiblePositions []LineupPosition `json:"eligiblePositions"`
+	Name              *string          `json:"name"`
+	Age               *int             `json:"age"`
+	Experience        *int             `json:"experience"`
+	// Fields that are not set keep their value
+	Profile *InputProfile `json:"profile"`
+	// Profile fields to clear. They are cleared before profile is applied, so a field in both takes its new value.
+	ClearProfile []ProfileField    `json:"clearProfile"`
+	Stats        *InputUpdateStats `json:"stats"`
 }
 
+// Fields that are not set are taken from the stored line for the season and
+// season type, or from the current line when there is none
 type InputUpdateStats struct {
-	Season    *string  `json:"season"`
-	Points    *float64 `json:"points"`
-	ThreePt   *float64 `json:"threePt"`
-	Rebounds  *float64 `json:"rebounds"`
-	Assists   *float64 `json:"assists"`
-	Steals    *float64 `json:"steals"`
-	Blocks    *float64 `json:"blocks"`
-	TurnOvers *float64 `json:"turnOvers"`
-	Mp        *float64 `json:"mp"`
+	Season      *string     `json:"season"`
+	SeasonType  *SeasonType `json:"seasonType"`
+	Points      *float64    `json:"points"`
+	ThreePt     *float64    `json:"threePt"`
+	Rebounds    *float64    `json:"rebounds"`
+	Assists     *float64    `json:"assists"`
+	Steals      *float64    `json:"steals"`
+	Blocks      *float64    `json:"blocks"`
+	TurnOvers   *float64    `json:"turnOvers"`
+	Mp          *float64    `json:"mp"`
+	GamesPlayed *int        `json:"gamesPlayed"`
 }
 
 type InputUser struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Username string  `json:"username"`
+	Password string  `json:"password"`
+	Email    *string `json:"email"`
+}
+
+type Invitation struct {
+	// Single use code to pass to createUser, only returned once
+	Code string `json:"code"`
+	// Role the invited user gets
+	Role Role `json:"role"`
+	// Organization the invited user joins
+	OrganizationID string `json:"organizationId"`
+	// Role the invited user gets in the organization
+	OrgRole   OrgRole `json:"orgRole"`
+	ExpiresAt string  `json:"expiresAt"`
+}
+
+// Result of the first login step. Without two factor authentication token is
+// set straight away. With it, challenge is set and has to be passed to
+// verifyTwoFactor together with a code to get the token.
+type LoginResult struct {
+	Token             *string `json:"token"`
+	Challenge         *string `json:"challenge"`
+	TwoFactorRequired bool    `json:"twoFactorRequired"`
+	// Set when the account has a privileged role but two factor authentication is
+	// mandatory for it and not set up yet. The token only carries the user role
+	// until enrollment is confirmed.
+	TwoFactorSetupRequired bool `json:"twoFactorSetupRequired"`
+}
+
+type Organization struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// Your role in the organization, null for admins that are not members
+	Role *OrgRole `json:"role"`
+}
+
+type OrganizationMember struct {
+	UserID   string  `json:"userId"`
+	Username string  `json:"username"`
+	Role     OrgRole `json:"role"`
+}
+
+type PageInfo struct {
+	// Pass as after to get the next page
+	EndCursor   *string `json:"endCursor"`
+	HasNextPage bool    `json:"hasNextPage"`
 }
 
 type Player struct {
-	Pos        Position `json:"pos"`
-	Name       string   `json:"name"`
-	Age        int      `json:"age"`
+	ID string `json:"id"`
+	// Group of the primary position, or the group stored for players without one
+	Pos Position `json:"pos"`
+	// Null for players stored with only a position group
+	PrimaryPosition *LineupPosition `json:"primaryPosition"`
+	// Positions the player can play, the primary one first
+	EligiblePositions []LineupPosition `json:"eligiblePositions"`
+	Name              string           `json:"name"`
+	// Today's age from the birth date, or the age stored for players without one
+	Age int `json:"age"`
+	// Experience from the draft or debut season, or the one stored for players without either
 	Experience int      `json:"experience"`
-	Stats      *Stats   `json:"stats"`
+	Profile    *Profile `json:"profile"`
+	// The stat line written last
+	Stats *Stats `json:"stats"`
+	// Every stored stat line, one per season and season type, filtered by the
+	// arguments that are set
+	SeasonStats []*Stats `json:"seasonStats"`
+	// The career line over the stored lines of one season type
+	Career *Career `json:"career"`
+	// Goes up by one with every update, pass it back as expectedVersion
+	Version int `json:"version"`
+	// When the player was moved to the trash, null for players that were not
+	DeletedAt *string `json:"deletedAt"`
 }
 
-type Stats struct {
-	Season    string  `json:"season"`
+type PlayerAuditConnection struct {
+	Edges    []*PlayerAuditEdge `json:"edges"`
+	PageInfo *PageInfo          `json:"pageInfo"`
+}
+
+type PlayerAuditEdge struct {
+	Cursor string            `json:"cursor"`
+	Node   *PlayerAuditEntry `json:"node"`
+}
+
+type PlayerAuditEntry struct {
+	ID             string      `json:"id"`
+	PlayerID       string      `json:"playerId"`
+	PlayerName     string      `json:"playerName"`
+	OrganizationID string      `json:"organizationId"`
+	Action         AuditAction `json:"action"`
+	ActorID        *string     `json:"actorId"`
+	// The actor's username, erased-<actorId> once their account is gone
+	ActorUsername *string        `json:"actorUsername"`
+	Changes       []*FieldChange `json:"changes"`
+	CreatedAt     string         `json:"createdAt"`
+}
+
+type PlayerConnection struct {
+	Edges    []*PlayerEdge `json:"edges"`
+	PageInfo *PageInfo     `json:"pageInfo"`
+}
+
+type PlayerEdge struct {
+	Cursor string  `json:"cursor"`
+	Node   *Player `json:"node"`
+}
+
+type PlayerFilter struct {
+	// Matches names containing this, ignoring case
+	Name *string `json:"name"`
+	// Matches players eligible for any position in the group
+	Pos *Position `json:"pos"`
+	// Matches players eligible for the position
+	Position *LineupPosition `json:"position"`
+	// Matches on the stat line written last, like Player.stats
+	Season     *string     `json:"season"`
+	SeasonType *SeasonType `json:"seasonType"`
+}
+
+type Profile struct {
+	// Written like 1998-03-24
+	BirthDate *string `json:"birthDate"`
+	// Age on asOf, written like 2024-01-31, today by default. Null without a birth date.
+	Age *int `json:"age"`
+	// In centimetres
+	Height *int `json:"height"`
+	// In kilograms
+	Weight     *int    `json:"weight"`
+	College    *string `json:"college"`
+	Country    *string `json:"country"`
+	DraftYear  *int    `json:"draftYear"`
+	DraftRound *int    `json:"draftRound"`
+	DraftPick  *int    `json:"draftPick"`
+	// First season played, written like 2019-20
+	DebutSeason *string `json:"debutSeason"`
+	// Seasons played before the one asOf falls in, today by default, counted from
+	// the debut season or else the draft year. Null without either.
+	Experience   *int        `json:"experience"`
+	JerseyNumber *int        `json:"jerseyNumber"`
+	Handedness   *Handedness `json:"handedness"`
+}
+
+type Settings struct {
+	RequireTwoFactorForPrivilegedRoles bool             `json:"requireTwoFactorForPrivilegedRoles"`
+	RegistrationMode                   RegistrationMode `json:"registrationMode"`
+}
+
+type StatHigh struct {
+	// Name of the stat, as in Stats
+	Stat       string     `json:"stat"`
+	Value      float64    `json:"value"`
+	Season     string     `json:"season"`
+	SeasonType SeasonType `json:"seasonType"`
+}
+
+type StatLine struct {
 	Points    float64 `json:"points"`
 	ThreePt   float64 `json:"threePt"`
 	Rebounds  float64 `json:"rebounds"`
@@ -80,10 +344,30 @@ type Stats struct {
 	Mp        float64 `json:"mp"`
 }
 
+type Stats struct {
+	Season     string     `json:"season"`
+	SeasonType SeasonType `json:"seasonType"`
+	Points     float64    `json:"points"`
+	ThreePt    float64    `json:"threePt"`
+	Rebounds   float64    `json:"rebounds"`
+	Assists    float64    `json:"assists"`
+	Steals     float64    `json:"steals"`
+	Blocks     float64    `json:"blocks"`
+	TurnOvers  float64    `json:"turnOvers"`
+	Mp         float64    `json:"mp"`
+	// Null for lines stored without it
+	GamesPlayed *int `json:"gamesPlayed"`
+}
+
 type Token struct {
 	Token string `json:"token"`
 }
 
+type TotpEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
 type UpdatePassword struct {
 	ID          *string `json:"id"`
 	Username    string  `json:"username"`
@@ -97,22 +381,270 @@ type UpdateUsername struct {
 }
 
 type User struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	ID               string  `json:"id"`
+	Username         string  `json:"username"`
+	Role             Role    `json:"role"`
+	Email            *string `json:"email"`
+	TwoFactorEnabled *bool   `json:"twoFactorEnabled"`
+	CreatedAt        string  `json:"createdAt"`
+	LastLoginAt      *string `json:"lastLoginAt"`
+	Disabled         *bool   `json:"disabled"`
 }
 
 func (User) IsUserInfo()              {}
 func (this User) GetID() string       { return this.ID }
 func (this User) GetUsername() string { return this.Username }
-func (this User) GetPassword() string { return this.Password }
 
+type UserConnection struct {
+	Edges    []*UserEdge `json:"edges"`
+	PageInfo *PageInfo   `json:"pageInfo"`
+}
+
+type UserEdge struct {
+	Cursor string `json:"cursor"`
+	Node   *User  `json:"node"`
+}
+
+type UserFilter struct {
+	// Matches usernames containing this, ignoring case
+	Username *string `json:"username"`
+	Role     *Role   `json:"role"`
+	Disabled *bool   `json:"disabled"`
+}
+
+type AuditAction string
+
+const (
+	AuditActionCreate  AuditAction = "create"
+	AuditActionUpdate  AuditAction = "update"
+	AuditActionDelete  AuditAction = "delete"
+	AuditActionRestore AuditAction = "restore"
+	// Removed for good once it had been in the trash for the retention period
+	AuditActionPurge AuditAction = "purge"
+)
+
+var AllAuditAction = []AuditAction{
+	AuditActionCreate,
+	AuditActionUpdate,
+	AuditActionDelete,
+	AuditActionRestore,
+	AuditActionPurge,
+}
+
+func (e AuditAction) IsValid() bool {
+	switch e {
+	case AuditActionCreate, AuditActionUpdate, AuditActionDelete, AuditActionRestore, AuditActionPurge:
+		return true
+	}
+	return false
+}
+
+func (e AuditAction) String() string {
+	return string(e)
+}
+
+func (e *AuditAction) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = AuditAction(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid AUDIT_ACTION", str)
+	}
+	return nil
+}
+
+func (e AuditAction) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// How a bulk mutation treats items that fail
+type BulkMode string
+
+const (
+	// Nothing is written unless every item succeeds
+	BulkModeAtomic BulkMode = "atomic"
+	// Items that succeed are written, failed ones are skipped
+	BulkModeBestEffort BulkMode = "best_effort"
+)
+
+var AllBulkMode = []BulkMode{
+	BulkModeAtomic,
+	BulkModeBestEffort,
+}
+
+func (e BulkMode) IsValid() bool {
+	switch e {
+	case BulkModeAtomic, BulkModeBestEffort:
+		return true
+	}
+	return false
+}
+
+func (e BulkMode) String() string {
+	return string(e)
+}
+
+func (e *BulkMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BulkMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BULK_MODE", str)
+	}
+	return nil
+}
+
+func (e BulkMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Handedness string
+
+const (
+	HandednessLeft  Handedness = "left"
+	HandednessRight Handedness = "right"
+)
+
+var AllHandedness = []Handedness{
+	HandednessLeft,
+	HandednessRight,
+}
+
+func (e Handedness) IsValid() bool {
+	switch e {
+	case HandednessLeft, HandednessRight:
+		return true
+	}
+	return false
+}
+
+func (e Handedness) String() string {
+	return string(e)
+}
+
+func (e *Handedness) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Handedness(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HANDEDNESS", str)
+	}
+	return nil
+}
+
+func (e Handedness) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type LineupPosition string
+
+const (
+	LineupPositionPg LineupPosition = "PG"
+	LineupPositionSg LineupPosition = "SG"
+	LineupPositionSf LineupPosition = "SF"
+	LineupPositionPf LineupPosition = "PF"
+	LineupPositionC  LineupPosition = "C"
+)
+
+var AllLineupPosition = []LineupPosition{
+	LineupPositionPg,
+	LineupPositionSg,
+	LineupPositionSf,
+	LineupPositionPf,
+	LineupPositionC,
+}
+
+func (e LineupPosition) IsValid() bool {
+	switch e {
+	case LineupPositionPg, LineupPositionSg, LineupPositionSf, LineupPositionPf, LineupPositionC:
+		return true
+	}
+	return false
+}
+
+func (e LineupPosition) String() string {
+	return string(e)
+}
+
+func (e *LineupPosition) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = LineupPosition(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid LINEUP_POSITION", str)
+	}
+	return nil
+}
+
+func (e LineupPosition) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type OrgRole string
+
+const (
+	OrgRoleViewer OrgRole = "viewer"
+	OrgRoleEditor OrgRole = "editor"
+	OrgRoleAdmin  OrgRole = "admin"
+)
+
+var AllOrgRole = []OrgRole{
+	OrgRoleViewer,
+	OrgRoleEditor,
+	OrgRoleAdmin,
+}
+
+func (e OrgRole) IsValid() bool {
+	switch e {
+	case OrgRoleViewer, OrgRoleEditor, OrgRoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e OrgRole) String() string {
+	return string(e)
+}
+
+func (e *OrgRole) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = OrgRole(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ORG_ROLE", str)
+	}
+	return nil
+}
+
+func (e OrgRole) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Position groups, each made up of lineup positions
 type Position string
 
 const (
-	PositionGuard   Position = "guard"
+	// PG and SG
+	PositionGuard Position = "guard"
+	// SF and PF
 	PositionForward Position = "forward"
-	PositionCenter  Position = "center"
+	// C
+	PositionCenter Position = "center"
 )
 
 var AllPosition = []Position{
@@ -149,3 +681,198 @@ func (e *Position) UnmarshalGQL(v interface{}) error {
 func (e Position) MarshalGQL(w io.Writer) {
 	fmt.Fprint(w, strconv.Quote(e.String()))
 }
+
+type ProfileField string
+
+const (
+	ProfileFieldBirthDate    ProfileField = "birthDate"
+	ProfileFieldHeight       ProfileField = "height"
+	ProfileFieldWeight       ProfileField = "weight"
+	ProfileFieldCollege      ProfileField = "college"
+	ProfileFieldCountry      ProfileField = "country"
+	ProfileFieldDraftYear    ProfileField = "draftYear"
+	ProfileFieldDraftRound   ProfileField = "draftRound"
+	ProfileFieldDraftPick    ProfileField = "draftPick"
+	ProfileFieldDebutSeason  ProfileField = "debutSeason"
+	ProfileFieldJerseyNumber ProfileField = "jerseyNumber"
+	ProfileFieldHandedness   ProfileField = "handedness"
+)
+
+var AllProfileField = []ProfileField{
+	ProfileFieldBirthDate,
+	ProfileFieldHeight,
+	ProfileFieldWeight,
+	ProfileFieldCollege,
+	ProfileFieldCountry,
+	ProfileFieldDraftYear,
+	ProfileFieldDraftRound,
+	ProfileFieldDraftPick,
+	ProfileFieldDebutSeason,
+	ProfileFieldJerseyNumber,
+	ProfileFieldHandedness,
+}
+
+func (e ProfileField) IsValid() bool {
+	switch e {
+	case ProfileFieldBirthDate, ProfileFieldHeight, ProfileFieldWeight, ProfileFieldCollege, ProfileFieldCountry, ProfileFieldDraftYear, ProfileFieldDraftRound, ProfileFieldDraftPick, ProfileFieldDebutSeason, ProfileFieldJerseyNumber, ProfileFieldHandedness:
+		return true
+	}
+	return false
+}
+
+func (e ProfileField) String() string {
+	return string(e)
+}
+
+func (e *ProfileField) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ProfileField(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid PROFILE_FIELD", str)
+	}
+	return nil
+}
+
+func (e ProfileField) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Who may create an account with createUser
+type RegistrationMode string
+
+const (
+	// Anyone
+	RegistrationModeOpen RegistrationMode = "open"
+	// Only users with an invitation
+	RegistrationModeInviteOnly RegistrationMode = "invite_only"
+	// Nobody
+	RegistrationModeClosed RegistrationMode = "closed"
+)
+
+var AllRegistrationMode = []RegistrationMode{
+	RegistrationModeOpen,
+	RegistrationModeInviteOnly,
+	RegistrationModeClosed,
+}
+
+func (e RegistrationMode) IsValid() bool {
+	switch e {
+	case RegistrationModeOpen, RegistrationModeInviteOnly, RegistrationModeClosed:
+		return true
+	}
+	return false
+}
+
+func (e RegistrationMode) String() string {
+	return string(e)
+}
+
+func (e *RegistrationMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = RegistrationMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid REGISTRATION_MODE", str)
+	}
+	return nil
+}
+
+func (e RegistrationMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+type Role string
+
+const (
+	RoleUser   Role = "user"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var AllRole = []Role{
+	RoleUser,
+	RoleEditor,
+	RoleAdmin,
+}
+
+func (e Role) IsValid() bool {
+	switch e {
+	case RoleUser, RoleEditor, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+func (e Role) String() string {
+	return string(e)
+}
+
+func (e *Role) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = Role(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ROLE", str)
+	}
+	return nil
+}
+
+func (e Role) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Part of the year a stat line is for
+type SeasonType string
+
+const (
+	SeasonTypeRegular   SeasonType = "REGULAR"
+	SeasonTypePlayoffs  SeasonType = "PLAYOFFS"
+	SeasonTypePlayIn    SeasonType = "PLAY_IN"
+	SeasonTypePreseason SeasonType = "PRESEASON"
+)
+
+var AllSeasonType = []SeasonType{
+	SeasonTypeRegular,
+	SeasonTypePlayoffs,
+	SeasonTypePlayIn,
+	SeasonTypePreseason,
+}
+
+func (e SeasonType) IsValid() bool {
+	switch e {
+	case SeasonTypeRegular, SeasonTypePlayoffs, SeasonTypePlayIn, SeasonTypePreseason:
+		return true
+	}
+	return false
+}
+
+func (e SeasonType) String() string {
+	return string(e)
+}
+
+func (e *SeasonType) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = SeasonType(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid SEASON_TYPE", str)
+	}
+	return nil
+}
+
+func (e SeasonType) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
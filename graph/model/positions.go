@@ -0,0 +1,24 @@
+package model
+
+// Group returns the position group a lineup position belongs to.
+func (e LineupPosition) Group() Position {
+	switch e {
+	case LineupPositionPg, LineupPositionSg:
+		return PositionGuard
+	case LineupPositionSf, LineupPositionPf:
+		return PositionForward
+	default:
+		return PositionCenter
+	}
+}
+
+// Positions returns the lineup positions in a position group.
+func (e Position) Positions() []LineupPosition {
+	positions := []LineupPosition{}
+	for _, position := range AllLineupPosition {
+		if position.Group() == e {
+			positions = append(positions, position)
+		}
+	}
+	return positions
+}
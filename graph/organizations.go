@@ -0,0 +1,68 @@
+package graph
+
+import (
+	"context"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+)
+
+// requireOrg returns the organization the caller acts in. Any member may read
+// its players, changing them takes the editor or admin role and, when it is
+// mandatory, two factor authentication.
+func requireOrg(ctx context.Context, edit bool) (*auth.Membership, error) {
+	user := auth.ForContext(ctx)
+	member := auth.OrgForContext(ctx)
+	if user == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	if member == nil || (edit && !member.CanEdit()) {
+		return nil, apperr.ErrForbidden
+	}
+	if edit && auth.TwoFactorSetupRequired(ctx) {
+		return nil, errTwoFactorSetupRequired
+	}
+
+	return member, nil
+}
+
+// requireOrgAdmin checks that the caller may manage an organization, either
+// as an admin of it or as a global admin. It checks the database rather than
+// the token, which may have been issued for another organization.
+func (r *Resolver) requireOrgAdmin(ctx context.Context, orgId string) (*model.User, error) {
+	user := auth.ForContext(ctx)
+	if user == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+	if auth.TwoFactorSetupRequired(ctx) {
+		return nil, errTwoFactorSetupRequired
+	}
+
+	if user.Role == model.RoleAdmin {
+		return user, nil
+	}
+
+	role, err := r.OrgRepo.GetMembership(ctx, user.ID, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if role != model.OrgRoleAdmin {
+		return nil, apperr.ErrForbidden
+	}
+
+	return user, nil
+}
+
+// playerSubject is the NATS subject player changes of an organization are
+// published on, so subscribers never see another organization's roster.
+func playerSubject(orgId string) string {
+	return "create." + orgId
+}
+
+// playerBatchSubject is the NATS subject bulk mutations publish the players
+// they wrote on, as one JSON array per batch.
+func playerBatchSubject(orgId string) string {
+	return "create." + orgId + ".batch"
+}
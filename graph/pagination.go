@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"encoding/base64"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageSize checks the first argument of a paginated field.
+func pageSize(first *int) (int, error) {
+	if first == nil {
+		return defaultPageSize, nil
+	}
+	if *first < 1 || *first > maxPageSize {
+		return 0, apperr.Errorf(apperr.Validation, "first must be between 1 and %d", maxPageSize)
+	}
+	return *first, nil
+}
+
+// Cursors are opaque to clients so the key they encode can change.
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor *string) (string, error) {
+	if cursor == nil {
+		return "", nil
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(*cursor)
+	if err != nil {
+		return "", apperr.New(apperr.Validation, "invalid cursor")
+	}
+
+	return string(key), nil
+}
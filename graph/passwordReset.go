@@ -0,0 +1,30 @@
+package graph
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+)
+
+const passwordResetLifetime = time.Hour
+
+// passwordResetSendTimeout bounds a reset mail sent after the mutation has
+// returned.
+const passwordResetSendTimeout = 30 * time.Second
+
+// passwordResetSent is returned whether or not the account exists, so the
+// mutation cannot be used to find out which usernames are taken.
+const passwordResetSent = "If the account exists and has an email address, a password reset link has been sent to it"
+
+// passwordResetBody builds the reset mail. With PASSWORD_RESET_URL set the
+// token is appended to that url, otherwise the bare token is sent.
+func passwordResetBody(token string) string {
+	link := token
+	if base := os.Getenv("PASSWORD_RESET_URL"); base != "" {
+		link = base + "?token=" + url.QueryEscape(token)
+	}
+
+	return fmt.Sprintf("A password reset was requested for your account.\n\n%s\n\nThis link expires in %s. If you did not request it you can ignore this mail.",
+		link, passwordResetLifetime)
+}
@@ -0,0 +1,44 @@
+package graph
+
+import (
+	"strconv"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// auditPage checks the paging arguments of an audit query. Audit cursors
+// encode the id of the last entry on the page.
+func auditPage(first *int, after *string) (int, int64, error) {
+	size, err := pageSize(first)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	key, err := decodeCursor(after)
+	if err != nil || key == "" {
+		return size, 0, err
+	}
+
+	before, err := strconv.ParseInt(key, 10, 64)
+	if err != nil {
+		return 0, 0, apperr.New(apperr.Validation, "invalid cursor")
+	}
+
+	return size, before, nil
+}
+
+func auditConnection(entries []*model.PlayerAuditEntry, hasNext bool) *model.PlayerAuditConnection {
+	connection := &model.PlayerAuditConnection{
+		Edges:    make([]*model.PlayerAuditEdge, len(entries)),
+		PageInfo: &model.PageInfo{HasNextPage: hasNext},
+	}
+	for i, entry := range entries {
+		connection.Edges[i] = &model.PlayerAuditEdge{Cursor: encodeCursor(entry.ID), Node: entry}
+	}
+	if len(entries) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(entries)-1].Cursor
+	}
+
+	return connection
+}
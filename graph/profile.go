@@ -0,0 +1,78 @@
+package graph
+
+import (
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+// seasonStartMonth is the month a season starts in, a date before it belongs
+// to the season that started the year before
+const seasonStartMonth = time.October
+
+// asOfDate reads the asOf argument of the computed profile fields, today
+// when it is not set.
+func asOfDate(asOf *string) (time.Time, error) {
+	if asOf == nil {
+		return time.Now().UTC(), nil
+	}
+
+	date, err := time.Parse(validate.DateLayout, *asOf)
+	if err != nil {
+		return time.Time{}, apperr.New(apperr.Validation, "asOf must be a date like 2024-01-31")
+	}
+
+	return date, nil
+}
+
+// ageOn returns the age on date of a player with a birth date, or nil for
+// one without.
+func ageOn(profile *model.Profile, date time.Time) (*int, error) {
+	if profile.BirthDate == nil {
+		return nil, nil
+	}
+
+	birthDate, err := time.Parse(validate.DateLayout, *profile.BirthDate)
+	if err != nil {
+		return nil, err
+	}
+	if date.Before(birthDate) {
+		return nil, apperr.New(apperr.Validation, "asOf cannot be before the birth date")
+	}
+
+	age := date.Year() - birthDate.Year()
+	if date.Month() < birthDate.Month() || (date.Month() == birthDate.Month() && date.Day() < birthDate.Day()) {
+		age--
+	}
+
+	return &age, nil
+}
+
+// experienceOn counts the seasons played before the one date falls in, from
+// the debut season or else the season after the draft. It is nil for a
+// player with neither.
+func experienceOn(profile *model.Profile, date time.Time) *int {
+	var debut int
+	switch {
+	case profile.DebutSeason != nil:
+		debut = validate.SeasonStart(*profile.DebutSeason)
+	case profile.DraftYear != nil:
+		debut = *profile.DraftYear
+	default:
+		return nil
+	}
+
+	season := date.Year()
+	if date.Month() < seasonStartMonth {
+		season--
+	}
+
+	experience := season - debut
+	if experience < 0 {
+		experience = 0
+	}
+
+	return &experience
+}
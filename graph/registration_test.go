@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+	"github.com/mattmazer1/graphql-api/repository/memory"
+)
+
+// newTestResolver returns a resolver on an empty in-memory store, which also
+// answers the status checks of the tokens it issues.
+func newTestResolver(t *testing.T) (*Resolver, *memory.Store) {
+	t.Setenv("JWT_SECRET", "registration test secret")
+
+	store := memory.New()
+	auth.SetStatusSource(store)
+
+	return &Resolver{PlayerRepo: store, UserRepo: store, AuthRepo: store, OrgRepo: store, SettingsRepo: store}, store
+}
+
+func TestInvitedUserListsOrganizationPlayers(t *testing.T) {
+	ctx := context.Background()
+	r, store := newTestResolver(t)
+
+	adminId, err := store.CreateUser(ctx, model.InputUser{Username: "ada", Password: "correct horse"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := store.CreateOrganization(ctx, "Celtics", adminId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	position := model.LineupPositionPg
+	age, experience := 25, 3
+	bo := model.InputPlayer{
+		Name:            "Bo",
+		PrimaryPosition: &position,
+		Age:             &age,
+		Experience:      &experience,
+		Stats:           &model.InputStats{Season: "2022-23", SeasonType: model.SeasonTypeRegular, Points: 20, Mp: 30},
+	}
+	if _, err := store.CreatePlayer(ctx, org.ID, adminId, bo); err != nil {
+		t.Fatal(err)
+	}
+
+	adminCtx := context.WithValue(ctx, auth.UserCtxKey, &model.User{ID: adminId, Username: "ada", Role: model.RoleAdmin})
+	invitation, err := (&mutationResolver{r}).CreateInvitation(adminCtx, model.RoleUser, 3600, &org.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if invitation.OrganizationID != org.ID || invitation.OrgRole != model.OrgRoleViewer {
+		t.Fatalf("got invitation %+v, want one to join %s as viewer", invitation, org.ID)
+	}
+
+	token, err := (&mutationResolver{r}).CreateUser(ctx, model.InputUser{Username: "cy", Password: "correct horse"}, &invitation.Code)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userCtx, _, err := auth.ContextFromToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	players, err := (&queryResolver{r}).Players(userCtx, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(players.Edges) != 1 || players.Edges[0].Node.Name != "Bo" {
+		t.Fatalf("got players %+v, want Bo of the invitation's organization", players.Edges)
+	}
+}
+
+func TestOpenRegistrationJoinsDefaultOrganization(t *testing.T) {
+	ctx := context.Background()
+	r, _ := newTestResolver(t)
+
+	token, err := (&mutationResolver{r}).CreateUser(ctx, model.InputUser{Username: "ada", Password: "correct horse"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userCtx, claims, err := auth.ContextFromToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Org != db.DefaultOrganizationId || claims.OrgRole != string(model.OrgRoleViewer) {
+		t.Fatalf("got organization %s %s, want %s viewer", claims.Org, claims.OrgRole, db.DefaultOrganizationId)
+	}
+	if _, err := (&queryResolver{r}).Players(userCtx, nil, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+}
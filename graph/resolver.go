@@ -1,7 +1,17 @@
 package graph
 
-import "github.com/nats-io/nats.go"
+import (
+	"github.com/mattmazer1/graphql-api/mailer"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/nats-io/nats.go"
+)
 
 type Resolver struct {
-	Nats *nats.Conn
+	Nats         *nats.Conn
+	Mailer       mailer.Mailer
+	PlayerRepo   repository.PlayerRepository
+	UserRepo     repository.UserRepository
+	AuthRepo     repository.AuthRepository
+	OrgRepo      repository.OrganizationRepository
+	SettingsRepo repository.SettingsRepository
 }
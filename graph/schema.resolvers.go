@@ -3,288 +3,1438 @@ package graph
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
+	"time"
 
+	"github.com/99designs/gqlgen/graphql"
 	_ "github.com/lib/pq"
+	"github.com/mattmazer1/graphql-api/apperr"
 	db "github.com/mattmazer1/graphql-api/database"
 	"github.com/mattmazer1/graphql-api/graph/model"
 	auth "github.com/mattmazer1/graphql-api/middleware"
 	nat "github.com/mattmazer1/graphql-api/nats"
+	"github.com/mattmazer1/graphql-api/playercsv"
+	"github.com/mattmazer1/graphql-api/repository"
 	"github.com/mattmazer1/graphql-api/utils"
 	nats "github.com/nats-io/nats.go"
 )
 
+// Age is the resolver for the age field.
+func (r *playerResolver) Age(ctx context.Context, obj *model.Player) (int, error) {
+	age, err := ageOn(obj.Profile, time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	if age == nil {
+		return obj.Age, nil
+	}
+
+	return *age, nil
+}
+
+// Experience is the resolver for the experience field.
+func (r *playerResolver) Experience(ctx context.Context, obj *model.Player) (int, error) {
+	experience := experienceOn(obj.Profile, time.Now().UTC())
+	if experience == nil {
+		return obj.Experience, nil
+	}
+
+	return *experience, nil
+}
+
+// SeasonStats is the resolver for the seasonStats field.
+func (r *playerResolver) SeasonStats(ctx context.Context, obj *model.Player, season *string, seasonType *model.SeasonType) ([]*model.Stats, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	lines, err := r.PlayerRepo.GetPlayerStats(ctx, member.OrgID, obj.ID, season, seasonType)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player stats: %w", err)
+	}
+
+	return lines, nil
+}
+
+// Career is the resolver for the career field.
+func (r *playerResolver) Career(ctx context.Context, obj *model.Player, seasonType model.SeasonType) (*model.Career, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	career, err := r.PlayerRepo.GetPlayerCareer(ctx, member.OrgID, obj, seasonType)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player career: %w", err)
+	}
+
+	return career, nil
+}
+
+// Age is the resolver for the age field.
+func (r *profileResolver) Age(ctx context.Context, obj *model.Profile, asOf *string) (*int, error) {
+	date, err := asOfDate(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	return ageOn(obj, date)
+}
+
+// Experience is the resolver for the experience field.
+func (r *profileResolver) Experience(ctx context.Context, obj *model.Profile, asOf *string) (*int, error) {
+	date, err := asOfDate(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	return experienceOn(obj, date), nil
+}
+
 // CreatePlayer is the resolver for the createPlayer field.
 func (r *mutationResolver) CreatePlayer(ctx context.Context, player model.InputPlayer) (*model.Player, error) {
-	user := auth.ForContext(ctx)
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	createdPlayer, dbErr := r.PlayerRepo.CreatePlayer(ctx, member.OrgID, auth.ForContext(ctx).ID, player)
+
+	if dbErr != nil {
+		return nil, fmt.Errorf("could not create player: %w", argumentError(dbErr, "player"))
+	}
+
+	createdPlayerJSON, err := json.Marshal(createdPlayer)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal created player: %w", err)
+	}
+
+	nat.Nc.Publish(playerSubject(member.OrgID), createdPlayerJSON)
+
+	return createdPlayer, nil
+}
+
+// UpdatePlayer is the resolver for the updatePlayer field.
+func (r *mutationResolver) UpdatePlayer(ctx context.Context, player model.InputUpdatePlayer, expectedVersion int) (*model.Player, error) {
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedPlayer, err := r.PlayerRepo.UpdatePlayer(ctx, member.OrgID, auth.ForContext(ctx).ID, player, expectedVersion)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update player: %w", argumentError(err, "player"))
+	}
+
+	return updatedPlayer, nil
+}
+
+// DeletePlayer is the resolver for the deletePlayer field.
+func (r *mutationResolver) DeletePlayer(ctx context.Context, name string, expectedVersion int) (*model.Player, error) {
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	deletedPlayer, err := r.PlayerRepo.DeletePlayer(ctx, member.OrgID, auth.ForContext(ctx).ID, name, expectedVersion)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not delete player: %w", err)
+	}
+
+	return deletedPlayer, nil
+}
+
+// RestorePlayer is the resolver for the restorePlayer field.
+func (r *mutationResolver) RestorePlayer(ctx context.Context, id string) (*model.Player, error) {
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	restoredPlayer, err := r.PlayerRepo.RestorePlayer(ctx, member.OrgID, auth.ForContext(ctx).ID, id)
+
+	if errors.Is(err, db.ErrPlayerNotFound) || errors.Is(err, db.ErrPlayerNameTaken) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not restore player: %w", err)
+	}
+
+	return restoredPlayer, nil
+}
+
+// CreatePlayers is the resolver for the createPlayers field.
+func (r *mutationResolver) CreatePlayers(ctx context.Context, players []*model.InputPlayer, mode model.BulkMode) (*model.BulkPlayerPayload, error) {
+	member, err := requireBulk(ctx, len(players))
+	if err != nil {
+		return nil, err
+	}
+
+	results, committed, err := r.PlayerRepo.CreatePlayers(ctx, member.OrgID, auth.ForContext(ctx).ID, players, mode == model.BulkModeAtomic)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create players: %w", err)
+	}
+
+	return bulkPayload(member.OrgID, results, committed), nil
+}
+
+// UpsertPlayers is the resolver for the upsertPlayers field.
+func (r *mutationResolver) UpsertPlayers(ctx context.Context, players []*model.InputPlayer, mode model.BulkMode) (*model.BulkPlayerPayload, error) {
+	member, err := requireBulk(ctx, len(players))
+	if err != nil {
+		return nil, err
+	}
+
+	results, committed, err := r.PlayerRepo.UpsertPlayers(ctx, member.OrgID, auth.ForContext(ctx).ID, players, mode == model.BulkModeAtomic)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not upsert players: %w", err)
+	}
+
+	return bulkPayload(member.OrgID, results, committed), nil
+}
+
+// DeletePlayers is the resolver for the deletePlayers field.
+func (r *mutationResolver) DeletePlayers(ctx context.Context, players []*model.InputDeletePlayer, mode model.BulkMode) (*model.BulkPlayerPayload, error) {
+	member, err := requireBulk(ctx, len(players))
+	if err != nil {
+		return nil, err
+	}
+
+	results, committed, err := r.PlayerRepo.DeletePlayers(ctx, member.OrgID, auth.ForContext(ctx).ID, players, mode == model.BulkModeAtomic)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not delete players: %w", err)
+	}
+
+	// Deletions are not published, like deletePlayer
+	return bulkPayload("", results, committed), nil
+}
+
+// ImportPlayersCSV is the resolver for the importPlayersCsv field.
+func (r *mutationResolver) ImportPlayersCSV(ctx context.Context, file graphql.Upload, mode model.BulkMode, upsert bool) (*model.CSVImportPayload, error) {
+	member, err := requireOrg(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, rowErrors, err := playercsv.Read(file.File, maxImportRows)
+	if err != nil {
+		return nil, err
+	}
+
+	// A row can fail on several columns and the header is not a row, so
+	// failed counts the distinct data rows with errors
+	payload := &model.CSVImportPayload{Errors: []*model.CSVRowError{}}
+	failedRows := map[int]bool{}
+	for _, rowError := range rowErrors {
+		payload.Errors = append(payload.Errors, csvRowError(rowError))
+		if rowError.Row > 1 {
+			failedRows[rowError.Row] = true
+		}
+	}
+	payload.Failed = len(failedRows)
+
+	atomic := mode == model.BulkModeAtomic
+	if len(rows) == 0 || (atomic && len(rowErrors) > 0) {
+		return payload, nil
+	}
+
+	players := make([]*model.InputPlayer, len(rows))
+	for i := range rows {
+		players[i] = &rows[i].Player
+	}
+
+	actorId := auth.ForContext(ctx).ID
+	var results []repository.BulkResult
+	if upsert {
+		results, payload.Committed, err = r.PlayerRepo.UpsertPlayers(ctx, member.OrgID, actorId, players, atomic)
+	} else {
+		results, payload.Committed, err = r.PlayerRepo.CreatePlayers(ctx, member.OrgID, actorId, players, atomic)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not import players: %w", err)
+	}
+
+	written := []*model.Player{}
+	for i, result := range results {
+		if result.Err != nil {
+			payload.Failed++
+			if fields := playercsv.ValidationErrors(rows[i].Row, result.Err); len(fields) > 0 {
+				for _, field := range fields {
+					payload.Errors = append(payload.Errors, csvRowError(field))
+				}
+				continue
+			}
+			message, _ := bulkItemError(i, result.Err)
+			payload.Errors = append(payload.Errors, &model.CSVRowError{Row: rows[i].Row, Message: message})
+			continue
+		}
+		written = append(written, result.Player)
+	}
+	payload.Imported = len(written)
+
+	if payload.Committed {
+		publishPlayers(member.OrgID, written)
+	}
+
+	return payload, nil
+}
+
+// Login is the resolver for the login field.
+func (r *mutationResolver) Login(ctx context.Context, input model.InputUser) (*model.LoginResult, error) {
+	ip := auth.ClientIP(ctx)
+
+	// Throttled attempts are turned away before the password is hashed, the
+	// others are counted as failures until the login succeeds
+	err := r.AuthRepo.ReserveLoginAttempt(ctx, input.Username, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	correct, err := r.UserRepo.Authenticate(ctx, input)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not authenticate user %w", err)
+	}
+	if !correct {
+		if err := r.AuthRepo.RecordLoginFailure(ctx, input.Username, ip); err != nil {
+			return nil, fmt.Errorf("could not authenticate user %w", err)
+		}
+		return nil, apperr.New(apperr.Unauthenticated, "could not authenticate user")
+	}
+
+	user, err := r.UserRepo.GetUser(ctx, input.Username)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	if user.Disabled != nil && *user.Disabled {
+		return nil, auth.ErrAccountDisabled
+	}
+
+	// Failures are only cleared once every step has passed, so guessing
+	// second factor codes still counts towards the lockout
+	if user.TwoFactorEnabled != nil && *user.TwoFactorEnabled {
+		challenge, challengeHash, err := utils.NewOpaqueToken()
+		if err != nil {
+			return nil, err
+		}
+
+		err = r.AuthRepo.CreateLoginChallenge(ctx, user.ID, challengeHash, time.Now().Add(loginChallengeLifetime))
+		if err != nil {
+			return nil, fmt.Errorf("could not create login challenge: %w", err)
+		}
+
+		return &model.LoginResult{Challenge: &challenge, TwoFactorRequired: true}, nil
+	}
+
+	if err := r.AuthRepo.RecordLoginSuccess(ctx, input.Username, ip); err != nil {
+		return nil, fmt.Errorf("could not authenticate user %w", err)
+	}
+
+	return r.loginResult(ctx, user)
+}
+
+// VerifyTwoFactor is the resolver for the verifyTwoFactor field.
+func (r *mutationResolver) VerifyTwoFactor(ctx context.Context, challenge string, code string) (string, error) {
+	challengeHash := utils.HashOpaqueToken(challenge)
+
+	// The attempt is counted before the code is checked, so a correct code
+	// cannot be sent past the limit alongside wrong ones
+	userId, err := r.AuthRepo.ReserveChallengeAttempt(ctx, challengeHash)
+
+	if err != nil {
+		return "", fmt.Errorf("could not get login challenge: %w", err)
+	}
+	if userId == "" {
+		return "", apperr.New(apperr.Unauthenticated, "invalid or expired challenge")
+	}
+
+	user, err := r.UserRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return "", apperr.New(apperr.Unauthenticated, "invalid or expired challenge")
+	}
+	if user.Disabled != nil && *user.Disabled {
+		return "", auth.ErrAccountDisabled
+	}
+
+	ip := auth.ClientIP(ctx)
+
+	err = r.AuthRepo.ReserveLoginAttempt(ctx, user.Username, ip)
+	if err != nil {
+		return "", err
+	}
+
+	correct, err := r.verifySecondFactor(ctx, user, code)
+
+	if err != nil {
+		return "", fmt.Errorf("could not verify code: %w", err)
+	}
+	if !correct {
+		if err := r.AuthRepo.RecordLoginFailure(ctx, user.Username, ip); err != nil {
+			return "", err
+		}
+		return "", apperr.New(apperr.Unauthenticated, "invalid code")
+	}
+
+	if err := r.AuthRepo.DeleteLoginChallenge(ctx, challengeHash); err != nil {
+		return "", err
+	}
+
+	if err := r.AuthRepo.RecordLoginSuccess(ctx, user.Username, ip); err != nil {
+		return "", fmt.Errorf("could not authenticate user %w", err)
+	}
+
+	result, err := r.loginResult(ctx, user)
+	if err != nil {
+		return "", err
+	}
+
+	return *result.Token, nil
+}
+
+// EnrollTotp is the resolver for the enrollTotp field.
+func (r *mutationResolver) EnrollTotp(ctx context.Context) (*model.TotpEnrollment, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	secret, err := utils.NewTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	// The authenticator app shows the username, which the token may carry
+	// from before a rename
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	updated, err := r.AuthRepo.SetTotpSecret(ctx, userauth.ID, secret)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not enroll totp: %w", err)
+	}
+	if !updated {
+		return nil, apperr.New(apperr.Conflict, "two factor authentication is already enabled")
+	}
+
+	return &model.TotpEnrollment{Secret: secret, URI: utils.TotpURI(user.Username, secret)}, nil
+}
+
+// ConfirmTotp is the resolver for the confirmTotp field.
+func (r *mutationResolver) ConfirmTotp(ctx context.Context, code string) ([]string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+
+	secret, enabled, err := r.AuthRepo.GetTotp(ctx, userauth.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get totp: %w", err)
+	}
+	if enabled {
+		return nil, apperr.New(apperr.Conflict, "two factor authentication is already enabled")
+	}
+	if secret == "" {
+		return nil, apperr.New(apperr.Conflict, "call enrollTotp first")
+	}
+
+	step, ok := utils.ValidateTotp(secret, code, time.Now())
+	if !ok {
+		return nil, apperr.New(apperr.Validation, "invalid code")
+	}
+
+	codes, codeHashes, err := utils.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.AuthRepo.EnableTotp(ctx, userauth.ID, step, codeHashes)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not enable totp: %w", err)
+	}
+
+	if err := r.AuthRepo.InsertAuthAudit(ctx, db.AuditTotpEnabled, user.Username, auth.ClientIP(ctx), userauth.ID); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTotp is the resolver for the disableTotp field.
+func (r *mutationResolver) DisableTotp(ctx context.Context, code string) (string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	privileged := isPrivileged(user.Role, "")
+	if !privileged {
+		privileged, err = r.OrgRepo.CanEditAnyOrganization(ctx, user.ID)
+		if err != nil {
+			return "", err
+		}
+	}
+	if privileged {
+		required, err := r.SettingsRepo.RequireTwoFactor(ctx)
+		if err != nil {
+			return "", err
+		}
+		if required {
+			return "", apperr.New(apperr.Forbidden, "two factor authentication is mandatory for editors and admins")
+		}
+	}
+
+	correct, err := r.verifySecondFactor(ctx, user, code)
+
+	if err != nil {
+		return "", fmt.Errorf("could not verify code: %w", err)
+	}
+	if !correct {
+		return "", apperr.New(apperr.Validation, "invalid code")
+	}
+
+	if err := r.AuthRepo.DisableTotp(ctx, user.ID); err != nil {
+		return "", fmt.Errorf("could not disable totp: %w", err)
+	}
+
+	if err := r.AuthRepo.InsertAuthAudit(ctx, db.AuditTotpDisabled, user.Username, auth.ClientIP(ctx), user.ID); err != nil {
+		return "", err
+	}
+
+	return "Disabled two factor authentication", nil
+}
+
+// SetRequireTwoFactor is the resolver for the setRequireTwoFactor field.
+func (r *mutationResolver) SetRequireTwoFactor(ctx context.Context, required bool) (*model.Settings, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	err := r.SettingsRepo.SetRequireTwoFactor(ctx, required)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update settings: %w", err)
+	}
+
+	return r.getSettings(ctx)
+}
+
+// SetRegistrationMode is the resolver for the setRegistrationMode field.
+func (r *mutationResolver) SetRegistrationMode(ctx context.Context, mode model.RegistrationMode) (*model.Settings, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	err := r.SettingsRepo.SetRegistrationMode(ctx, mode)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update settings: %w", err)
+	}
+
+	return r.getSettings(ctx)
+}
+
+// CreateInvitation is the resolver for the createInvitation field.
+func (r *mutationResolver) CreateInvitation(ctx context.Context, role model.Role, expiresIn int, organizationID *string, orgRole *model.OrgRole) (*model.Invitation, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	lifetime := time.Duration(expiresIn) * time.Second
+	if lifetime <= 0 || lifetime > maxInvitationLifetime {
+		return nil, apperr.Errorf(apperr.Validation, "expiresIn must be between 1 and %d seconds", int(maxInvitationLifetime.Seconds()))
+	}
+
+	// The invited user joins the caller's organization unless told otherwise
+	orgId := ""
+	if organizationID != nil {
+		orgId = *organizationID
+	} else if member := auth.OrgForContext(ctx); member != nil {
+		orgId = member.OrgID
+	}
+	if orgId == "" {
+		return nil, apperr.New(apperr.Validation, "organizationId is required")
+	}
+
+	exists, err := r.OrgRepo.OrganizationExists(ctx, orgId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apperr.Errorf(apperr.NotFound, "organization %s not found", orgId)
+	}
+
+	joinAs := model.OrgRoleViewer
+	if orgRole != nil {
+		joinAs = *orgRole
+	}
+
+	code, codeHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(lifetime)
+
+	err = r.UserRepo.CreateInvitation(ctx, codeHash, role, orgId, joinAs, userauth.ID, expiresAt)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create invitation: %w", err)
+	}
+
+	if err := r.AuthRepo.InsertAuthAudit(ctx, db.AuditInvitation, "", auth.ClientIP(ctx), userauth.ID); err != nil {
+		log.Printf("could not audit invitation: %v", err)
+	}
+
+	return &model.Invitation{
+		Code:           code,
+		Role:           role,
+		OrganizationID: orgId,
+		OrgRole:        joinAs,
+		ExpiresAt:      expiresAt.UTC().Format(time.RFC3339),
+	}, nil
+}
+
+// RefreshToken is the resolver for the refreshToken field.
+func (r *mutationResolver) RefreshToken(ctx context.Context, token string) (string, error) {
+	claims, err := utils.ParseToken(token)
+	if err != nil {
+		return "", auth.ErrInvalidToken
+	}
+
+	// A disabled user or a token from an ended session cannot be refreshed
+	if err := auth.CheckUserStatus(ctx, claims); err != nil {
+		return "", err
+	}
+
+	// Look the user up by id so a renamed user gets their current username
+	// and a deleted user cannot keep refreshing
+	user, err := r.UserRepo.GetUserById(ctx, claims.Subject)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	// Refreshing must not hand a privileged role back to a user who has not
+	// set up mandatory two factor authentication yet
+	result, err := r.loginResult(ctx, user)
+	if err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+	return *result.Token, nil
+}
+
+// CreateUser is the resolver for the createUser field.
+func (r *mutationResolver) CreateUser(ctx context.Context, input model.InputUser, invitation *string) (string, error) {
+	invitationHash, err := r.checkRegistration(ctx, invitation)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := r.UserRepo.CreateUser(ctx, input, invitationHash)
+
+	if errors.Is(err, db.ErrInvalidInvitation) || errors.Is(err, db.ErrNoOrganization) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("could no create user to db: %w", err)
+	}
+
+	user, err := r.UserRepo.GetUserById(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+
+	// Invitations can hand out privileged roles, which are subject to
+	// mandatory two factor authentication like on login
+	result, err := r.loginResult(ctx, user)
+	if err != nil {
+		return "", fmt.Errorf("could not generate token: %w", err)
+	}
+
+	return *result.Token, nil
+}
+
+// UpdateUser is the resolver for the updateUser field.
+func (r *mutationResolver) UpdateUsername(ctx context.Context, user model.UpdateUsername) (string, error) {
+	id, err := r.requireSelfOrAdmin(ctx, user.OldUsername)
+	if err != nil {
+		return "", err
+	}
+
+	user.ID = &id
+
+	dbErr := r.UserRepo.UpdateUsername(ctx, user)
+
+	if dbErr != nil {
+		return "", fmt.Errorf("could not update username: %w", dbErr)
+	}
+
+	return fmt.Sprintf("Successfully updated username for user id - %s", *user.ID), nil
+}
+
+func (r *mutationResolver) UpdatePassword(ctx context.Context, user model.UpdatePassword) (string, error) {
+	id, err := r.requireSelfOrAdmin(ctx, user.Username)
+	if err != nil {
+		return "", err
+	}
+
+	user.ID = &id
+
+	dbErr := r.UserRepo.UpdatePassword(ctx, user)
+
+	if dbErr != nil {
+		return "", fmt.Errorf("could not update password: %w", dbErr)
+	}
+
+	auth.InvalidateUserStatus(id)
+
+	return fmt.Sprintf("Successfully updated password for user id - %s", *user.ID), nil
+}
+
+// DeleteUser is the resolver for the deleteUser field.
+func (r *mutationResolver) DeleteUser(ctx context.Context, username string) (string, error) {
+	id, err := r.requireSelfOrAdmin(ctx, username)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.UserRepo.DeleteUser(ctx, username)
+
+	if err != nil {
+		return "", fmt.Errorf("could not delete user: %w", err)
+	}
+
+	auth.InvalidateUserStatus(id)
+
+	return fmt.Sprintf("Deleted user %s", username), nil
+}
+
+// UnlockAccount is the resolver for the unlockAccount field.
+func (r *mutationResolver) UnlockAccount(ctx context.Context, username string) (string, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	err = r.AuthRepo.UnlockAccount(ctx, username, userauth.ID)
+
+	if err != nil {
+		return "", fmt.Errorf("could not unlock account: %w", err)
+	}
+
+	return fmt.Sprintf("Unlocked account %s", username), nil
+}
+
+// DisableUser is the resolver for the disableUser field.
+func (r *mutationResolver) DisableUser(ctx context.Context, username string) (*model.User, error) {
+	return r.setUserDisabled(ctx, username, true)
+}
+
+// EnableUser is the resolver for the enableUser field.
+func (r *mutationResolver) EnableUser(ctx context.Context, username string) (*model.User, error) {
+	return r.setUserDisabled(ctx, username, false)
+}
+
+// SetUserRole is the resolver for the setUserRole field.
+func (r *mutationResolver) SetUserRole(ctx context.Context, username string, role model.Role) (*model.User, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keeps an admin from locking everyone out by demoting themselves
+	if role != model.RoleAdmin {
+		self, err := r.isCaller(ctx, userauth, username)
+		if err != nil {
+			return nil, err
+		}
+		if self {
+			return nil, apperr.New(apperr.Forbidden, "you cannot change your own role")
+		}
+	}
+
+	id, err := r.UserRepo.SetUserRole(ctx, username, role, userauth.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not set user role: %w", err)
+	}
+	if id == "" {
+		return nil, apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	auth.InvalidateUserStatus(id)
+
+	return r.UserRepo.GetUserById(ctx, id)
+}
+
+// ForceLogout is the resolver for the forceLogout field.
+func (r *mutationResolver) ForceLogout(ctx context.Context, username string) (string, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := r.UserRepo.ForceLogout(ctx, username, userauth.ID)
+
+	if err != nil {
+		return "", fmt.Errorf("could not end sessions: %w", err)
+	}
+	if id == "" {
+		return "", apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	auth.InvalidateUserStatus(id)
+
+	return fmt.Sprintf("Ended all sessions of %s", username), nil
+}
+
+// CreateOrganization is the resolver for the createOrganization field.
+func (r *mutationResolver) CreateOrganization(ctx context.Context, name string) (*model.Organization, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	organization, err := r.OrgRepo.CreateOrganization(ctx, name, userauth.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create organization: %w", err)
+	}
+
+	return organization, nil
+}
+
+// SetOrganizationMember is the resolver for the setOrganizationMember field.
+func (r *mutationResolver) SetOrganizationMember(ctx context.Context, organizationID string, username string, role model.OrgRole) (*model.OrganizationMember, error) {
+	if _, err := r.requireOrgAdmin(ctx, organizationID); err != nil {
+		return nil, err
+	}
+
+	exists, err := r.OrgRepo.OrganizationExists(ctx, organizationID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, apperr.Errorf(apperr.NotFound, "organization %s not found", organizationID)
+	}
+
+	user, err := r.UserRepo.GetUser(ctx, username)
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
 	if user == nil {
-		return nil, fmt.Errorf("access denied")
+		return nil, apperr.Errorf(apperr.NotFound, "user %s not found", username)
 	}
 
-	createdPlayer := &model.Player{
-		Pos:        player.Pos,
-		Name:       player.Name,
-		Age:        player.Age,
-		Experience: player.Experience,
-		Stats: &model.Stats{
-			Season:    player.Stats.Season,
-			Points:    player.Stats.Points,
-			ThreePt:   player.Stats.ThreePt,
-			Rebounds:  player.Stats.Rebounds,
-			Assists:   player.Stats.Assists,
-			Steals:    player.Stats.Steals,
-			Blocks:    player.Stats.Blocks,
-			TurnOvers: player.Stats.TurnOvers,
-			Mp:        player.Stats.Mp},
+	err = r.OrgRepo.SetOrganizationMember(ctx, organizationID, user.ID, role)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not set organization member: %w", err)
 	}
 
-	dbErr := db.CreatePlayer(ctx, player)
+	auth.InvalidateUserStatus(user.ID)
 
-	if dbErr != nil {
-		return nil, fmt.Errorf("could not delete player: %w", dbErr)
+	return &model.OrganizationMember{UserID: user.ID, Username: user.Username, Role: role}, nil
+}
+
+// RemoveOrganizationMember is the resolver for the removeOrganizationMember field.
+func (r *mutationResolver) RemoveOrganizationMember(ctx context.Context, organizationID string, username string) (string, error) {
+	if _, err := r.requireOrgAdmin(ctx, organizationID); err != nil {
+		return "", err
 	}
 
-	createdPlayerJSON, err := json.Marshal(createdPlayer)
+	id, err := r.UserRepo.GetUserId(ctx, username)
+	if err != nil {
+		return "", fmt.Errorf("could not get user id: %w", err)
+	}
+	if id == "" {
+		return "", apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	err = r.OrgRepo.RemoveOrganizationMember(ctx, organizationID, id)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not marshal created player: %w", err)
+		return "", fmt.Errorf("could not remove organization member: %w", err)
 	}
 
-	nat.Nc.Publish("create", createdPlayerJSON)
+	auth.InvalidateUserStatus(id)
 
-	return createdPlayer, nil
+	return fmt.Sprintf("Removed %s from organization %s", username, organizationID), nil
 }
 
-// UpdatePlayer is the resolver for the updatePlayer field.
-func (r *mutationResolver) UpdatePlayer(ctx context.Context, player model.InputUpdatePlayer) (*model.Player, error) {
-	user := auth.ForContext(ctx)
+// SwitchOrganization is the resolver for the switchOrganization field.
+func (r *mutationResolver) SwitchOrganization(ctx context.Context, organizationID string) (string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
 	if user == nil {
-		return nil, fmt.Errorf("access denied")
+		return "", apperr.ErrUnauthenticated
+	}
+
+	role, err := r.OrgRepo.GetMembership(ctx, user.ID, organizationID)
+	if err != nil {
+		return "", err
 	}
 
-	updatedPlayer := &model.Player{
-		Pos:        *player.Pos,
-		Name:       *player.Name,
-		Age:        *player.Age,
-		Experience: *player.Experience,
-		Stats: &model.Stats{
-			Season:    *player.Stats.Season,
-			Points:    *player.Stats.Points,
-			ThreePt:   *player.Stats.ThreePt,
-			Rebounds:  *player.Stats.Rebounds,
-			Assists:   *player.Stats.Assists,
-			Steals:    *player.Stats.Steals,
-			Blocks:    *player.Stats.Blocks,
-			TurnOvers: *player.Stats.TurnOvers,
-			Mp:        *player.Stats.Mp},
+	// Admins can act in any organization
+	if role == "" && user.Role == model.RoleAdmin {
+		exists, err := r.OrgRepo.OrganizationExists(ctx, organizationID)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			role = model.OrgRoleAdmin
+		}
 	}
 
-	err := db.UpdatePlayer(ctx, player)
+	if role == "" {
+		return "", apperr.ErrForbidden
+	}
 
+	result, err := r.issueToken(ctx, user, organizationID, role)
 	if err != nil {
-		return nil, fmt.Errorf("could not update player: %w", err)
+		return "", fmt.Errorf("could not generate token: %w", err)
 	}
 
-	return updatedPlayer, nil
+	return *result.Token, nil
 }
 
-// DeletePlayer is the resolver for the deletePlayer field.
-func (r *mutationResolver) DeletePlayer(ctx context.Context, name string) (*model.Player, error) {
-	user := auth.ForContext(ctx)
-	if user == nil {
-		return nil, fmt.Errorf("access denied")
+// RequestPasswordReset is the resolver for the requestPasswordReset field.
+func (r *mutationResolver) RequestPasswordReset(ctx context.Context, username string) (string, error) {
+	// Requests are counted before the account is looked up, so unknown
+	// usernames are throttled like real ones
+	allowed, err := r.AuthRepo.ReservePasswordReset(ctx, username, auth.ClientIP(ctx))
+
+	if err != nil {
+		return "", fmt.Errorf("could not request password reset: %w", err)
+	}
+	if !allowed {
+		return passwordResetSent, nil
 	}
 
-	err := db.DeletePlayer(ctx, name)
+	user, err := r.UserRepo.GetUser(ctx, username)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not delete player: %w", err)
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+
+	if user == nil || user.Email == nil {
+		return passwordResetSent, nil
+	}
+
+	token, tokenHash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
 	}
 
-	return &model.Player{Name: name}, nil
+	err = r.UserRepo.CreatePasswordReset(ctx, user.ID, tokenHash, time.Now().Add(passwordResetLifetime))
+
+	if err != nil {
+		return "", fmt.Errorf("could not create password reset: %w", err)
+	}
+
+	// The mail is sent in the background and a failed send is only logged,
+	// waiting for it or returning its error would tell the caller the
+	// account exists
+	go func(userId string, email string) {
+		ctx, cancel := context.WithTimeout(context.Background(), passwordResetSendTimeout)
+		defer cancel()
+
+		if err := r.Mailer.Send(ctx, email, "Reset your password", passwordResetBody(token)); err != nil {
+			log.Printf("could not send password reset to user id %s: %v", userId, err)
+		}
+	}(user.ID, *user.Email)
+
+	return passwordResetSent, nil
 }
 
-// Login is the resolver for the login field.
-func (r *mutationResolver) Login(ctx context.Context, input model.InputUser) (string, error) {
-	user := &model.User{
-		Username: input.Username,
-		Password: input.Password,
+// ResetPassword is the resolver for the resetPassword field.
+func (r *mutationResolver) ResetPassword(ctx context.Context, token string, newPassword string) (string, error) {
+	tokenHash := utils.HashOpaqueToken(token)
+
+	valid, err := r.UserRepo.PasswordResetValid(ctx, tokenHash)
+
+	if err != nil {
+		return "", fmt.Errorf("could not reset password: %w", err)
+	}
+	if !valid {
+		return "", apperr.New(apperr.Validation, "invalid or expired reset token")
+	}
+
+	hashedPassword, err := utils.HashPasswordContext(ctx, newPassword)
+	if err != nil {
+		return "", fmt.Errorf("could not hash user password: %w", err)
+	}
+
+	userId, username, err := r.UserRepo.ResetPassword(ctx, tokenHash, hashedPassword)
+
+	if err != nil {
+		return "", fmt.Errorf("could not reset password: %w", err)
+	}
+	if username == "" {
+		return "", apperr.New(apperr.Validation, "invalid or expired reset token")
+	}
+
+	auth.InvalidateUserStatus(userId)
+
+	// Proving access to the mailbox is enough to lift a lockout
+	if err := r.AuthRepo.ClearLoginFailures(ctx, username); err != nil {
+		return "", fmt.Errorf("could not clear login failures: %w", err)
+	}
+
+	if err := r.AuthRepo.InsertAuthAudit(ctx, db.AuditPasswordReset, username, auth.ClientIP(ctx), ""); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("Successfully reset password for %s", username), nil
+}
+
+// EraseMyAccount is the resolver for the eraseMyAccount field.
+func (r *mutationResolver) EraseMyAccount(ctx context.Context, password string, code *string) (string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return "", apperr.ErrUnauthenticated
 	}
 
-	correct, err := db.Authenticate(user)
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
+	if err != nil {
+		return "", fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	// Erasure cannot be undone, so the caller has to prove it is them and not
+	// just someone holding their token. Wrong passwords count towards the
+	// lockout like on login.
+	ip := auth.ClientIP(ctx)
+
+	err = r.AuthRepo.ReserveLoginAttempt(ctx, user.Username, ip)
+	if err != nil {
+		return "", err
+	}
 
+	correct, err := r.UserRepo.Authenticate(ctx, model.InputUser{Username: user.Username, Password: password})
 	if err != nil {
 		return "", fmt.Errorf("could not authenticate user %w", err)
 	}
+	if correct && user.TwoFactorEnabled != nil && *user.TwoFactorEnabled {
+		if code == nil {
+			return "", apperr.New(apperr.Validation, "a two factor code is required")
+		}
+		correct, err = r.verifySecondFactor(ctx, user, *code)
+		if err != nil {
+			return "", fmt.Errorf("could not verify code: %w", err)
+		}
+	}
 	if !correct {
-		return "", fmt.Errorf("could not authenticate user")
+		if err := r.AuthRepo.RecordLoginFailure(ctx, user.Username, ip); err != nil {
+			return "", err
+		}
+		return "", apperr.New(apperr.Unauthenticated, "could not authenticate user")
 	}
 
-	token, err := utils.GenerateToken(user.Username)
+	err = r.UserRepo.EraseUser(ctx, user.ID)
+
 	if err != nil {
-		return "", err
+		return "", fmt.Errorf("could not erase account: %w", err)
 	}
 
-	return token, nil
+	auth.InvalidateUserStatus(user.ID)
 
+	return "Your account and personal data have been erased", nil
 }
 
-// RefreshToken is the resolver for the refreshToken field.
-func (r *mutationResolver) RefreshToken(ctx context.Context, token string) (string, error) {
-	username, err := utils.ParseToken(token)
+// Player is the resolver for the player field.
+func (r *queryResolver) Player(ctx context.Context, name string) (*model.Player, error) {
+	member, err := requireOrg(ctx, false)
 	if err != nil {
-		return "", fmt.Errorf("access denied")
+		return nil, err
 	}
 
-	newToken, err := utils.GenerateToken(username)
+	player, err := r.PlayerRepo.GetPlayer(ctx, member.OrgID, name)
+
 	if err != nil {
-		return "", fmt.Errorf("could not generate token: %w", err)
+		return nil, fmt.Errorf("could not get player: %w", err)
 	}
-	return newToken, nil
+
+	return player, nil
 }
 
-// CreateUser is the resolver for the createUser field.
-func (r *mutationResolver) CreateUser(ctx context.Context, input model.InputUser) (string, error) {
-	err := db.CreateUsr(ctx, input)
+// Players is the resolver for the players field.
+func (r *queryResolver) Players(ctx context.Context, filter *model.PlayerFilter, first *int, after *string) (*model.PlayerConnection, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
 
+	size, err := pageSize(first)
 	if err != nil {
-		return "", fmt.Errorf("could no create user to db: %w", err)
+		return nil, err
+	}
+
+	afterName, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
 	}
 
-	token, err := utils.GenerateToken(input.Username)
+	players, hasNext, err := r.PlayerRepo.GetPlayers(ctx, member.OrgID, filter, size, afterName)
 
 	if err != nil {
-		return "", fmt.Errorf("could not generate token: %w", err)
+		return nil, fmt.Errorf("could not get players: %w", err)
+	}
+
+	connection := &model.PlayerConnection{
+		Edges:    make([]*model.PlayerEdge, len(players)),
+		PageInfo: &model.PageInfo{HasNextPage: hasNext},
+	}
+	for i, player := range players {
+		connection.Edges[i] = &model.PlayerEdge{Cursor: encodeCursor(player.Name), Node: player}
+	}
+	if len(players) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(players)-1].Cursor
 	}
 
-	return token, nil
+	return connection, nil
 }
 
-// UpdateUser is the resolver for the updateUser field.
-func (r *mutationResolver) UpdateUsername(ctx context.Context, user model.UpdateUsername) (string, error) {
+// GetUserID is the resolver for the getUserId field.
+func (r *queryResolver) GetUserID(ctx context.Context, username string) (string, error) {
 	userauth := auth.ForContext(ctx)
 	if userauth == nil {
-		return "", fmt.Errorf("access denied")
+		return "", apperr.ErrUnauthenticated
 	}
 
-	id, err := db.GetUserId(user.OldUsername)
+	id, err := r.UserRepo.GetUserId(ctx, username)
 
 	if err != nil {
-		return "", fmt.Errorf("could no get user id: %w", err)
+		return "", fmt.Errorf("could not get user id: %w", err)
 	}
 
-	user.ID = &id
+	// Only your own id, unless you are an admin, so accounts cannot be
+	// enumerated through this query
+	if id != userauth.ID && userauth.Role != model.RoleAdmin {
+		return "", apperr.ErrForbidden
+	}
 
-	dbErr := db.UpdateUsername(ctx, user)
+	return id, nil
+}
 
-	if dbErr != nil {
-		return "", fmt.Errorf("could not update username: %w", err)
+// User is the resolver for the user field.
+func (r *queryResolver) User(ctx context.Context, username string) (*model.User, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return nil, apperr.ErrUnauthenticated
 	}
 
-	return fmt.Sprintf("Successfully updated username for user id - %s", *user.ID), nil
+	user, err := r.UserRepo.GetUser(ctx, username)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return nil, apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	return user, nil
 }
 
-func (r *mutationResolver) UpdatePassword(ctx context.Context, user model.UpdatePassword) (string, error) {
+// Me is the resolver for the me field.
+func (r *queryResolver) Me(ctx context.Context) (*model.User, error) {
 	userauth := auth.ForContext(ctx)
 	if userauth == nil {
-		return "", fmt.Errorf("access denied")
+		return nil, apperr.ErrUnauthenticated
 	}
 
-	id, err := db.GetUserId(user.Username)
+	user, err := r.UserRepo.GetUserById(ctx, userauth.ID)
 
 	if err != nil {
-		return "", fmt.Errorf("could no get user id: %w", err)
+		return nil, fmt.Errorf("could not get user: %w", err)
+	}
+	if user == nil {
+		return nil, apperr.ErrUnauthenticated
 	}
 
-	user.ID = &id
+	return user, nil
+}
+
+// Settings is the resolver for the settings field.
+func (r *queryResolver) Settings(ctx context.Context) (*model.Settings, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
 
-	dbErr := db.UpdatePassword(ctx, user)
+	return r.getSettings(ctx)
+}
 
-	if dbErr != nil {
-		return "", fmt.Errorf("could not update password: %w", err)
+// Organizations is the resolver for the organizations field.
+func (r *queryResolver) Organizations(ctx context.Context) ([]*model.Organization, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return nil, apperr.ErrUnauthenticated
 	}
 
-	return fmt.Sprintf("Successfully updated password for user id - %s", *user.ID), nil
+	organizations, err := r.OrgRepo.GetOrganizations(ctx, userauth.ID, userauth.Role == model.RoleAdmin)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get organizations: %w", err)
+	}
+
+	return organizations, nil
 }
 
-// DeleteUser is the resolver for the deleteUser field.
-func (r *mutationResolver) DeleteUser(ctx context.Context, username string) (string, error) {
+// OrganizationMembers is the resolver for the organizationMembers field.
+func (r *queryResolver) OrganizationMembers(ctx context.Context, organizationID string) ([]*model.OrganizationMember, error) {
 	userauth := auth.ForContext(ctx)
 	if userauth == nil {
-		return "", fmt.Errorf("access denied")
+		return nil, apperr.ErrUnauthenticated
 	}
 
-	err := db.DeleteUser(ctx, username)
+	if userauth.Role != model.RoleAdmin {
+		role, err := r.OrgRepo.GetMembership(ctx, userauth.ID, organizationID)
+		if err != nil {
+			return nil, err
+		}
+		if role == "" {
+			return nil, apperr.ErrForbidden
+		}
+	}
+
+	members, err := r.OrgRepo.GetOrganizationMembers(ctx, organizationID)
 
 	if err != nil {
-		return "", fmt.Errorf("could not delete user: %w", err)
+		return nil, fmt.Errorf("could not get organization members: %w", err)
 	}
 
-	return fmt.Sprintf("Deleted user %s", username), nil
+	return members, nil
 }
 
-// Player is the resolver for the player field.
-func (r *queryResolver) Player(ctx context.Context, name string) (*model.Player, error) {
-	player, err := db.GetPlayer(ctx, name)
+// Users is the resolver for the users field.
+func (r *queryResolver) Users(ctx context.Context, filter *model.UserFilter, first *int, after *string) (*model.UserConnection, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
 
+	size, err := pageSize(first)
 	if err != nil {
-		return nil, fmt.Errorf("could not get player: %w", err)
+		return nil, err
 	}
 
-	return player, nil
+	afterUsername, err := decodeCursor(after)
+	if err != nil {
+		return nil, err
+	}
+
+	users, hasNext, err := r.UserRepo.GetUsers(ctx, filter, size, afterUsername)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get users: %w", err)
+	}
+
+	connection := &model.UserConnection{
+		Edges:    make([]*model.UserEdge, len(users)),
+		PageInfo: &model.PageInfo{HasNextPage: hasNext},
+	}
+	for i, user := range users {
+		connection.Edges[i] = &model.UserEdge{Cursor: encodeCursor(user.Username), Node: user}
+	}
+	if len(users) > 0 {
+		connection.PageInfo.EndCursor = &connection.Edges[len(users)-1].Cursor
+	}
+
+	return connection, nil
 }
 
-// GetUserID is the resolver for the getUserId field.
-func (r *queryResolver) GetUserID(ctx context.Context, username string) (string, error) {
-	id, err := db.GetUserId(username)
+// ExportMyData is the resolver for the exportMyData field.
+func (r *queryResolver) ExportMyData(ctx context.Context) (string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	export, err := r.UserRepo.ExportUserData(ctx, userauth.ID)
 
 	if err != nil {
-		return "", fmt.Errorf("could not get user id: %w", err)
+		return "", fmt.Errorf("could not export data: %w", err)
+	}
+	if export == nil {
+		return "", apperr.ErrUnauthenticated
 	}
 
-	return id, nil
+	exportJSON, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("could not export data: %w", err)
+	}
+
+	return string(exportJSON), nil
 }
 
-// User is the resolver for the user field.
-func (r *queryResolver) User(ctx context.Context, username string) (*model.User, error) {
-	user, err := db.GetUser(username)
+// DeletedPlayers is the resolver for the deletedPlayers field.
+func (r *queryResolver) DeletedPlayers(ctx context.Context) ([]*model.Player, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	players, err := r.PlayerRepo.GetDeletedPlayers(ctx, member.OrgID)
 
 	if err != nil {
-		return nil, fmt.Errorf("could not get user: %w", err)
+		return nil, fmt.Errorf("could not get deleted players: %w", err)
 	}
 
-	return user, nil
+	return players, nil
+}
+
+// PlayerHistory is the resolver for the playerHistory field.
+func (r *queryResolver) PlayerHistory(ctx context.Context, id string, first *int, after *string) (*model.PlayerAuditConnection, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
+	size, before, err := auditPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, hasNext, err := r.PlayerRepo.GetPlayerHistory(ctx, member.OrgID, id, size, before)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get player history: %w", err)
+	}
+
+	return auditConnection(entries, hasNext), nil
+}
+
+// AuditLog is the resolver for the auditLog field.
+func (r *queryResolver) AuditLog(ctx context.Context, organizationID *string, first *int, after *string) (*model.PlayerAuditConnection, error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	size, before, err := auditPage(first, after)
+	if err != nil {
+		return nil, err
+	}
+
+	orgId := ""
+	if organizationID != nil {
+		orgId = *organizationID
+	}
+
+	entries, hasNext, err := r.PlayerRepo.GetAuditLog(ctx, orgId, size, before)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get audit log: %w", err)
+	}
+
+	return auditConnection(entries, hasNext), nil
 }
 
 // Player is the resolver for the player field.
 func (r *subscriptionResolver) Player(ctx context.Context) (<-chan *model.Player, error) {
+	member, err := requireOrg(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+
 	ch := make(chan *model.Player)
 
-	go func() {
+	// A subscriber that went away stops taking players, so sends give up
+	// once its context is done
+	send := func(player *model.Player) {
+		select {
+		case ch <- player:
+		case <-ctx.Done():
+		}
+	}
 
-		sub, err := nat.Nc.Subscribe("create", func(m *nats.Msg) {
-			player := &model.Player{}
+	sub, err := nat.Nc.Subscribe(playerSubject(member.OrgID), func(m *nats.Msg) {
+		player := &model.Player{}
 
-			err := json.Unmarshal(m.Data, player)
-			if err != nil {
-				fmt.Println("could not umarshall created player: %w", err)
-				return
-			}
+		if err := json.Unmarshal(m.Data, player); err != nil {
+			log.Printf("could not unmarshal created player: %v", err)
+			return
+		}
 
-			ch <- player
-		})
+		send(player)
+	})
 
-		if err != nil {
-			close(ch)
-			fmt.Println("could not subscribe to created player: %w", err)
+	if err != nil {
+		return nil, fmt.Errorf("could not subscribe to created players: %w", err)
+	}
+
+	// Bulk mutations publish their players in one message
+	batchSub, err := nat.Nc.Subscribe(playerBatchSubject(member.OrgID), func(m *nats.Msg) {
+		players := []*model.Player{}
+
+		if err := json.Unmarshal(m.Data, &players); err != nil {
+			log.Printf("could not unmarshal created players: %v", err)
 			return
 		}
 
-		go func() {
-			<-ctx.Done()
-			sub.Unsubscribe()
-		}()
+		for _, player := range players {
+			send(player)
+		}
+	})
+
+	if err != nil {
+		sub.Unsubscribe()
+		return nil, fmt.Errorf("could not subscribe to created players: %w", err)
+	}
 
+	go func() {
+		<-ctx.Done()
+		sub.Unsubscribe()
+		batchSub.Unsubscribe()
 	}()
 
 	return ch, nil
@@ -293,6 +1443,12 @@ func (r *subscriptionResolver) Player(ctx context.Context) (<-chan *model.Player
 // Mutation returns MutationResolver implementation.
 func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
 
+// Player returns PlayerResolver implementation.
+func (r *Resolver) Player() PlayerResolver { return &playerResolver{r} }
+
+// Profile returns ProfileResolver implementation.
+func (r *Resolver) Profile() ProfileResolver { return &profileResolver{r} }
+
 // Query returns QueryResolver implementation.
 func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 
@@ -300,5 +1456,7 @@ func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
 func (r *Resolver) Subscription() SubscriptionResolver { return &subscriptionResolver{r} }
 
 type mutationResolver struct{ *Resolver }
+type playerResolver struct{ *Resolver }
+type profileResolver struct{ *Resolver }
 type queryResolver struct{ *Resolver }
 type subscriptionResolver struct{ *Resolver }
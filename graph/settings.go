@@ -0,0 +1,61 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+// maxInvitationLifetime caps expiresIn so invitations cannot be left lying
+// around indefinitely.
+const maxInvitationLifetime = 30 * 24 * time.Hour
+
+func (r *Resolver) getSettings(ctx context.Context) (*model.Settings, error) {
+	required, err := r.SettingsRepo.RequireTwoFactor(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get settings: %w", err)
+	}
+
+	mode, err := r.SettingsRepo.GetRegistrationMode(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not get settings: %w", err)
+	}
+
+	return &model.Settings{RequireTwoFactorForPrivilegedRoles: required, RegistrationMode: mode}, nil
+}
+
+// checkRegistration decides whether createUser may go ahead in the current
+// registration mode and returns the hash of the invitation to use up, if any.
+func (r *Resolver) checkRegistration(ctx context.Context, invitation *string) (string, error) {
+	mode, err := r.SettingsRepo.GetRegistrationMode(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case mode == model.RegistrationModeClosed:
+		return "", apperr.New(apperr.Forbidden, "registration is closed")
+	case invitation == nil || *invitation == "":
+		if mode == model.RegistrationModeInviteOnly {
+			return "", apperr.New(apperr.Forbidden, "registration requires an invitation")
+		}
+		return "", nil
+	}
+
+	codeHash := utils.HashOpaqueToken(*invitation)
+
+	valid, err := r.UserRepo.InvitationValid(ctx, codeHash)
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return "", db.ErrInvalidInvitation
+	}
+
+	return codeHash, nil
+}
@@ -0,0 +1,48 @@
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+const loginChallengeLifetime = 5 * time.Minute
+
+var errTwoFactorSetupRequired = apperr.New(apperr.Forbidden, "set up two factor authentication and log in again to make changes")
+
+// isPrivileged reports whether a global role or a role in an organization can
+// change players or manage users, which makes two factor authentication
+// mandatory when it is required.
+func isPrivileged(role model.Role, orgRole model.OrgRole) bool {
+	return role == model.RoleAdmin || role == model.RoleEditor ||
+		orgRole == model.OrgRoleAdmin || orgRole == model.OrgRoleEditor
+}
+
+// verifySecondFactor accepts either a current TOTP code that has not been
+// used yet or an unused recovery code.
+func (r *Resolver) verifySecondFactor(ctx context.Context, user *model.User, code string) (bool, error) {
+	secret, enabled, err := r.AuthRepo.GetTotp(ctx, user.ID)
+	if err != nil || !enabled {
+		return false, err
+	}
+
+	if step, ok := utils.ValidateTotp(secret, code, time.Now()); ok {
+		return r.AuthRepo.UseTotpStep(ctx, user.ID, step)
+	}
+
+	used, err := r.AuthRepo.UseRecoveryCode(ctx, user.ID, utils.HashRecoveryCode(code))
+	if err != nil || !used {
+		return false, err
+	}
+
+	if err := r.AuthRepo.InsertAuthAudit(ctx, db.AuditRecoveryCode, user.Username, auth.ClientIP(ctx), user.ID); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,90 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	auth "github.com/mattmazer1/graphql-api/middleware"
+)
+
+// requireAdmin returns the caller if they have the global admin role.
+func requireAdmin(ctx context.Context) (*model.User, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return nil, apperr.ErrUnauthenticated
+	}
+	if userauth.Role != model.RoleAdmin {
+		return nil, apperr.ErrForbidden
+	}
+
+	return userauth, nil
+}
+
+// isCaller reports whether username belongs to the caller. The username in a
+// token goes stale once the user is renamed, so the user is matched by id.
+func (r *Resolver) isCaller(ctx context.Context, userauth *model.User, username string) (bool, error) {
+	id, err := r.UserRepo.GetUserId(ctx, username)
+	if err != nil {
+		return false, fmt.Errorf("could not get user id: %w", err)
+	}
+
+	return id != "" && id == userauth.ID, nil
+}
+
+// requireSelfOrAdmin returns the id of the user with a username if it is the
+// caller or the caller is an admin. Other callers cannot tell whether the user
+// exists.
+func (r *Resolver) requireSelfOrAdmin(ctx context.Context, username string) (string, error) {
+	userauth := auth.ForContext(ctx)
+	if userauth == nil {
+		return "", apperr.ErrUnauthenticated
+	}
+
+	id, err := r.UserRepo.GetUserId(ctx, username)
+	if err != nil {
+		return "", fmt.Errorf("could not get user id: %w", err)
+	}
+
+	if userauth.Role != model.RoleAdmin && (id == "" || id != userauth.ID) {
+		return "", apperr.ErrForbidden
+	}
+	if id == "" {
+		return "", apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	return id, nil
+}
+
+// setUserDisabled backs disableUser and enableUser. Admins cannot disable
+// themselves, so there is always someone left to enable accounts again.
+func (r *Resolver) setUserDisabled(ctx context.Context, username string, disabled bool) (*model.User, error) {
+	userauth, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if disabled {
+		self, err := r.isCaller(ctx, userauth, username)
+		if err != nil {
+			return nil, err
+		}
+		if self {
+			return nil, apperr.New(apperr.Forbidden, "you cannot disable your own account")
+		}
+	}
+
+	id, err := r.UserRepo.SetUserDisabled(ctx, username, disabled, userauth.ID)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update user: %w", err)
+	}
+	if id == "" {
+		return nil, apperr.Errorf(apperr.NotFound, "user %s not found", username)
+	}
+
+	auth.InvalidateUserStatus(id)
+
+	return r.UserRepo.GetUserById(ctx, id)
+}
@@ -0,0 +1,49 @@
+package graph
+
+import (
+	"errors"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+// validationError is a VALIDATION error listing every failing field of an
+// input, with paths starting at argument when it is set.
+func validationError(invalid *validate.Error, argument string) *apperr.Error {
+	fields := invalid.Fields
+	if argument != "" {
+		fields = invalid.Prefix(argument)
+	}
+
+	return &apperr.Error{
+		Code:       apperr.Validation,
+		Message:    invalid.Error(),
+		Extensions: map[string]interface{}{"fields": fields},
+	}
+}
+
+// argumentError adds argument to the paths of an input that failed
+// validation. Other errors are returned as they are.
+func argumentError(err error, argument string) error {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return err
+	}
+	return validationError(invalid, argument)
+}
+
+// fieldErrors returns the failing fields of an input that failed validation,
+// or nil for any other error.
+func fieldErrors(err error) []*model.FieldError {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	fields := make([]*model.FieldError, len(invalid.Fields))
+	for i, field := range invalid.Fields {
+		fields[i] = &model.FieldError{Path: field.Path, Message: field.Message}
+	}
+	return fields
+}
@@ -0,0 +1,79 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+)
+
+// Mailer delivers a plain text message to a single recipient.
+type Mailer interface {
+	Send(ctx context.Context, to string, subject string, body string) error
+}
+
+// SMTPMailer sends mail through an SMTP relay.
+type SMTPMailer struct {
+	Addr string
+	From string
+	Auth smtp.Auth
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// Refuse header injection through the recipient or subject
+	if strings.ContainsAny(to, "\r\n") || strings.ContainsAny(subject, "\r\n") {
+		return fmt.Errorf("invalid mail header")
+	}
+
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		m.From, to, subject, body)
+
+	if err := smtp.SendMail(m.Addr, m.Auth, m.From, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("could not send mail: %w", err)
+	}
+
+	return nil
+}
+
+// LogMailer logs that a message was sent instead of sending it, for local
+// development and tests. The body is left out of the log, it can hold a
+// live token.
+type LogMailer struct{}
+
+func (m *LogMailer) Send(ctx context.Context, to string, subject string, body string) error {
+	log.Printf("mail to %s: %s", to, subject)
+	return nil
+}
+
+// NewMailer returns an SMTPMailer when SMTP_HOST is set and a LogMailer
+// otherwise.
+func NewMailer() Mailer {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Printf("SMTP_HOST not set, mail will be logged")
+		return &LogMailer{}
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "no-reply@" + host
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	return &SMTPMailer{Addr: host + ":" + port, From: from, Auth: auth}
+}
@@ -2,14 +2,31 @@ package middleware
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
-	db "github.com/mattmazer1/graphql-api/database"
 	"github.com/mattmazer1/graphql-api/graph/model"
 	"github.com/mattmazer1/graphql-api/utils"
 )
 
 var UserCtxKey = &contextKey{"user"}
+var OrgCtxKey = &contextKey{"org"}
+var TwoFactorSetupCtxKey = &contextKey{"tfaSetup"}
+
+// Membership is the organization a request acts in and the caller's role in
+// it, as carried by their token.
+type Membership struct {
+	OrgID string
+	Role  model.OrgRole
+}
+
+// CanEdit reports whether the role may change the organization's players.
+func (m *Membership) CanEdit() bool {
+	return m.Role == model.OrgRoleEditor || m.Role == model.OrgRoleAdmin
+}
 
 type contextKey struct {
 	name string
@@ -26,26 +43,22 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 				return
 			}
 
-			// Validate jwt token
-			tokenStr := header
-			username, err := utils.ParseToken(tokenStr)
-			if err != nil {
+			// Validate jwt token and put the user in context
+			ctx, _, err := ContextFromToken(r.Context(), header)
+			if errors.Is(err, ErrAccountDisabled) {
+				http.Error(w, "Account disabled", http.StatusForbidden)
+				return
+			}
+			if errors.Is(err, ErrTokenRevoked) || errors.Is(err, ErrInvalidToken) {
 				http.Error(w, "Invalid token", http.StatusForbidden)
 				return
 			}
-
-			// Create user and check if user exists in db
-			user := model.User{Username: username}
-			id, err := db.GetUserId(username)
 			if err != nil {
-				next.ServeHTTP(w, r)
+				log.Printf("could not authenticate request: %v", err)
+				http.Error(w, "Internal server error", http.StatusInternalServerError)
 				return
 			}
 
-			user.ID = id
-			// Put it in context
-			ctx := context.WithValue(r.Context(), UserCtxKey, &user)
-
 			// Call the next with our new context
 			r = r.WithContext(ctx)
 			next.ServeHTTP(w, r)
@@ -53,7 +66,46 @@ func AuthMiddleware() func(http.Handler) http.Handler {
 	}
 }
 
+// ContextFromToken validates a jwt token and puts the user it was issued to,
+// and their organization if they have one, on the context. The token carries
+// everything we need apart from whether it is still good, which
+// CheckUserStatus answers mostly from its cache.
+func ContextFromToken(ctx context.Context, tokenStr string) (context.Context, *utils.Claims, error) {
+	claims, err := utils.ParseToken(strings.TrimPrefix(tokenStr, "Bearer "))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	if err := CheckUserStatus(ctx, claims); err != nil {
+		return nil, nil, err
+	}
+
+	user := &model.User{ID: claims.Subject, Username: claims.Username, Role: model.Role(claims.Role)}
+	ctx = context.WithValue(ctx, UserCtxKey, user)
+
+	if claims.Org != "" {
+		ctx = context.WithValue(ctx, OrgCtxKey, &Membership{OrgID: claims.Org, Role: model.OrgRole(claims.OrgRole)})
+	}
+	if claims.TwoFactorSetup {
+		ctx = context.WithValue(ctx, TwoFactorSetupCtxKey, true)
+	}
+
+	return ctx, claims, nil
+}
+
 func ForContext(ctx context.Context) *model.User {
 	raw, _ := ctx.Value(UserCtxKey).(*model.User)
 	return raw
 }
+
+// TwoFactorSetupRequired reports whether the caller's token was issued before
+// they set up the two factor authentication their role requires.
+func TwoFactorSetupRequired(ctx context.Context) bool {
+	required, _ := ctx.Value(TwoFactorSetupCtxKey).(bool)
+	return required
+}
+
+func OrgForContext(ctx context.Context) *Membership {
+	raw, _ := ctx.Value(OrgCtxKey).(*Membership)
+	return raw
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+var IpCtxKey = &contextKey{"ip"}
+
+// ClientIPMiddleware puts the caller's address on the request context. When
+// TRUST_PROXY is set the address appended to X-Forwarded-For by our load
+// balancer is used, since RemoteAddr is then the balancer itself.
+func ClientIPMiddleware() func(http.Handler) http.Handler {
+	trustProxy := os.Getenv("TRUST_PROXY") == "true"
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+
+			if forwarded := r.Header.Get("X-Forwarded-For"); trustProxy && forwarded != "" {
+				// Only the last hop was added by our balancer, anything before
+				// it is whatever the client sent
+				hops := strings.Split(forwarded, ",")
+				ip = strings.TrimSpace(hops[len(hops)-1])
+			}
+
+			ctx := context.WithValue(r.Context(), IpCtxKey, ip)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func ClientIP(ctx context.Context) string {
+	raw, _ := ctx.Value(IpCtxKey).(string)
+	return raw
+}
@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+var (
+	ErrInvalidToken    = apperr.New(apperr.Unauthenticated, "invalid token")
+	ErrAccountDisabled = apperr.New(apperr.Forbidden, "account disabled")
+	ErrTokenRevoked    = apperr.New(apperr.Unauthenticated, "token revoked")
+)
+
+// userStatusTTL is how long a user's status is cached, so most requests do
+// not touch the database. Disabling a user or ending their sessions takes
+// effect at once on the instance that did it and within the TTL elsewhere.
+var userStatusTTL = getUserStatusTTL()
+
+// maxCachedStatuses bounds the cache, expired entries are dropped once it
+// grows past this
+const maxCachedStatuses = 10000
+
+// StatusSource looks up the status of a token's user.
+type StatusSource interface {
+	GetUserStatus(ctx context.Context, id string) (*repository.UserStatus, error)
+}
+
+// statusSource is where uncached statuses come from, set with
+// SetStatusSource before serving requests.
+var statusSource StatusSource
+
+func SetStatusSource(source StatusSource) {
+	statusSource = source
+}
+
+type cachedStatus struct {
+	status  *repository.UserStatus
+	fetched time.Time
+}
+
+var userStatuses = struct {
+	sync.Mutex
+	m map[string]cachedStatus
+}{m: map[string]cachedStatus{}}
+
+// getUserStatusTTL reads USER_STATUS_TTL, 30 seconds by default.
+func getUserStatusTTL() time.Duration {
+	if ttl, err := time.ParseDuration(os.Getenv("USER_STATUS_TTL")); err == nil && ttl >= 0 {
+		return ttl
+	}
+	return 30 * time.Second
+}
+
+// CheckUserStatus rejects tokens of users that were deleted or disabled, and
+// tokens issued before the user's sessions were ended.
+func CheckUserStatus(ctx context.Context, claims *utils.Claims) error {
+	status, err := userStatus(ctx, claims.Subject)
+	if err != nil {
+		return err
+	}
+
+	if !status.Exists {
+		return ErrTokenRevoked
+	}
+	if status.Disabled {
+		return ErrAccountDisabled
+	}
+
+	// iat only has second precision, so it is compared with the second the
+	// sessions were ended in. A token issued in that second is accepted,
+	// otherwise logging in right after a password reset would be rejected.
+	if claims.IssuedAt != nil && !status.TokensValidAfter.IsZero() &&
+		claims.IssuedAt.Time.Before(status.TokensValidAfter.Truncate(time.Second)) {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+func userStatus(ctx context.Context, userId string) (*repository.UserStatus, error) {
+	userStatuses.Lock()
+	cached, ok := userStatuses.m[userId]
+	userStatuses.Unlock()
+
+	if ok && time.Since(cached.fetched) < userStatusTTL {
+		return cached.status, nil
+	}
+
+	if statusSource == nil {
+		return nil, errors.New("could not check user: no status source")
+	}
+
+	status, err := statusSource.GetUserStatus(ctx, userId)
+	if err != nil {
+		return nil, fmt.Errorf("could not check user: %w", err)
+	}
+
+	now := time.Now()
+
+	userStatuses.Lock()
+	if len(userStatuses.m) >= maxCachedStatuses {
+		for id, entry := range userStatuses.m {
+			if now.Sub(entry.fetched) >= userStatusTTL {
+				delete(userStatuses.m, id)
+			}
+		}
+	}
+	userStatuses.m[userId] = cachedStatus{status: status, fetched: now}
+	userStatuses.Unlock()
+
+	return status, nil
+}
+
+// InvalidateUserStatus drops a user's cached status after it was changed.
+func InvalidateUserStatus(userId string) {
+	userStatuses.Lock()
+	delete(userStatuses.m, userId)
+	userStatuses.Unlock()
+}
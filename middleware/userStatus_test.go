@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+type fixedStatus struct {
+	status *repository.UserStatus
+}
+
+func (s fixedStatus) GetUserStatus(ctx context.Context, id string) (*repository.UserStatus, error) {
+	return s.status, nil
+}
+
+func TestCheckUserStatusRevocation(t *testing.T) {
+	revokedAt := time.Date(2023, 5, 1, 12, 0, 0, 600*int(time.Millisecond), time.UTC)
+	SetStatusSource(fixedStatus{&repository.UserStatus{Exists: true, TokensValidAfter: revokedAt}})
+	t.Cleanup(func() { SetStatusSource(nil) })
+
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		wantErr  error
+	}{
+		{"issued the second before", revokedAt.Add(-time.Second).Truncate(time.Second), ErrTokenRevoked},
+		{"issued in the same second", revokedAt.Truncate(time.Second), nil},
+		{"issued after", revokedAt.Add(time.Second).Truncate(time.Second), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			InvalidateUserStatus("user")
+			claims := &utils.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "user", IssuedAt: jwt.NewNumericDate(tt.issuedAt)}}
+			if err := CheckUserStatus(context.Background(), claims); err != tt.wantErr {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
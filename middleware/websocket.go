@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+)
+
+// WebsocketInit authenticates a websocket connection from its connection_init
+// payload, since browsers cannot set headers on the upgrade request. The token
+// is read from "Authorization" or "token" and checked the same way as the
+// Authorization header. A connection without a token stays as it was upgraded.
+func WebsocketInit(ctx context.Context, payload transport.InitPayload) (context.Context, error) {
+	tokenStr := payload.Authorization()
+	if tokenStr == "" {
+		tokenStr = payload.GetString("token")
+	}
+
+	if tokenStr == "" {
+		return ctx, nil
+	}
+
+	ctx, claims, err := ContextFromToken(ctx, tokenStr)
+	if errors.Is(err, ErrAccountDisabled) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// Close the connection when the token expires instead of letting it stay
+	// authenticated for as long as the socket is open
+	ctx = transport.AppendCloseReason(ctx, "token expired")
+	ctx, cancel := context.WithDeadline(ctx, claims.ExpiresAt.Time)
+	go func() {
+		<-ctx.Done()
+		cancel()
+	}()
+
+	return ctx, nil
+}
@@ -0,0 +1,106 @@
+package playercsv
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/middleware"
+	"github.com/mattmazer1/graphql-api/repository"
+)
+
+// flushEvery is how many rows are written before they are flushed to the
+// client
+const flushEvery = 100
+
+// ExportHandler returns a handler serving the players of the caller's
+// organization as CSV. It takes the fields of PlayerFilter as query
+// parameters and streams rows as they are read, so large exports are not held
+// in memory.
+func ExportHandler(players repository.PlayerRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		export(players, w, r)
+	}
+}
+
+func export(players repository.PlayerRepository, w http.ResponseWriter, r *http.Request) {
+	if middleware.ForContext(r.Context()) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	member := middleware.OrgForContext(r.Context())
+	if member == nil {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	filter := &model.PlayerFilter{}
+	query := r.URL.Query()
+	if name := query.Get("name"); name != "" {
+		filter.Name = &name
+	}
+	// pos takes a position group or a lineup position, both matching the
+	// players eligible for it
+	if pos := query.Get("pos"); pos != "" {
+		group := model.Position(pos)
+		position := model.LineupPosition(pos)
+		switch {
+		case group.IsValid():
+			filter.Pos = &group
+		case position.IsValid():
+			filter.Position = &position
+		default:
+			http.Error(w, "Invalid pos", http.StatusBadRequest)
+			return
+		}
+	}
+	if season := query.Get("season"); season != "" {
+		filter.Season = &season
+	}
+	if seasonType := model.SeasonType(query.Get("seasonType")); seasonType != "" {
+		if !seasonType.IsValid() {
+			http.Error(w, "Invalid seasonType", http.StatusBadRequest)
+			return
+		}
+		filter.SeasonType = &seasonType
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="players.csv"`)
+
+	writer := NewWriter(w)
+	flusher, _ := w.(http.Flusher)
+	written := 0
+
+	err := writer.WriteHeader()
+	if err == nil {
+		err = players.StreamPlayers(r.Context(), member.OrgID, filter, func(player *model.Player) error {
+			if err := writer.Write(player); err != nil {
+				return err
+			}
+
+			written++
+			if written%flushEvery == 0 {
+				if err := writer.Flush(); err != nil {
+					return err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+			}
+
+			return nil
+		})
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+
+	// Once rows have been sent the status cannot change, so the connection is
+	// dropped to keep the client from taking a partial file for a full one
+	if err != nil {
+		log.Printf("could not export players: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
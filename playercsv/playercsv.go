@@ -0,0 +1,435 @@
+// Package playercsv reads and writes players as CSV, with one column per
+// InputPlayer and InputStats field named like the GraphQL field.
+package playercsv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+// Columns are the columns of an export, in order. Imports accept them in any
+// order, ignoring case and a "stats." prefix.
+var Columns = []string{
+	"name",
+	"pos",
+	"primaryPosition",
+	"eligiblePositions",
+	"age",
+	"experience",
+	"season",
+	"seasonType",
+	"points",
+	"threePt",
+	"rebounds",
+	"assists",
+	"steals",
+	"blocks",
+	"turnOvers",
+	"mp",
+	"gamesPlayed",
+	"birthDate",
+	"height",
+	"weight",
+	"college",
+	"country",
+	"draftYear",
+	"draftRound",
+	"draftPick",
+	"debutSeason",
+	"jerseyNumber",
+	"handedness",
+}
+
+// RowError is a problem with one row of an import. Row counts records from
+// 1, which is the header, and Column is empty for errors about a whole row.
+type RowError struct {
+	Row     int
+	Column  string
+	Message string
+}
+
+// Row is a row of an import that could be read into a player.
+type Row struct {
+	Row    int
+	Player model.InputPlayer
+}
+
+func normalizeColumn(column string) string {
+	column = strings.ToLower(strings.TrimSpace(column))
+	return strings.TrimPrefix(column, "stats.")
+}
+
+// optionalColumns may be left out of an import or left empty in a row, like
+// the fields they map to may be in the API. seasonType then defaults to
+// REGULAR.
+var optionalColumns = map[string]bool{
+	"pos":               true,
+	"primaryPosition":   true,
+	"eligiblePositions": true,
+	"age":               true,
+	"experience":        true,
+	"seasonType":        true,
+	"gamesPlayed":       true,
+	"birthDate":         true,
+	"height":            true,
+	"weight":            true,
+	"college":           true,
+	"country":           true,
+	"draftYear":         true,
+	"draftRound":        true,
+	"draftPick":         true,
+	"debutSeason":       true,
+	"jerseyNumber":      true,
+	"handedness":        true,
+}
+
+// Read reads an import. Rows with problems are left out and reported as row
+// errors, so one call reports every problem in the file. A header that does
+// not map onto the columns is reported on row 1 without reading further.
+// The error is for files that cannot be read at all or have more than
+// maxRows rows.
+func Read(r io.Reader, maxRows int) ([]Row, []RowError, error) {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, nil, apperr.New(apperr.Validation, "the file is empty")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read header: %w", err)
+	}
+
+	known := map[string]string{}
+	for _, column := range Columns {
+		known[strings.ToLower(column)] = column
+	}
+
+	// index of each column in the file
+	index := map[string]int{}
+	headerErrors := []RowError{}
+	for i, raw := range header {
+		column, ok := known[normalizeColumn(raw)]
+		switch {
+		case !ok:
+			headerErrors = append(headerErrors, RowError{Row: 1, Column: raw, Message: "unknown column"})
+		case index[column] != 0:
+			headerErrors = append(headerErrors, RowError{Row: 1, Column: raw, Message: "duplicate column"})
+		default:
+			index[column] = i + 1
+		}
+	}
+	for _, column := range Columns {
+		if index[column] == 0 && !optionalColumns[column] {
+			headerErrors = append(headerErrors, RowError{Row: 1, Column: column, Message: "missing column"})
+		}
+	}
+	if len(headerErrors) > 0 {
+		return nil, headerErrors, nil
+	}
+
+	rows := []Row{}
+	rowErrors := []RowError{}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if row-1 > maxRows {
+			return nil, nil, apperr.Errorf(apperr.Validation, "an import takes at most %d rows", maxRows)
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErrors = append(rowErrors, RowError{Row: row, Message: parseErr.Err.Error()})
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read row %d: %w", row, err)
+		}
+
+		values := map[string]string{}
+		for column, i := range index {
+			values[column] = unescapeCell(strings.TrimSpace(record[i-1]))
+		}
+
+		player, errs := parseRow(row, values)
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		rows = append(rows, Row{Row: row, Player: player})
+	}
+
+	return rows, rowErrors, nil
+}
+
+// parseRow maps the values of a row onto a player, collecting an error for
+// every value that does not parse.
+func parseRow(row int, values map[string]string) (model.InputPlayer, []RowError) {
+	errs := []RowError{}
+
+	text := func(column string) string {
+		if values[column] == "" && !optionalColumns[column] {
+			errs = append(errs, RowError{Row: row, Column: column, Message: "is required"})
+		}
+		return values[column]
+	}
+	optionalText := func(column string) *string {
+		if value := text(column); value != "" {
+			return &value
+		}
+		return nil
+	}
+	integer := func(column string) *int {
+		value := text(column)
+		if value == "" {
+			return nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, RowError{Row: row, Column: column, Message: "must be a whole number"})
+		}
+		return &n
+	}
+	float := func(column string) float64 {
+		value := text(column)
+		if value == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			errs = append(errs, RowError{Row: row, Column: column, Message: "must be a number"})
+		}
+		return f
+	}
+
+	player := model.InputPlayer{
+		Name:       text("name"),
+		Age:        integer("age"),
+		Experience: integer("experience"),
+		Profile: &model.InputProfile{
+			BirthDate:    optionalText("birthDate"),
+			Height:       integer("height"),
+			Weight:       integer("weight"),
+			College:      optionalText("college"),
+			Country:      optionalText("country"),
+			DraftYear:    integer("draftYear"),
+			DraftRound:   integer("draftRound"),
+			DraftPick:    integer("draftPick"),
+			DebutSeason:  optionalText("debutSeason"),
+			JerseyNumber: integer("jerseyNumber"),
+		},
+		Stats: &model.InputStats{
+			Season:      text("season"),
+			SeasonType:  model.SeasonTypeRegular,
+			Points:      float("points"),
+			ThreePt:     float("threePt"),
+			Rebounds:    float("rebounds"),
+			Assists:     float("assists"),
+			Steals:      float("steals"),
+			Blocks:      float("blocks"),
+			TurnOvers:   float("turnOvers"),
+			Mp:          float("mp"),
+			GamesPlayed: integer("gamesPlayed"),
+		},
+	}
+
+	if value := values["pos"]; value != "" {
+		pos := model.Position(strings.ToLower(value))
+		player.Pos = &pos
+		if !pos.IsValid() {
+			errs = append(errs, RowError{Row: row, Column: "pos", Message: fmt.Sprintf("%q is not a valid position", value)})
+		}
+	}
+	if value := values["primaryPosition"]; value != "" {
+		primary := model.LineupPosition(strings.ToUpper(value))
+		player.PrimaryPosition = &primary
+		if !primary.IsValid() {
+			errs = append(errs, RowError{Row: row, Column: "primaryPosition", Message: fmt.Sprintf("%q is not a valid position", value)})
+		}
+	}
+	// Eligible positions are written like "PG/SG"
+	if value := values["eligiblePositions"]; value != "" {
+		for _, raw := range strings.Split(value, "/") {
+			position := model.LineupPosition(strings.ToUpper(strings.TrimSpace(raw)))
+			player.EligiblePositions = append(player.EligiblePositions, position)
+			if !position.IsValid() {
+				errs = append(errs, RowError{Row: row, Column: "eligiblePositions", Message: fmt.Sprintf("%q is not a valid position", raw)})
+			}
+		}
+	}
+	if value := values["seasonType"]; value != "" {
+		player.Stats.SeasonType = model.SeasonType(strings.ToUpper(value))
+		if !player.Stats.SeasonType.IsValid() {
+			errs = append(errs, RowError{Row: row, Column: "seasonType", Message: fmt.Sprintf("%q is not a valid season type", value)})
+		}
+	}
+
+	if value := values["handedness"]; value != "" {
+		handedness := model.Handedness(strings.ToLower(value))
+		player.Profile.Handedness = &handedness
+		if !handedness.IsValid() {
+			errs = append(errs, RowError{Row: row, Column: "handedness", Message: fmt.Sprintf("%q is not a valid handedness", value)})
+		}
+	}
+
+	// Values that parsed still have to make sense for a player
+	if len(errs) == 0 {
+		errs = ValidationErrors(row, validate.Player(player))
+	}
+
+	return player, errs
+}
+
+// ValidationErrors turns the field errors of a player that failed validation
+// into errors about the columns of its row.
+func ValidationErrors(row int, err error) []RowError {
+	var invalid *validate.Error
+	if !errors.As(err, &invalid) {
+		return nil
+	}
+
+	errs := make([]RowError, len(invalid.Fields))
+	for i, field := range invalid.Fields {
+		errs[i] = RowError{Row: row, Column: field.Path[strings.LastIndex(field.Path, ".")+1:], Message: field.Message}
+	}
+	return errs
+}
+
+// formulaPrefixes start a formula when a spreadsheet opens the file.
+const formulaPrefixes = "=+-@\t\r"
+
+// escapeCell keeps a spreadsheet from running a value as a formula by
+// putting a ' in front of it, which spreadsheets do not show.
+func escapeCell(value string) string {
+	if value != "" && strings.ContainsRune(formulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCell takes off the ' escapeCell put in front of a value, so an
+// export can be imported again as it is.
+func unescapeCell(value string) string {
+	if len(value) > 1 && value[0] == '\'' && strings.ContainsRune(formulaPrefixes, rune(value[1])) {
+		return value[1:]
+	}
+	return value
+}
+
+// Writer writes players in the export format. Values that a spreadsheet
+// would take for a formula are escaped.
+type Writer struct {
+	w *csv.Writer
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: csv.NewWriter(w)}
+}
+
+func (w *Writer) WriteHeader() error {
+	return w.w.Write(Columns)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (w *Writer) Write(player *model.Player) error {
+	profile := player.Profile
+	if profile == nil {
+		profile = &model.Profile{}
+	}
+
+	// The stored age and experience are left out for players whose profile
+	// replaces them, like they are when such a player is created
+	age := strconv.Itoa(player.Age)
+	if profile.BirthDate != nil {
+		age = ""
+	}
+	experience := strconv.Itoa(player.Experience)
+	if profile.DraftYear != nil || profile.DebutSeason != nil {
+		experience = ""
+	}
+
+	handedness := ""
+	if profile.Handedness != nil {
+		handedness = string(*profile.Handedness)
+	}
+
+	primary := ""
+	if player.PrimaryPosition != nil {
+		primary = string(*player.PrimaryPosition)
+	}
+	eligible := make([]string, len(player.EligiblePositions))
+	for i, position := range player.EligiblePositions {
+		eligible[i] = string(position)
+	}
+
+	record := []string{
+		player.Name,
+		string(player.Pos),
+		primary,
+		strings.Join(eligible, "/"),
+		age,
+		experience,
+		player.Stats.Season,
+		string(player.Stats.SeasonType),
+		formatFloat(player.Stats.Points),
+		formatFloat(player.Stats.ThreePt),
+		formatFloat(player.Stats.Rebounds),
+		formatFloat(player.Stats.Assists),
+		formatFloat(player.Stats.Steals),
+		formatFloat(player.Stats.Blocks),
+		formatFloat(player.Stats.TurnOvers),
+		formatFloat(player.Stats.Mp),
+		formatInt(player.Stats.GamesPlayed),
+		formatText(profile.BirthDate),
+		formatInt(profile.Height),
+		formatInt(profile.Weight),
+		formatText(profile.College),
+		formatText(profile.Country),
+		formatInt(profile.DraftYear),
+		formatInt(profile.DraftRound),
+		formatInt(profile.DraftPick),
+		formatText(profile.DebutSeason),
+		formatInt(profile.JerseyNumber),
+		handedness,
+	}
+	for i, value := range record {
+		record[i] = escapeCell(value)
+	}
+
+	return w.w.Write(record)
+}
+
+func formatText(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+func formatInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+// Flush writes buffered rows to the underlying writer.
+func (w *Writer) Flush() error {
+	w.w.Flush()
+	return w.w.Error()
+}
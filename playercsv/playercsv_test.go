@@ -0,0 +1,52 @@
+package playercsv
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+func TestFormulasAreEscaped(t *testing.T) {
+	primary := model.LineupPositionPg
+	college := `=HYPERLINK("http://example.com","Duke")`
+	player := &model.Player{
+		Name:            "@Ada",
+		Pos:             model.PositionGuard,
+		PrimaryPosition: &primary,
+		Age:             25,
+		Experience:      3,
+		Profile:         &model.Profile{College: &college},
+		Stats:           &model.Stats{Season: "2022-23", SeasonType: model.SeasonTypeRegular, Points: 20, Mp: 30},
+	}
+
+	var buf bytes.Buffer
+	writer := NewWriter(&buf)
+	if err := writer.WriteHeader(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Write(player); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	exported := buf.String()
+	if !strings.Contains(exported, "'@Ada") || !strings.Contains(exported, `"'=HYPERLINK(`) {
+		t.Fatalf("got export %q, want the name and college escaped", exported)
+	}
+
+	// The export imports as the player it was written from
+	rows, rowErrors, err := Read(strings.NewReader(exported), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rowErrors) > 0 {
+		t.Fatalf("got row errors %+v", rowErrors)
+	}
+	if len(rows) != 1 || rows[0].Player.Name != "@Ada" || *rows[0].Player.Profile.College != college {
+		t.Fatalf("got rows %+v, want @Ada from %s", rows, college)
+	}
+}
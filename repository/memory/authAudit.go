@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// authEvent is an auth audit row. Empty strings stand for NULL columns.
+type authEvent struct {
+	event     string
+	username  string
+	ip        string
+	actorId   string
+	userId    string
+	createdAt time.Time
+}
+
+// recordAuth appends an auth event, tied to the user holding username at the
+// time, if there is one. The caller holds mu.
+func (s *Store) recordAuth(event string, username string, ip string, actorId string) {
+	userId := ""
+	if stored := s.byUsername(username); stored != nil && username != "" {
+		userId = stored.user.ID
+	}
+
+	s.authAudit = append(s.authAudit, &authEvent{
+		event:     event,
+		username:  username,
+		ip:        ip,
+		actorId:   actorId,
+		userId:    userId,
+		createdAt: now(),
+	})
+}
+
+func (s *Store) InsertAuthAudit(ctx context.Context, event string, username string, ip string, actorId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.recordAuth(event, username, ip, actorId)
+
+	return nil
+}
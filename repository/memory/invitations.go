@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+type invitation struct {
+	role      model.Role
+	orgId     string
+	orgRole   model.OrgRole
+	createdBy string
+	createdAt time.Time
+	expiresAt time.Time
+	// usedBy is empty once the user who used it is gone, usedAt stays set
+	usedBy string
+	usedAt *time.Time
+}
+
+// valid reports whether an invitation, which may be nil, can still be used.
+func (i *invitation) valid() bool {
+	return i != nil && i.usedAt == nil && i.expiresAt.After(time.Now())
+}
+
+func (s *Store) CreateInvitation(ctx context.Context, codeHash string, role model.Role, orgId string, orgRole model.OrgRole, createdBy string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.invitations[codeHash] = &invitation{
+		role:      role,
+		orgId:     orgId,
+		orgRole:   orgRole,
+		createdBy: createdBy,
+		createdAt: now(),
+		expiresAt: expiresAt,
+	}
+
+	return nil
+}
+
+func (s *Store) InvitationValid(ctx context.Context, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.invitations[codeHash].valid(), nil
+}
@@ -0,0 +1,180 @@
+package memory
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+)
+
+// loginFailure counts the failed logins of an account from an address or of
+// an address, and the attempts in flight, under the same thresholds and
+// delays as the Postgres repository.
+type loginFailure struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+	pending     int
+	lastAttempt time.Time
+}
+
+func accountKey(username string) string {
+	return "user:" + strings.ToLower(username)
+}
+
+func pairKey(username string, ip string) string {
+	return "ip:" + ip + "|" + accountKey(username)
+}
+
+type loginKey struct {
+	key       string
+	threshold int
+	event     string
+}
+
+func loginKeys(username string, ip string) []loginKey {
+	keys := []loginKey{{pairKey(username, ip), db.AccountLockThreshold, db.AuditAccountLocked}}
+	if ip != "" {
+		keys = append(keys, loginKey{"ip:" + ip, db.IpLockThreshold, db.AuditIpLocked})
+	}
+	return keys
+}
+
+func (s *Store) ReserveLoginAttempt(ctx context.Context, username string, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := loginKeys(username, ip)
+
+	// Every key is checked before any is reserved, like the Postgres
+	// transaction that rolls back
+	for _, key := range keys {
+		failure, ok := s.loginFailures[key.key]
+		if !ok {
+			continue
+		}
+
+		if failure.lockedUntil.After(now) {
+			return &db.LoginThrottledError{RetryAfter: failure.lockedUntil.Sub(now), Locked: true}
+		}
+
+		if wait := db.AttemptWait(now, failure.failures, failure.lastFailure, failure.pending, failure.lastAttempt); wait > 0 {
+			return &db.LoginThrottledError{RetryAfter: wait}
+		}
+	}
+
+	for _, key := range keys {
+		failure, ok := s.loginFailures[key.key]
+		if !ok {
+			failure = &loginFailure{lastFailure: now}
+			s.loginFailures[key.key] = failure
+		}
+
+		if now.Sub(failure.lastAttempt) > db.AttemptTimeout {
+			failure.pending = 0
+		}
+		failure.pending++
+		failure.lastAttempt = now
+	}
+
+	return nil
+}
+
+func (s *Store) RecordLoginFailure(ctx context.Context, username string, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for key, failure := range s.loginFailures {
+		if now.Sub(failure.lastFailure) > db.FailureWindow && now.Sub(failure.lastAttempt) > db.AttemptTimeout && !failure.lockedUntil.After(now) {
+			delete(s.loginFailures, key)
+		}
+	}
+
+	for _, key := range loginKeys(username, ip) {
+		s.recordFailure(key, username, ip)
+	}
+
+	return nil
+}
+
+// recordFailure counts a failure against key, starting over once the last
+// one is outside the window, ends its attempt and locks the key at
+// threshold. The caller holds mu.
+func (s *Store) recordFailure(key loginKey, username string, ip string) {
+	now := time.Now()
+
+	failure, ok := s.loginFailures[key.key]
+	if !ok {
+		failure = &loginFailure{}
+		s.loginFailures[key.key] = failure
+	}
+
+	if failure.lastFailure.Before(now.Add(-db.FailureWindow)) {
+		failure.failures = 1
+	} else {
+		failure.failures++
+	}
+	failure.lastFailure = now
+	if failure.pending > 0 {
+		failure.pending--
+	}
+
+	if failure.failures < key.threshold {
+		return
+	}
+
+	failure.lockedUntil = now.Add(db.LockoutDuration)
+	if failure.failures == key.threshold {
+		s.recordAuth(key.event, username, ip, "")
+	}
+}
+
+func (s *Store) RecordLoginSuccess(ctx context.Context, username string, ip string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.loginFailures, pairKey(username, ip))
+	if failure, ok := s.loginFailures["ip:"+ip]; ok && ip != "" && failure.pending > 0 {
+		failure.pending--
+	}
+
+	if stored := s.byUsername(username); stored != nil {
+		stored.user.LastLoginAt = stringPointer(now().Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+func (s *Store) ClearLoginFailures(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clearLoginFailures(username)
+
+	return nil
+}
+
+// clearLoginFailures deletes the failures of an account from every address.
+// The caller holds mu.
+func (s *Store) clearLoginFailures(username string) {
+	suffix := "|" + accountKey(username)
+	for key := range s.loginFailures {
+		if i := strings.Index(key, "|"); i >= 0 && key[i:] == suffix {
+			delete(s.loginFailures, key)
+		}
+	}
+}
+
+func (s *Store) UnlockAccount(ctx context.Context, username string, actorId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.clearLoginFailures(username)
+	s.recordAuth(db.AuditAccountUnlocked, username, "", actorId)
+
+	return nil
+}
@@ -0,0 +1,96 @@
+// Package memory keeps everything the resolvers store in memory, for tests
+// and local development without Postgres. It behaves like the Postgres
+// repository, which the conformance suite in package repotest checks, except
+// that nothing outlives the process.
+package memory
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/repository"
+)
+
+// Store implements every repository of package repository. The zero value is
+// not usable, create one with New, which starts with the default organization
+// the migrations create.
+type Store struct {
+	mu sync.Mutex
+
+	// players by organization and id, including the ones in the trash
+	players map[string]map[string]*storedPlayer
+	// audit holds every player change, oldest first
+	audit     []*auditEntry
+	nextAudit int64
+
+	users       map[string]*storedUser
+	invitations map[string]*invitation
+	resets      map[string]*passwordReset
+
+	// authAudit holds every auth event, oldest first
+	authAudit     []*authEvent
+	loginFailures map[string]*loginFailure
+	resetRequests map[string]*resetRequests
+	challenges    map[string]*loginChallenge
+
+	organizations map[string]*organization
+
+	requireTwoFactor bool
+	registrationMode model.RegistrationMode
+}
+
+var (
+	_ repository.PlayerRepository       = (*Store)(nil)
+	_ repository.UserRepository         = (*Store)(nil)
+	_ repository.AuthRepository         = (*Store)(nil)
+	_ repository.OrganizationRepository = (*Store)(nil)
+	_ repository.SettingsRepository     = (*Store)(nil)
+)
+
+func New() *Store {
+	return &Store{
+		players:       map[string]map[string]*storedPlayer{},
+		nextAudit:     1,
+		users:         map[string]*storedUser{},
+		invitations:   map[string]*invitation{},
+		resets:        map[string]*passwordReset{},
+		loginFailures: map[string]*loginFailure{},
+		resetRequests: map[string]*resetRequests{},
+		challenges:    map[string]*loginChallenge{},
+		organizations: map[string]*organization{
+			db.DefaultOrganizationId: {id: db.DefaultOrganizationId, name: "Default", members: map[string]*membership{}},
+		},
+	}
+}
+
+// newId returns a random UUID, like the ones Postgres generates.
+func newId() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+// optionalString returns nil for an empty string, like a NULL column.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
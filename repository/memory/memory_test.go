@@ -0,0 +1,36 @@
+package memory_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/repository/memory"
+	"github.com/mattmazer1/graphql-api/repository/repotest"
+)
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	repotest.Run(t, func(t *testing.T) repotest.Setup {
+		store := memory.New()
+
+		creator, err := store.CreateUser(ctx, model.InputUser{Username: "repotest", Password: "correct horse"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		org, err := store.CreateOrganization(ctx, "repotest_a", creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := store.CreateOrganization(ctx, "repotest_b", creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repotest.Setup{
+			Players: store, Users: store, Auth: store, Orgs: store, Settings: store,
+			OrgID: org.ID, OtherOrgID: other.ID,
+		}
+	})
+}
@@ -0,0 +1,192 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+type organization struct {
+	id      string
+	name    string
+	members map[string]*membership
+}
+
+type membership struct {
+	role     model.OrgRole
+	joinedAt time.Time
+}
+
+func (s *Store) CreateOrganization(ctx context.Context, name string, creatorId string) (*model.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, org := range s.organizations {
+		if org.name == name {
+			return nil, apperr.Errorf(apperr.Conflict, "organization %s already exists", name)
+		}
+	}
+
+	id := newId()
+	s.organizations[id] = &organization{
+		id:      id,
+		name:    name,
+		members: map[string]*membership{creatorId: {role: model.OrgRoleAdmin, joinedAt: now()}},
+	}
+
+	role := model.OrgRoleAdmin
+	return &model.Organization{ID: id, Name: name, Role: &role}, nil
+}
+
+func (s *Store) OrganizationExists(ctx context.Context, orgId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.organizations[orgId]
+	return ok, nil
+}
+
+func (s *Store) GetMembership(ctx context.Context, userId string, orgId string) (model.OrgRole, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if org, ok := s.organizations[orgId]; ok {
+		if member, ok := org.members[userId]; ok {
+			return member.role, nil
+		}
+	}
+
+	return "", nil
+}
+
+func (s *Store) GetDefaultMembership(ctx context.Context, userId string) (string, model.OrgRole, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var first *organization
+	var firstMember *membership
+	for _, org := range s.organizations {
+		member, ok := org.members[userId]
+		if !ok {
+			continue
+		}
+		if first == nil || member.joinedAt.Before(firstMember.joinedAt) ||
+			(member.joinedAt.Equal(firstMember.joinedAt) && org.id < first.id) {
+			first, firstMember = org, member
+		}
+	}
+
+	if first == nil {
+		return "", "", nil
+	}
+
+	return first.id, firstMember.role, nil
+}
+
+func (s *Store) CanEditAnyOrganization(ctx context.Context, userId string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, org := range s.organizations {
+		if member, ok := org.members[userId]; ok && (member.role == model.OrgRoleEditor || member.role == model.OrgRoleAdmin) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// organizationsOf returns the organizations a user belongs to, or all of them,
+// ordered by name. The caller holds mu.
+func (s *Store) organizationsOf(userId string, all bool) []*model.Organization {
+	organizations := []*model.Organization{}
+	for _, org := range s.organizations {
+		member, ok := org.members[userId]
+		if !ok && !all {
+			continue
+		}
+
+		organization := &model.Organization{ID: org.id, Name: org.name}
+		if ok {
+			role := member.role
+			organization.Role = &role
+		}
+		organizations = append(organizations, organization)
+	}
+	sort.Slice(organizations, func(i, j int) bool { return organizations[i].Name < organizations[j].Name })
+
+	return organizations
+}
+
+func (s *Store) GetOrganizations(ctx context.Context, userId string, all bool) ([]*model.Organization, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.organizationsOf(userId, all), nil
+}
+
+func (s *Store) GetOrganizationMembers(ctx context.Context, orgId string) ([]*model.OrganizationMember, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	members := []*model.OrganizationMember{}
+	org, ok := s.organizations[orgId]
+	if !ok {
+		return members, nil
+	}
+
+	for userId, member := range org.members {
+		stored, ok := s.users[userId]
+		if !ok {
+			continue
+		}
+		members = append(members, &model.OrganizationMember{UserID: userId, Username: stored.user.Username, Role: member.role})
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Username < members[j].Username })
+
+	return members, nil
+}
+
+func (s *Store) SetOrganizationMember(ctx context.Context, orgId string, userId string, role model.OrgRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, ok := s.organizations[orgId]
+	if !ok {
+		return fmt.Errorf("could not set organization member: no organization %s", orgId)
+	}
+
+	member, ok := org.members[userId]
+	if !ok {
+		org.members[userId] = &membership{role: role, joinedAt: now()}
+		return nil
+	}
+
+	if member.role != role {
+		member.role = role
+		s.endSessions(userId)
+	}
+
+	return nil
+}
+
+func (s *Store) RemoveOrganizationMember(ctx context.Context, orgId string, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	org, ok := s.organizations[orgId]
+	if !ok {
+		return nil
+	}
+
+	if _, ok := org.members[userId]; ok {
+		delete(org.members, userId)
+		s.endSessions(userId)
+	}
+
+	return nil
+}
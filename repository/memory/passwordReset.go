@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+)
+
+type passwordReset struct {
+	userId    string
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+// valid reports whether a reset, which may be nil, can still be used.
+func (r *passwordReset) valid() bool {
+	return r != nil && r.usedAt == nil && r.expiresAt.After(time.Now())
+}
+
+func (s *Store) CreatePasswordReset(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, reset := range s.resets {
+		if reset.userId == userId {
+			delete(s.resets, hash)
+		}
+	}
+	s.resets[tokenHash] = &passwordReset{userId: userId, expiresAt: expiresAt}
+
+	return nil
+}
+
+func (s *Store) PasswordResetValid(ctx context.Context, tokenHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.resets[tokenHash].valid(), nil
+}
+
+func (s *Store) ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (string, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	reset := s.resets[tokenHash]
+	if !reset.valid() {
+		return "", "", nil
+	}
+	stored, ok := s.users[reset.userId]
+	if !ok {
+		return "", "", nil
+	}
+
+	usedAt := now()
+	reset.usedAt = &usedAt
+	stored.password = hashedPassword
+	stored.tokensValidAfter = now()
+
+	return stored.user.ID, stored.user.Username, nil
+}
+
+// resetRequests counts the reset requests of an address or an account in
+// the window that started at windowStart.
+type resetRequests struct {
+	requests    int
+	windowStart time.Time
+}
+
+func (s *Store) ReservePasswordReset(ctx context.Context, username string, ip string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, counted := range s.resetRequests {
+		if now.Sub(counted.windowStart) > db.ResetWindow {
+			delete(s.resetRequests, key)
+		}
+	}
+
+	if ip != "" {
+		counted := s.countResetRequest("ip:"+ip, now)
+		if counted.requests > db.ResetIpLimit {
+			return false, &db.ResetThrottledError{RetryAfter: counted.windowStart.Add(db.ResetWindow).Sub(now)}
+		}
+	}
+
+	return s.countResetRequest(accountKey(username), now).requests <= db.ResetAccountLimit, nil
+}
+
+// countResetRequest counts a request against key. The caller holds mu.
+func (s *Store) countResetRequest(key string, now time.Time) *resetRequests {
+	counted, ok := s.resetRequests[key]
+	if !ok {
+		counted = &resetRequests{windowStart: now}
+		s.resetRequests[key] = counted
+	}
+	counted.requests++
+	return counted
+}
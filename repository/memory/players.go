@@ -0,0 +1,830 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+type statsKey struct {
+	season     string
+	seasonType model.SeasonType
+}
+
+// storedPlayer is a player with its stored stat lines. The player is
+// replaced, never changed in place, so copies handed out stay as they were.
+type storedPlayer struct {
+	player    *model.Player
+	deletedAt time.Time
+	stats     map[statsKey]*model.Stats
+}
+
+type auditEntry struct {
+	id    int64
+	entry model.PlayerAuditEntry
+}
+
+// seasonTypeOrder is the order of the lines of one season
+var seasonTypeOrder = []model.SeasonType{
+	model.SeasonTypePreseason,
+	model.SeasonTypeRegular,
+	model.SeasonTypePlayIn,
+	model.SeasonTypePlayoffs,
+}
+
+// orgPlayers returns the players of an organization. The caller holds mu.
+func (s *Store) orgPlayers(orgId string) (map[string]*storedPlayer, error) {
+	if orgId == "" {
+		return nil, fmt.Errorf("no organization")
+	}
+
+	players, ok := s.players[orgId]
+	if !ok {
+		players = map[string]*storedPlayer{}
+		s.players[orgId] = players
+	}
+
+	return players, nil
+}
+
+func seasonType(t model.SeasonType) model.SeasonType {
+	if t == "" {
+		return model.SeasonTypeRegular
+	}
+	return t
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
+
+func copyInt(n *int) *int {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	return &c
+}
+
+func copyStats(stats *model.Stats) *model.Stats {
+	c := *stats
+	c.GamesPlayed = copyInt(stats.GamesPlayed)
+	return &c
+}
+
+func copyPlayer(player *model.Player) *model.Player {
+	c := *player
+	profile := *player.Profile
+	c.Profile = &profile
+	c.Stats = copyStats(player.Stats)
+	c.EligiblePositions = append([]model.LineupPosition{}, player.EligiblePositions...)
+	return &c
+}
+
+// newPlayer builds the player stored for an input that passed validation.
+func newPlayer(id string, input model.InputPlayer, version int) *model.Player {
+	pos, eligible := validate.Positions(input)
+
+	profile := input.Profile
+	if profile == nil {
+		profile = &model.InputProfile{}
+	}
+	var handedness *model.Handedness
+	if profile.Handedness != nil {
+		h := *profile.Handedness
+		handedness = &h
+	}
+	var primary *model.LineupPosition
+	if input.PrimaryPosition != nil {
+		p := *input.PrimaryPosition
+		primary = &p
+	}
+
+	player := &model.Player{
+		ID:                id,
+		Pos:               pos,
+		PrimaryPosition:   primary,
+		EligiblePositions: eligible,
+		Name:              input.Name,
+		Profile: &model.Profile{
+			BirthDate:    copyString(profile.BirthDate),
+			Height:       copyInt(profile.Height),
+			Weight:       copyInt(profile.Weight),
+			College:      copyString(profile.College),
+			Country:      copyString(profile.Country),
+			DraftYear:    copyInt(profile.DraftYear),
+			DraftRound:   copyInt(profile.DraftRound),
+			DraftPick:    copyInt(profile.DraftPick),
+			DebutSeason:  copyString(profile.DebutSeason),
+			JerseyNumber: copyInt(profile.JerseyNumber),
+			Handedness:   handedness,
+		},
+		Stats: &model.Stats{
+			Season:      input.Stats.Season,
+			SeasonType:  seasonType(input.Stats.SeasonType),
+			Points:      input.Stats.Points,
+			ThreePt:     input.Stats.ThreePt,
+			Rebounds:    input.Stats.Rebounds,
+			Assists:     input.Stats.Assists,
+			Steals:      input.Stats.Steals,
+			Blocks:      input.Stats.Blocks,
+			TurnOvers:   input.Stats.TurnOvers,
+			Mp:          input.Stats.Mp,
+			GamesPlayed: copyInt(input.Stats.GamesPlayed),
+		},
+		Version: version,
+	}
+	if input.Age != nil {
+		player.Age = *input.Age
+	}
+	if input.Experience != nil {
+		player.Experience = *input.Experience
+	}
+
+	return player
+}
+
+// live returns the player with a name that is not in the trash, or nil.
+func live(players map[string]*storedPlayer, name string) *storedPlayer {
+	for _, stored := range players {
+		if stored.player.DeletedAt == nil && stored.player.Name == name {
+			return stored
+		}
+	}
+	return nil
+}
+
+func lock(players map[string]*storedPlayer, name string, expectedVersion int) (*storedPlayer, error) {
+	stored := live(players, name)
+	if stored == nil {
+		return nil, db.ErrPlayerNotFound
+	}
+	if stored.player.Version != expectedVersion {
+		return nil, &db.VersionConflictError{Current: copyPlayer(stored.player)}
+	}
+
+	return stored, nil
+}
+
+// The write functions make one change to a player of an organization and
+// audit it. They check everything before they change anything, so a failed
+// change leaves the store as it was. The caller holds mu.
+
+func (s *Store) create(orgId string, players map[string]*storedPlayer, actorId string, input model.InputPlayer) (*model.Player, error) {
+	if err := validate.Player(input); err != nil {
+		return nil, err
+	}
+	if live(players, input.Name) != nil {
+		return nil, db.ErrPlayerNameTaken
+	}
+
+	player := newPlayer(newId(), input, 1)
+	stored := &storedPlayer{player: player, stats: map[statsKey]*model.Stats{}}
+	stored.stats[statsKey{player.Stats.Season, player.Stats.SeasonType}] = copyStats(player.Stats)
+	players[player.ID] = stored
+
+	s.record(orgId, actorId, model.AuditActionCreate, nil, player)
+	return copyPlayer(player), nil
+}
+
+func (s *Store) update(orgId string, stored *storedPlayer, actorId string, update model.InputUpdatePlayer) (*model.Player, error) {
+	before := stored.player
+
+	// An update that moves to another season or season type starts from the
+	// stored line for it
+	base := before
+	if update.Stats != nil {
+		key := statsKey{before.Stats.Season, before.Stats.SeasonType}
+		if update.Stats.Season != nil {
+			key.season = *update.Stats.Season
+		}
+		if update.Stats.SeasonType != nil {
+			key.seasonType = seasonType(*update.Stats.SeasonType)
+		}
+		if line, ok := stored.stats[key]; ok {
+			base = copyPlayer(before)
+			base.Stats = copyStats(line)
+		}
+	}
+
+	merged := validate.MergeUpdate(base, update)
+	if err := validate.Player(merged); err != nil {
+		return nil, err
+	}
+
+	player := newPlayer(before.ID, merged, before.Version+1)
+	stored.player = player
+	stored.stats[statsKey{player.Stats.Season, player.Stats.SeasonType}] = copyStats(player.Stats)
+
+	s.record(orgId, actorId, model.AuditActionUpdate, before, player)
+	return copyPlayer(player), nil
+}
+
+func (s *Store) delete(orgId string, stored *storedPlayer, actorId string) *model.Player {
+	before := stored.player
+
+	stored.deletedAt = now()
+	player := copyPlayer(before)
+	player.Version++
+	player.DeletedAt = stringPointer(stored.deletedAt.Format(time.RFC3339))
+	stored.player = player
+
+	s.record(orgId, actorId, model.AuditActionDelete, before, nil)
+	return copyPlayer(player)
+}
+
+// record audits a change to a player. Updates that did not change anything
+// are not recorded.
+func (s *Store) record(orgId string, actorId string, action model.AuditAction, before *model.Player, after *model.Player) {
+	changes := db.DiffPlayers(before, after)
+	if len(changes) == 0 {
+		return
+	}
+
+	player := after
+	if player == nil {
+		player = before
+	}
+
+	entry := &auditEntry{
+		id: s.nextAudit,
+		entry: model.PlayerAuditEntry{
+			ID:             fmt.Sprint(s.nextAudit),
+			PlayerID:       player.ID,
+			PlayerName:     player.Name,
+			OrganizationID: orgId,
+			Action:         action,
+			Changes:        changes,
+			CreatedAt:      now().Format(time.RFC3339),
+		},
+	}
+	if actorId != "" {
+		entry.entry.ActorID = stringPointer(actorId)
+	}
+
+	s.audit = append(s.audit, entry)
+	s.nextAudit++
+}
+
+func matches(player *model.Player, filter *model.PlayerFilter) bool {
+	if player.DeletedAt != nil {
+		return false
+	}
+	if filter == nil {
+		return true
+	}
+
+	if filter.Name != nil && !strings.Contains(strings.ToLower(player.Name), strings.ToLower(*filter.Name)) {
+		return false
+	}
+	if filter.Pos != nil {
+		eligible := false
+		for _, position := range player.EligiblePositions {
+			if position.Group() == *filter.Pos {
+				eligible = true
+			}
+		}
+		if !eligible {
+			return false
+		}
+	}
+	if filter.Position != nil {
+		eligible := false
+		for _, position := range player.EligiblePositions {
+			if position == *filter.Position {
+				eligible = true
+			}
+		}
+		if !eligible {
+			return false
+		}
+	}
+	if filter.Season != nil && player.Stats.Season != *filter.Season {
+		return false
+	}
+	if filter.SeasonType != nil && player.Stats.SeasonType != *filter.SeasonType {
+		return false
+	}
+
+	return true
+}
+
+// filtered returns copies of the players matching filter, ordered by name.
+func (s *Store) filtered(orgId string, filter *model.PlayerFilter) ([]*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []*model.Player{}
+	for _, stored := range players {
+		if matches(stored.player, filter) {
+			matching = append(matching, copyPlayer(stored.player))
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Name < matching[j].Name })
+
+	return matching, nil
+}
+
+func (s *Store) GetPlayer(ctx context.Context, orgId string, name string) (*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := live(players, name)
+	if stored == nil {
+		return nil, db.ErrPlayerNotFound
+	}
+
+	return copyPlayer(stored.player), nil
+}
+
+func (s *Store) GetPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, first int, after string) ([]*model.Player, bool, error) {
+	matching, err := s.filtered(orgId, filter)
+	if err != nil {
+		return nil, false, err
+	}
+
+	page := []*model.Player{}
+	for _, player := range matching {
+		if player.Name > after {
+			page = append(page, player)
+		}
+	}
+
+	if len(page) > first {
+		return page[:first], true, nil
+	}
+
+	return page, false, nil
+}
+
+func (s *Store) StreamPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, fn func(*model.Player) error) error {
+	matching, err := s.filtered(orgId, filter)
+	if err != nil {
+		return err
+	}
+
+	for _, player := range matching {
+		if err := fn(player); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) CreatePlayer(ctx context.Context, orgId string, actorId string, player model.InputPlayer) (*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.create(orgId, players, actorId, player)
+}
+
+func (s *Store) UpdatePlayer(ctx context.Context, orgId string, actorId string, player model.InputUpdatePlayer, expectedVersion int) (*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	name := ""
+	if player.Name != nil {
+		name = *player.Name
+	}
+
+	stored, err := lock(players, name, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.update(orgId, stored, actorId, player)
+}
+
+func (s *Store) DeletePlayer(ctx context.Context, orgId string, actorId string, name string, expectedVersion int) (*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := lock(players, name, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.delete(orgId, stored, actorId), nil
+}
+
+// runBulk applies fn to every item of a batch. A failed item changes nothing
+// and the rest of the batch still runs. An atomic batch with a failed item is
+// undone as a whole. It reports whether the batch was kept.
+func (s *Store) runBulk(orgId string, atomic bool, n int, fn func(players map[string]*storedPlayer, i int) (*model.Player, error)) ([]repository.BulkResult, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The stored players are replaced rather than changed, so a shallow copy
+	// of each with its own stat lines is enough to undo the batch
+	snapshot := make(map[string]*storedPlayer, len(players))
+	for id, stored := range players {
+		c := *stored
+		c.stats = make(map[statsKey]*model.Stats, len(stored.stats))
+		for key, line := range stored.stats {
+			c.stats[key] = line
+		}
+		snapshot[id] = &c
+	}
+	audited := len(s.audit)
+
+	results := make([]repository.BulkResult, n)
+	failed := false
+
+	for i := 0; i < n; i++ {
+		player, err := fn(players, i)
+		results[i] = repository.BulkResult{Player: player, Err: err}
+		if err != nil {
+			failed = true
+		}
+	}
+
+	if atomic && failed {
+		s.players[orgId] = snapshot
+		s.audit = s.audit[:audited]
+		return results, false, nil
+	}
+
+	return results, true, nil
+}
+
+func (s *Store) CreatePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]repository.BulkResult, bool, error) {
+	return s.runBulk(orgId, atomic, len(players), func(stored map[string]*storedPlayer, i int) (*model.Player, error) {
+		return s.create(orgId, stored, actorId, *players[i])
+	})
+}
+
+func (s *Store) UpsertPlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]repository.BulkResult, bool, error) {
+	return s.runBulk(orgId, atomic, len(players), func(stored map[string]*storedPlayer, i int) (*model.Player, error) {
+		player := players[i]
+
+		before := live(stored, player.Name)
+		if before == nil {
+			return s.create(orgId, stored, actorId, *player)
+		}
+
+		return s.update(orgId, before, actorId, validate.Overwrite(*player))
+	})
+}
+
+func (s *Store) DeletePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputDeletePlayer, atomic bool) ([]repository.BulkResult, bool, error) {
+	return s.runBulk(orgId, atomic, len(players), func(stored map[string]*storedPlayer, i int) (*model.Player, error) {
+		before, err := lock(stored, players[i].Name, players[i].ExpectedVersion)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.delete(orgId, before, actorId), nil
+	})
+}
+
+func (s *Store) GetDeletedPlayers(ctx context.Context, orgId string) ([]*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	deleted := []*storedPlayer{}
+	for _, stored := range players {
+		if stored.player.DeletedAt != nil {
+			deleted = append(deleted, stored)
+		}
+	}
+	sort.SliceStable(deleted, func(i, j int) bool { return deleted[i].deletedAt.After(deleted[j].deletedAt) })
+
+	result := make([]*model.Player, len(deleted))
+	for i, stored := range deleted {
+		result[i] = copyPlayer(stored.player)
+	}
+
+	return result, nil
+}
+
+func (s *Store) RestorePlayer(ctx context.Context, orgId string, actorId string, id string) (*model.Player, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, ok := players[id]
+	if !ok || stored.player.DeletedAt == nil {
+		return nil, db.ErrPlayerNotFound
+	}
+	if live(players, stored.player.Name) != nil {
+		return nil, db.ErrPlayerNameTaken
+	}
+
+	player := copyPlayer(stored.player)
+	player.Version++
+	player.DeletedAt = nil
+	stored.player = player
+	stored.deletedAt = time.Time{}
+
+	s.record(orgId, actorId, model.AuditActionRestore, nil, player)
+	return copyPlayer(player), nil
+}
+
+// lines returns copies of the stored lines of a player, newest season first
+// and in the order of the season within one.
+func (s *Store) lines(orgId string, playerId string) ([]*model.Stats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	players, err := s.orgPlayers(orgId)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []*model.Stats{}
+	if stored, ok := players[playerId]; ok {
+		for _, line := range stored.stats {
+			lines = append(lines, copyStats(line))
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i].Season != lines[j].Season {
+			return lines[i].Season > lines[j].Season
+		}
+		return seasonTypeIndex(lines[i].SeasonType) < seasonTypeIndex(lines[j].SeasonType)
+	})
+
+	return lines, nil
+}
+
+func seasonTypeIndex(t model.SeasonType) int {
+	for i, other := range seasonTypeOrder {
+		if other == t {
+			return i
+		}
+	}
+	return len(seasonTypeOrder)
+}
+
+func (s *Store) GetPlayerStats(ctx context.Context, orgId string, playerId string, season *string, seasonType *model.SeasonType) ([]*model.Stats, error) {
+	lines, err := s.lines(orgId, playerId)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := []*model.Stats{}
+	for _, line := range lines {
+		if (season == nil || line.Season == *season) && (seasonType == nil || line.SeasonType == *seasonType) {
+			matching = append(matching, line)
+		}
+	}
+
+	return matching, nil
+}
+
+// careerStats are the stats a career high is looked for in, named like the
+// fields of Stats
+var careerStats = []struct {
+	name  string
+	value func(*model.Stats) float64
+}{
+	{"points", func(s *model.Stats) float64 { return s.Points }},
+	{"threePt", func(s *model.Stats) float64 { return s.ThreePt }},
+	{"rebounds", func(s *model.Stats) float64 { return s.Rebounds }},
+	{"assists", func(s *model.Stats) float64 { return s.Assists }},
+	{"steals", func(s *model.Stats) float64 { return s.Steals }},
+	{"blocks", func(s *model.Stats) float64 { return s.Blocks }},
+	{"turnOvers", func(s *model.Stats) float64 { return s.TurnOvers }},
+	{"mp", func(s *model.Stats) float64 { return s.Mp }},
+}
+
+// statHighs returns the best value of every stat in lines, ties going to the
+// line better says goes first.
+func statHighs(lines []*model.Stats, better func(a *model.Stats, b *model.Stats) bool) []*model.StatHigh {
+	highs := []*model.StatHigh{}
+	if len(lines) == 0 {
+		return highs
+	}
+
+	for _, stat := range careerStats {
+		best := lines[0]
+		for _, line := range lines[1:] {
+			value, bestValue := stat.value(line), stat.value(best)
+			if value > bestValue || (value == bestValue && better(line, best)) {
+				best = line
+			}
+		}
+		highs = append(highs, &model.StatHigh{
+			Stat:       stat.name,
+			Value:      stat.value(best),
+			Season:     best.Season,
+			SeasonType: best.SeasonType,
+		})
+	}
+
+	return highs
+}
+
+func (s *Store) GetPlayerCareer(ctx context.Context, orgId string, player *model.Player, seasonType model.SeasonType) (*model.Career, error) {
+	lines, err := s.lines(orgId, player.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	career := &model.Career{
+		SeasonType: seasonType,
+		Totals:     &model.StatLine{},
+		Averages:   &model.StatLine{},
+	}
+	// The stats are per game averages, so totals take every line's games
+	// played and are only known when every line has them
+	totals := career.Totals
+	gamesPlayed := 0
+	complete := true
+	minutes := 0.0
+	weighted := &model.StatLine{}
+
+	ofType := []*model.Stats{}
+	for _, line := range lines {
+		if line.SeasonType != seasonType {
+			continue
+		}
+		ofType = append(ofType, line)
+
+		if line.GamesPlayed == nil {
+			complete = false
+		} else {
+			games := float64(*line.GamesPlayed)
+			gamesPlayed += *line.GamesPlayed
+			totals.Points += line.Points * games
+			totals.ThreePt += line.ThreePt * games
+			totals.Rebounds += line.Rebounds * games
+			totals.Assists += line.Assists * games
+			totals.Steals += line.Steals * games
+			totals.Blocks += line.Blocks * games
+			totals.TurnOvers += line.TurnOvers * games
+			totals.Mp += line.Mp * games
+		}
+
+		minutes += line.Mp
+		weighted.Points += line.Points * line.Mp
+		weighted.ThreePt += line.ThreePt * line.Mp
+		weighted.Rebounds += line.Rebounds * line.Mp
+		weighted.Assists += line.Assists * line.Mp
+		weighted.Steals += line.Steals * line.Mp
+		weighted.Blocks += line.Blocks * line.Mp
+		weighted.TurnOvers += line.TurnOvers * line.Mp
+	}
+
+	if complete {
+		career.GamesPlayed = &gamesPlayed
+	} else {
+		career.Totals = nil
+	}
+
+	career.Seasons = len(ofType)
+	if len(ofType) == 0 {
+		career.CareerHighs = []*model.StatHigh{}
+	} else {
+		// Lines are newest first
+		career.FirstSeason = stringPointer(ofType[len(ofType)-1].Season)
+		career.LastSeason = stringPointer(ofType[0].Season)
+		career.Averages.Mp = minutes / float64(len(ofType))
+
+		// Averages are weighted by minutes, a line without minutes does not
+		// count towards them
+		if minutes != 0 {
+			career.Averages.Points = weighted.Points / minutes
+			career.Averages.ThreePt = weighted.ThreePt / minutes
+			career.Averages.Rebounds = weighted.Rebounds / minutes
+			career.Averages.Assists = weighted.Assists / minutes
+			career.Averages.Steals = weighted.Steals / minutes
+			career.Averages.Blocks = weighted.Blocks / minutes
+			career.Averages.TurnOvers = weighted.TurnOvers / minutes
+		}
+
+		// Ties go to the latest season
+		career.CareerHighs = statHighs(ofType, func(a *model.Stats, b *model.Stats) bool {
+			return a.Season > b.Season
+		})
+	}
+
+	// Season highs are over every season type of the latest season
+	latest := []*model.Stats{}
+	for _, line := range lines {
+		if line.Season == lines[0].Season {
+			latest = append(latest, line)
+		}
+	}
+	career.SeasonHighs = statHighs(latest, func(a *model.Stats, b *model.Stats) bool {
+		return a.SeasonType < b.SeasonType
+	})
+
+	return career, nil
+}
+
+// entries returns copies of the audit entries keep accepts, newest first,
+// up to first of them before the entry with id before, and whether there are
+// more. The caller holds mu.
+func (s *Store) entries(first int, before int64, keep func(*auditEntry) bool) ([]*model.PlayerAuditEntry, bool) {
+	entries := []*model.PlayerAuditEntry{}
+
+	for i := len(s.audit) - 1; i >= 0; i-- {
+		entry := s.audit[i]
+		if (before != 0 && entry.id >= before) || !keep(entry) {
+			continue
+		}
+		if len(entries) == first {
+			return entries, true
+		}
+
+		c := entry.entry
+		c.Changes = make([]*model.FieldChange, len(entry.entry.Changes))
+		for j, change := range entry.entry.Changes {
+			changeCopy := *change
+			c.Changes[j] = &changeCopy
+		}
+
+		// Users that no longer exist show up under the same pseudonym as in
+		// the auth audit log
+		if c.ActorID != nil {
+			username := "erased-" + *c.ActorID
+			if user, ok := s.users[*c.ActorID]; ok {
+				username = user.user.Username
+			}
+			c.ActorUsername = &username
+		}
+
+		entries = append(entries, &c)
+	}
+
+	return entries, false
+}
+
+func (s *Store) GetPlayerHistory(ctx context.Context, orgId string, playerId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.orgPlayers(orgId); err != nil {
+		return nil, false, err
+	}
+
+	entries, hasNext := s.entries(first, before, func(entry *auditEntry) bool {
+		return entry.entry.OrganizationID == orgId && entry.entry.PlayerID == playerId
+	})
+
+	return entries, hasNext, nil
+}
+
+func (s *Store) GetAuditLog(ctx context.Context, orgId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, hasNext := s.entries(first, before, func(entry *auditEntry) bool {
+		return orgId == "" || entry.entry.OrganizationID == orgId
+	})
+
+	return entries, hasNext, nil
+}
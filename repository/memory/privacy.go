@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"sort"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+func (s *Store) ExportUserData(ctx context.Context, userId string) (*model.UserExport, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return nil, nil
+	}
+
+	export := &model.UserExport{
+		ExportedAt:    now(),
+		Account:       copyUser(&stored.user),
+		Organizations: s.organizationsOf(userId, false),
+	}
+
+	if !stored.tokensValidAfter.IsZero() {
+		validAfter := stored.tokensValidAfter
+		export.Sessions.TokensValidAfter = &validAfter
+	}
+
+	export.Sessions.LoginChallenges = []model.ExportTimestamp{}
+	for _, challenge := range s.challenges {
+		if challenge.userId == userId {
+			export.Sessions.LoginChallenges = append(export.Sessions.LoginChallenges, model.ExportTimestamp{ExpiresAt: challenge.expiresAt})
+		}
+	}
+	sortTimestamps(export.Sessions.LoginChallenges)
+
+	export.Sessions.PasswordResets = []model.ExportTimestamp{}
+	for _, reset := range s.resets {
+		if reset.userId == userId {
+			export.Sessions.PasswordResets = append(export.Sessions.PasswordResets, model.ExportTimestamp{ExpiresAt: reset.expiresAt, UsedAt: reset.usedAt})
+		}
+	}
+	sortTimestamps(export.Sessions.PasswordResets)
+
+	for _, used := range stored.recoveryCodes {
+		if !used {
+			export.Sessions.RecoveryCodes++
+		}
+	}
+
+	export.Invitations = []model.ExportInvitation{}
+	for _, invitation := range s.invitations {
+		if invitation.createdBy != userId && invitation.usedBy != userId {
+			continue
+		}
+		export.Invitations = append(export.Invitations, model.ExportInvitation{
+			Role:      string(invitation.role),
+			Created:   invitation.createdBy == userId,
+			CreatedAt: invitation.createdAt,
+			ExpiresAt: invitation.expiresAt,
+			UsedAt:    invitation.usedAt,
+		})
+	}
+	sort.Slice(export.Invitations, func(i, j int) bool {
+		return export.Invitations[i].CreatedAt.Before(export.Invitations[j].CreatedAt)
+	})
+
+	export.AuthoredChanges = []model.ExportAuditEvent{}
+	export.SecurityEvents = []model.ExportAuditEvent{}
+	for _, event := range s.authAudit {
+		exported := model.ExportAuditEvent{
+			Event:     event.event,
+			Username:  optionalString(event.username),
+			Ip:        optionalString(event.ip),
+			CreatedAt: event.createdAt,
+		}
+		if event.actorId == userId {
+			export.AuthoredChanges = append(export.AuthoredChanges, exported)
+		}
+		if event.userId == userId {
+			export.SecurityEvents = append(export.SecurityEvents, exported)
+		}
+	}
+
+	// entries returns the newest first, the export lists the oldest first
+	changes, _ := s.entries(len(s.audit), 0, func(entry *auditEntry) bool {
+		return entry.entry.ActorID != nil && *entry.entry.ActorID == userId
+	})
+	for i, j := 0, len(changes)-1; i < j; i, j = i+1, j-1 {
+		changes[i], changes[j] = changes[j], changes[i]
+	}
+	export.PlayerChanges = changes
+
+	return export, nil
+}
+
+func sortTimestamps(timestamps []model.ExportTimestamp) {
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i].ExpiresAt.Before(timestamps[j].ExpiresAt) })
+}
+
+// EraseUser deletes a user like the Postgres repository does: auth events
+// about them are kept under a pseudonym and every event about or by them
+// loses its address.
+func (s *Store) EraseUser(ctx context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return nil
+	}
+
+	pseudonym := db.ErasedUsername(userId)
+	for _, event := range s.authAudit {
+		if event.userId == userId {
+			event.username = pseudonym
+		}
+		if event.userId == userId || event.actorId == userId {
+			event.ip = ""
+		}
+	}
+
+	delete(s.loginFailures, accountKey(stored.user.Username))
+	s.removeUser(userId)
+
+	s.authAudit = append(s.authAudit, &authEvent{
+		event:     db.AuditAccountErased,
+		username:  pseudonym,
+		actorId:   userId,
+		userId:    userId,
+		createdAt: now(),
+	})
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+func (s *Store) RequireTwoFactor(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requireTwoFactor, nil
+}
+
+func (s *Store) SetRequireTwoFactor(ctx context.Context, required bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requireTwoFactor = required
+
+	return nil
+}
+
+func (s *Store) GetRegistrationMode(ctx context.Context) (model.RegistrationMode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.registrationMode.IsValid() {
+		return model.RegistrationModeOpen, nil
+	}
+
+	return s.registrationMode, nil
+}
+
+func (s *Store) SetRegistrationMode(ctx context.Context, mode model.RegistrationMode) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.registrationMode = mode
+
+	return nil
+}
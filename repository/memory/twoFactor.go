@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+)
+
+type loginChallenge struct {
+	userId    string
+	expiresAt time.Time
+	attempts  int
+}
+
+func (s *Store) SetTotpSecret(ctx context.Context, userId string, secret string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok || *stored.user.TwoFactorEnabled {
+		return false, nil
+	}
+
+	stored.totpSecret = secret
+	stored.totpLastStep = nil
+
+	return true, nil
+}
+
+func (s *Store) GetTotp(ctx context.Context, userId string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return "", false, nil
+	}
+
+	return stored.totpSecret, *stored.user.TwoFactorEnabled, nil
+}
+
+func (s *Store) UseTotpStep(ctx context.Context, userId string, step int64) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok || (stored.totpLastStep != nil && *stored.totpLastStep >= step) {
+		return false, nil
+	}
+
+	stored.totpLastStep = &step
+
+	return true, nil
+}
+
+func (s *Store) EnableTotp(ctx context.Context, userId string, step int64, codeHashes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return nil
+	}
+
+	enabled := true
+	stored.user.TwoFactorEnabled = &enabled
+	stored.totpLastStep = &step
+	stored.recoveryCodes = map[string]bool{}
+	for _, codeHash := range codeHashes {
+		stored.recoveryCodes[codeHash] = false
+	}
+
+	return nil
+}
+
+func (s *Store) DisableTotp(ctx context.Context, userId string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return nil
+	}
+
+	enabled := false
+	stored.user.TwoFactorEnabled = &enabled
+	stored.totpSecret = ""
+	stored.totpLastStep = nil
+	stored.recoveryCodes = nil
+
+	return nil
+}
+
+func (s *Store) UseRecoveryCode(ctx context.Context, userId string, codeHash string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[userId]
+	if !ok {
+		return false, nil
+	}
+
+	used, exists := stored.recoveryCodes[codeHash]
+	if !exists || used {
+		return false, nil
+	}
+	stored.recoveryCodes[codeHash] = true
+
+	return true, nil
+}
+
+func (s *Store) CreateLoginChallenge(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.challenges[tokenHash] = &loginChallenge{userId: userId, expiresAt: expiresAt}
+
+	return nil
+}
+
+func (s *Store) ReserveChallengeAttempt(ctx context.Context, tokenHash string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	challenge, ok := s.challenges[tokenHash]
+	if !ok || !challenge.expiresAt.After(time.Now()) || challenge.attempts >= db.MaxChallengeAttempts {
+		return "", nil
+	}
+	challenge.attempts++
+
+	return challenge.userId, nil
+}
+
+// DeleteLoginChallenge removes a challenge along with the expired ones.
+func (s *Store) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := time.Now()
+	for hash, challenge := range s.challenges {
+		if hash == tokenHash || challenge.expiresAt.Before(current) {
+			delete(s.challenges, hash)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,358 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+type storedUser struct {
+	user             model.User
+	password         string
+	tokensValidAfter time.Time
+
+	totpSecret string
+	// totpLastStep is the step of the last accepted code, nil before the
+	// first
+	totpLastStep *int64
+	// recoveryCodes tells by hash whether each code was used
+	recoveryCodes map[string]bool
+}
+
+func copyUser(user *model.User) *model.User {
+	c := *user
+	c.Email = copyString(user.Email)
+	twoFactorEnabled := *user.TwoFactorEnabled
+	c.TwoFactorEnabled = &twoFactorEnabled
+	disabled := *user.Disabled
+	c.Disabled = &disabled
+	return &c
+}
+
+// byUsername returns the user with a username, or nil. The caller holds mu.
+func (s *Store) byUsername(username string) *storedUser {
+	for _, stored := range s.users {
+		if stored.user.Username == username {
+			return stored
+		}
+	}
+	return nil
+}
+
+// endSessions rejects every token issued to a user so far. The caller holds
+// mu.
+func (s *Store) endSessions(userId string) {
+	if stored, ok := s.users[userId]; ok {
+		stored.tokensValidAfter = now()
+	}
+}
+
+// removeUser deletes a user along with their memberships, password resets
+// and login challenges. Invitations forget who created or used them. The
+// caller holds mu.
+func (s *Store) removeUser(userId string) {
+	delete(s.users, userId)
+
+	for _, org := range s.organizations {
+		delete(org.members, userId)
+	}
+	for hash, reset := range s.resets {
+		if reset.userId == userId {
+			delete(s.resets, hash)
+		}
+	}
+	for hash, challenge := range s.challenges {
+		if challenge.userId == userId {
+			delete(s.challenges, hash)
+		}
+	}
+	for _, invitation := range s.invitations {
+		if invitation.createdBy == userId {
+			invitation.createdBy = ""
+		}
+		if invitation.usedBy == userId {
+			invitation.usedBy = ""
+		}
+	}
+}
+
+func (s *Store) GetUserId(ctx context.Context, username string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.byUsername(username); stored != nil {
+		return stored.user.ID, nil
+	}
+	return "", nil
+}
+
+func (s *Store) GetUser(ctx context.Context, username string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.byUsername(username); stored != nil {
+		return copyUser(&stored.user), nil
+	}
+	return nil, nil
+}
+
+func (s *Store) GetUserById(ctx context.Context, id string) (*model.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored, ok := s.users[id]; ok {
+		return copyUser(&stored.user), nil
+	}
+	return nil, nil
+}
+
+func (s *Store) GetUsers(ctx context.Context, filter *model.UserFilter, first int, after string) ([]*model.User, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	users := []*model.User{}
+	for _, stored := range s.users {
+		user := &stored.user
+		if user.Username <= after {
+			continue
+		}
+		if filter != nil {
+			if filter.Username != nil && !strings.Contains(strings.ToLower(user.Username), strings.ToLower(*filter.Username)) {
+				continue
+			}
+			if filter.Role != nil && user.Role != *filter.Role {
+				continue
+			}
+			if filter.Disabled != nil && *user.Disabled != *filter.Disabled {
+				continue
+			}
+		}
+		users = append(users, copyUser(user))
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+
+	if len(users) > first {
+		return users[:first], true, nil
+	}
+
+	return users, false, nil
+}
+
+func (s *Store) GetUserStatus(ctx context.Context, id string) (*repository.UserStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.users[id]
+	if !ok {
+		return &repository.UserStatus{}, nil
+	}
+
+	return &repository.UserStatus{Exists: true, Disabled: *stored.user.Disabled, TokensValidAfter: stored.tokensValidAfter}, nil
+}
+
+func (s *Store) CreateUser(ctx context.Context, user model.InputUser, invitationHash string) (string, error) {
+	hashedPassword, err := utils.HashPasswordContext(ctx, user.Password)
+	if err != nil {
+		return "", fmt.Errorf("could not hash user password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// The invitation is only used up once the user is sure to be created
+	role := model.RoleUser
+	orgRole := model.OrgRoleViewer
+	var used *invitation
+	org := s.organizations[db.DefaultOrganizationId]
+	if invitationHash != "" {
+		used = s.invitations[invitationHash]
+		if !used.valid() {
+			return "", db.ErrInvalidInvitation
+		}
+		role, orgRole = used.role, used.orgRole
+		// Postgres deletes the invitations of an organization with it
+		if org = s.organizations[used.orgId]; org == nil {
+			return "", db.ErrInvalidInvitation
+		}
+	}
+	if org == nil {
+		return "", db.ErrNoOrganization
+	}
+
+	if s.byUsername(user.Username) != nil {
+		return "", db.ErrUsernameTaken
+	}
+
+	twoFactorEnabled := false
+	disabled := false
+	id := newId()
+
+	if used != nil {
+		usedAt := now()
+		used.usedAt = &usedAt
+		used.usedBy = id
+	}
+	org.members[id] = &membership{role: orgRole, joinedAt: now()}
+	s.users[id] = &storedUser{
+		user: model.User{
+			ID:               id,
+			Username:         user.Username,
+			Role:             role,
+			Email:            copyString(user.Email),
+			TwoFactorEnabled: &twoFactorEnabled,
+			CreatedAt:        now().Format(time.RFC3339),
+			Disabled:         &disabled,
+		},
+		password: hashedPassword,
+	}
+
+	return id, nil
+}
+
+// Authenticate checks a user's password and, like the Postgres repository,
+// hashes a correct one again when its hash was made under an older policy.
+func (s *Store) Authenticate(ctx context.Context, user model.InputUser) (bool, error) {
+	s.mu.Lock()
+	stored := s.byUsername(user.Username)
+	hash := ""
+	if stored != nil {
+		hash = stored.password
+	}
+	s.mu.Unlock()
+
+	correct, err := utils.CheckPasswordHashContext(ctx, user.Password, hash)
+	if err != nil {
+		return false, fmt.Errorf("could not check password: %w", err)
+	}
+	if !correct {
+		return false, nil
+	}
+
+	if utils.NeedsRehash(hash) {
+		if err := s.rehashPassword(ctx, stored, user.Password, hash); err != nil {
+			log.Printf("could not rehash password for %s: %v", user.Username, err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *Store) rehashPassword(ctx context.Context, stored *storedUser, password string, oldHash string) error {
+	hashedPassword, err := utils.HashPasswordContext(ctx, password)
+	if err != nil {
+		return fmt.Errorf("could not hash user password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Only replace the hash we verified against, in case the password was
+	// changed in the meantime
+	if stored.password == oldHash {
+		stored.password = hashedPassword
+	}
+
+	return nil
+}
+
+func (s *Store) UpdateUsername(ctx context.Context, user model.UpdateUsername) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID == nil {
+		return nil
+	}
+	stored, ok := s.users[*user.ID]
+	if !ok {
+		return nil
+	}
+
+	if other := s.byUsername(user.NewUsername); other != nil && other != stored {
+		return db.ErrUsernameTaken
+	}
+	stored.user.Username = user.NewUsername
+
+	return nil
+}
+
+func (s *Store) UpdatePassword(ctx context.Context, user model.UpdatePassword) error {
+	hashedPassword, err := utils.HashPasswordContext(ctx, user.NewPassword)
+	if err != nil {
+		return fmt.Errorf("could not hash user password: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if user.ID == nil {
+		return nil
+	}
+	if stored, ok := s.users[*user.ID]; ok {
+		stored.password = hashedPassword
+		stored.tokensValidAfter = now()
+	}
+
+	return nil
+}
+
+func (s *Store) DeleteUser(ctx context.Context, username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if stored := s.byUsername(username); stored != nil {
+		s.removeUser(stored.user.ID)
+	}
+
+	return nil
+}
+
+// changeUser applies change to the user with a username and returns its id,
+// empty if there is no such user.
+func (s *Store) changeUser(username string, change func(stored *storedUser)) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := s.byUsername(username)
+	if stored == nil {
+		return ""
+	}
+
+	change(stored)
+	return stored.user.ID
+}
+
+func (s *Store) SetUserDisabled(ctx context.Context, username string, disabled bool, actorId string) (string, error) {
+	return s.changeUser(username, func(stored *storedUser) {
+		stored.user.Disabled = &disabled
+
+		event := db.AuditUserEnabled
+		if disabled {
+			stored.tokensValidAfter = now()
+			event = db.AuditUserDisabled
+		}
+		s.recordAuth(event, username, "", actorId)
+	}), nil
+}
+
+func (s *Store) SetUserRole(ctx context.Context, username string, role model.Role, actorId string) (string, error) {
+	return s.changeUser(username, func(stored *storedUser) {
+		stored.user.Role = role
+		stored.tokensValidAfter = now()
+		s.recordAuth(db.AuditRoleChanged, username, "", actorId)
+	}), nil
+}
+
+func (s *Store) ForceLogout(ctx context.Context, username string, actorId string) (string, error) {
+	return s.changeUser(username, func(stored *storedUser) {
+		stored.tokensValidAfter = now()
+		s.recordAuth(db.AuditForcedLogout, username, "", actorId)
+	}), nil
+}
@@ -0,0 +1,303 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// Postgres stores everything in a Postgres database migrated by db.Migrate.
+type Postgres struct {
+	conn *sql.DB
+}
+
+// NewPostgres returns the repositories of the database conn is connected to.
+func NewPostgres(conn *sql.DB) Postgres {
+	return Postgres{conn: conn}
+}
+
+var (
+	_ PlayerRepository       = Postgres{}
+	_ UserRepository         = Postgres{}
+	_ AuthRepository         = Postgres{}
+	_ OrganizationRepository = Postgres{}
+	_ SettingsRepository     = Postgres{}
+)
+
+func bulkResults(results []db.BulkResult, committed bool, err error) ([]BulkResult, bool, error) {
+	if err != nil {
+		return nil, false, err
+	}
+
+	converted := make([]BulkResult, len(results))
+	for i, result := range results {
+		converted[i] = BulkResult{Player: result.Player, Err: result.Err}
+	}
+
+	return converted, committed, nil
+}
+
+func (p Postgres) GetPlayer(ctx context.Context, orgId string, name string) (*model.Player, error) {
+	return db.GetPlayer(ctx, p.conn, orgId, name)
+}
+
+func (p Postgres) GetPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, first int, after string) ([]*model.Player, bool, error) {
+	return db.GetPlayers(ctx, p.conn, orgId, filter, first, after)
+}
+
+func (p Postgres) StreamPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, fn func(*model.Player) error) error {
+	return db.StreamPlayers(ctx, p.conn, orgId, filter, fn)
+}
+
+func (p Postgres) CreatePlayer(ctx context.Context, orgId string, actorId string, player model.InputPlayer) (*model.Player, error) {
+	return db.CreatePlayer(ctx, p.conn, orgId, actorId, player)
+}
+
+func (p Postgres) UpdatePlayer(ctx context.Context, orgId string, actorId string, player model.InputUpdatePlayer, expectedVersion int) (*model.Player, error) {
+	return db.UpdatePlayer(ctx, p.conn, orgId, actorId, player, expectedVersion)
+}
+
+func (p Postgres) DeletePlayer(ctx context.Context, orgId string, actorId string, name string, expectedVersion int) (*model.Player, error) {
+	return db.DeletePlayer(ctx, p.conn, orgId, actorId, name, expectedVersion)
+}
+
+func (p Postgres) CreatePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error) {
+	return bulkResults(db.CreatePlayers(ctx, p.conn, orgId, actorId, players, atomic))
+}
+
+func (p Postgres) UpsertPlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error) {
+	return bulkResults(db.UpsertPlayers(ctx, p.conn, orgId, actorId, players, atomic))
+}
+
+func (p Postgres) DeletePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputDeletePlayer, atomic bool) ([]BulkResult, bool, error) {
+	return bulkResults(db.DeletePlayers(ctx, p.conn, orgId, actorId, players, atomic))
+}
+
+func (p Postgres) GetDeletedPlayers(ctx context.Context, orgId string) ([]*model.Player, error) {
+	return db.GetDeletedPlayers(ctx, p.conn, orgId)
+}
+
+func (p Postgres) RestorePlayer(ctx context.Context, orgId string, actorId string, id string) (*model.Player, error) {
+	return db.RestorePlayer(ctx, p.conn, orgId, actorId, id)
+}
+
+func (p Postgres) GetPlayerStats(ctx context.Context, orgId string, playerId string, season *string, seasonType *model.SeasonType) ([]*model.Stats, error) {
+	return db.GetPlayerStats(ctx, p.conn, orgId, playerId, season, seasonType)
+}
+
+func (p Postgres) GetPlayerCareer(ctx context.Context, orgId string, player *model.Player, seasonType model.SeasonType) (*model.Career, error) {
+	return db.GetPlayerCareer(ctx, p.conn, orgId, player, seasonType)
+}
+
+func (p Postgres) GetPlayerHistory(ctx context.Context, orgId string, playerId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	return db.GetPlayerHistory(ctx, p.conn, orgId, playerId, first, before)
+}
+
+func (p Postgres) GetAuditLog(ctx context.Context, orgId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error) {
+	return db.GetAuditLog(ctx, p.conn, orgId, first, before)
+}
+
+func (p Postgres) GetUserId(ctx context.Context, username string) (string, error) {
+	return db.GetUserId(p.conn, username)
+}
+
+func (p Postgres) GetUser(ctx context.Context, username string) (*model.User, error) {
+	return db.GetUser(p.conn, username)
+}
+
+func (p Postgres) GetUserById(ctx context.Context, id string) (*model.User, error) {
+	return db.GetUserById(ctx, p.conn, id)
+}
+
+func (p Postgres) GetUsers(ctx context.Context, filter *model.UserFilter, first int, after string) ([]*model.User, bool, error) {
+	return db.GetUsers(ctx, p.conn, filter, first, after)
+}
+
+func (p Postgres) GetUserStatus(ctx context.Context, id string) (*UserStatus, error) {
+	status, err := db.GetUserStatus(ctx, p.conn, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserStatus{Exists: status.Exists, Disabled: status.Disabled, TokensValidAfter: status.TokensValidAfter}, nil
+}
+
+func (p Postgres) CreateUser(ctx context.Context, user model.InputUser, invitationHash string) (string, error) {
+	return db.CreateUsr(ctx, p.conn, user, invitationHash)
+}
+
+func (p Postgres) Authenticate(ctx context.Context, user model.InputUser) (bool, error) {
+	return db.Authenticate(ctx, p.conn, user)
+}
+
+func (p Postgres) UpdateUsername(ctx context.Context, user model.UpdateUsername) error {
+	return db.UpdateUsername(ctx, p.conn, user)
+}
+
+func (p Postgres) UpdatePassword(ctx context.Context, user model.UpdatePassword) error {
+	return db.UpdatePassword(ctx, p.conn, user)
+}
+
+func (p Postgres) DeleteUser(ctx context.Context, username string) error {
+	return db.DeleteUser(ctx, p.conn, username)
+}
+
+func (p Postgres) SetUserDisabled(ctx context.Context, username string, disabled bool, actorId string) (string, error) {
+	return db.SetUserDisabled(ctx, p.conn, username, disabled, actorId)
+}
+
+func (p Postgres) SetUserRole(ctx context.Context, username string, role model.Role, actorId string) (string, error) {
+	return db.SetUserRole(ctx, p.conn, username, role, actorId)
+}
+
+func (p Postgres) ForceLogout(ctx context.Context, username string, actorId string) (string, error) {
+	return db.ForceLogout(ctx, p.conn, username, actorId)
+}
+
+func (p Postgres) EraseUser(ctx context.Context, userId string) error {
+	return db.EraseUser(ctx, p.conn, userId)
+}
+
+func (p Postgres) ExportUserData(ctx context.Context, userId string) (*model.UserExport, error) {
+	return db.ExportUserData(ctx, p.conn, userId)
+}
+
+func (p Postgres) CreateInvitation(ctx context.Context, codeHash string, role model.Role, orgId string, orgRole model.OrgRole, createdBy string, expiresAt time.Time) error {
+	return db.CreateInvitation(ctx, p.conn, codeHash, role, orgId, orgRole, createdBy, expiresAt)
+}
+
+func (p Postgres) InvitationValid(ctx context.Context, codeHash string) (bool, error) {
+	return db.InvitationValid(ctx, p.conn, codeHash)
+}
+
+func (p Postgres) CreatePasswordReset(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error {
+	return db.CreatePasswordReset(ctx, p.conn, userId, tokenHash, expiresAt)
+}
+
+func (p Postgres) PasswordResetValid(ctx context.Context, tokenHash string) (bool, error) {
+	return db.PasswordResetValid(ctx, p.conn, tokenHash)
+}
+
+func (p Postgres) ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (string, string, error) {
+	return db.ResetPassword(ctx, p.conn, tokenHash, hashedPassword)
+}
+
+func (p Postgres) ReserveLoginAttempt(ctx context.Context, username string, ip string) error {
+	return db.ReserveLoginAttempt(ctx, p.conn, username, ip)
+}
+
+func (p Postgres) RecordLoginFailure(ctx context.Context, username string, ip string) error {
+	return db.RecordLoginFailure(ctx, p.conn, username, ip)
+}
+
+func (p Postgres) RecordLoginSuccess(ctx context.Context, username string, ip string) error {
+	return db.RecordLoginSuccess(ctx, p.conn, username, ip)
+}
+
+func (p Postgres) ClearLoginFailures(ctx context.Context, username string) error {
+	return db.ClearLoginFailures(ctx, p.conn, username)
+}
+
+func (p Postgres) UnlockAccount(ctx context.Context, username string, actorId string) error {
+	return db.UnlockAccount(ctx, p.conn, username, actorId)
+}
+
+func (p Postgres) ReservePasswordReset(ctx context.Context, username string, ip string) (bool, error) {
+	return db.ReservePasswordReset(ctx, p.conn, username, ip)
+}
+
+func (p Postgres) SetTotpSecret(ctx context.Context, userId string, secret string) (bool, error) {
+	return db.SetTotpSecret(ctx, p.conn, userId, secret)
+}
+
+func (p Postgres) GetTotp(ctx context.Context, userId string) (string, bool, error) {
+	return db.GetTotp(ctx, p.conn, userId)
+}
+
+func (p Postgres) UseTotpStep(ctx context.Context, userId string, step int64) (bool, error) {
+	return db.UseTotpStep(ctx, p.conn, userId, step)
+}
+
+func (p Postgres) EnableTotp(ctx context.Context, userId string, step int64, codeHashes []string) error {
+	return db.EnableTotp(ctx, p.conn, userId, step, codeHashes)
+}
+
+func (p Postgres) DisableTotp(ctx context.Context, userId string) error {
+	return db.DisableTotp(ctx, p.conn, userId)
+}
+
+func (p Postgres) UseRecoveryCode(ctx context.Context, userId string, codeHash string) (bool, error) {
+	return db.UseRecoveryCode(ctx, p.conn, userId, codeHash)
+}
+
+func (p Postgres) CreateLoginChallenge(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error {
+	return db.CreateLoginChallenge(ctx, p.conn, userId, tokenHash, expiresAt)
+}
+
+func (p Postgres) ReserveChallengeAttempt(ctx context.Context, tokenHash string) (string, error) {
+	return db.ReserveChallengeAttempt(ctx, p.conn, tokenHash)
+}
+
+func (p Postgres) DeleteLoginChallenge(ctx context.Context, tokenHash string) error {
+	return db.DeleteLoginChallenge(ctx, p.conn, tokenHash)
+}
+
+func (p Postgres) InsertAuthAudit(ctx context.Context, event string, username string, ip string, actorId string) error {
+	return db.InsertAuthAudit(ctx, p.conn, event, username, ip, actorId)
+}
+
+func (p Postgres) CreateOrganization(ctx context.Context, name string, creatorId string) (*model.Organization, error) {
+	return db.CreateOrganization(ctx, p.conn, name, creatorId)
+}
+
+func (p Postgres) OrganizationExists(ctx context.Context, orgId string) (bool, error) {
+	return db.OrganizationExists(ctx, p.conn, orgId)
+}
+
+func (p Postgres) GetMembership(ctx context.Context, userId string, orgId string) (model.OrgRole, error) {
+	return db.GetMembership(ctx, p.conn, userId, orgId)
+}
+
+func (p Postgres) GetDefaultMembership(ctx context.Context, userId string) (string, model.OrgRole, error) {
+	return db.GetDefaultMembership(ctx, p.conn, userId)
+}
+
+func (p Postgres) CanEditAnyOrganization(ctx context.Context, userId string) (bool, error) {
+	return db.CanEditAnyOrganization(ctx, p.conn, userId)
+}
+
+func (p Postgres) GetOrganizations(ctx context.Context, userId string, all bool) ([]*model.Organization, error) {
+	return db.GetOrganizations(ctx, p.conn, userId, all)
+}
+
+func (p Postgres) GetOrganizationMembers(ctx context.Context, orgId string) ([]*model.OrganizationMember, error) {
+	return db.GetOrganizationMembers(ctx, p.conn, orgId)
+}
+
+func (p Postgres) SetOrganizationMember(ctx context.Context, orgId string, userId string, role model.OrgRole) error {
+	return db.SetOrganizationMember(ctx, p.conn, orgId, userId, role)
+}
+
+func (p Postgres) RemoveOrganizationMember(ctx context.Context, orgId string, userId string) error {
+	return db.RemoveOrganizationMember(ctx, p.conn, orgId, userId)
+}
+
+func (p Postgres) RequireTwoFactor(ctx context.Context) (bool, error) {
+	return db.RequireTwoFactor(ctx, p.conn)
+}
+
+func (p Postgres) SetRequireTwoFactor(ctx context.Context, required bool) error {
+	return db.SetSetting(ctx, p.conn, db.SettingRequireTwoFactor, strconv.FormatBool(required))
+}
+
+func (p Postgres) GetRegistrationMode(ctx context.Context) (model.RegistrationMode, error) {
+	return db.GetRegistrationMode(ctx, p.conn)
+}
+
+func (p Postgres) SetRegistrationMode(ctx context.Context, mode model.RegistrationMode) error {
+	return db.SetSetting(ctx, p.conn, db.SettingRegistrationMode, string(mode))
+}
@@ -0,0 +1,67 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/repository/repotest"
+)
+
+// TestPostgres runs the conformance suite against the database
+// TEST_DATABASE_URL points to, which it migrates first. Everything it creates
+// is left behind, so use a database of its own.
+func TestPostgres(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	conn, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err := conn.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(conn); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	repo := repository.NewPostgres(conn)
+	run := time.Now().UnixNano()
+	setups := 0
+
+	repotest.Run(t, func(t *testing.T) repotest.Setup {
+		setups++
+		prefix := fmt.Sprintf("repotest_%d_%d", run, setups)
+
+		creator, err := repo.CreateUser(ctx, model.InputUser{Username: prefix, Password: "correct horse"}, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		org, err := repo.CreateOrganization(ctx, prefix+"_a", creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		other, err := repo.CreateOrganization(ctx, prefix+"_b", creator)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repotest.Setup{
+			Players: repo, Users: repo, Auth: repo, Orgs: repo, Settings: repo,
+			OrgID: org.ID, OtherOrgID: other.ID,
+		}
+	})
+}
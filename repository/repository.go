@@ -0,0 +1,218 @@
+// Package repository defines what the resolvers need from storage, so they
+// can run against Postgres in production and against the in-memory store in
+// package memory elsewhere. Both report errors with the sentinels and error
+// types of package db, such as db.ErrPlayerNotFound and
+// db.VersionConflictError, and pass the conformance suite in package
+// repotest.
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// BulkResult is the outcome of one item of a bulk mutation. With an atomic
+// batch that was rolled back Player is what the item would have written.
+type BulkResult struct {
+	Player *model.Player
+	Err    error
+}
+
+// UserStatus is what authentication needs to know about a token's user
+// beyond the token itself.
+type UserStatus struct {
+	Exists   bool
+	Disabled bool
+	// TokensValidAfter is set when the user's sessions were ended, tokens
+	// issued up to then are rejected
+	TokensValidAfter time.Time
+}
+
+// PlayerRepository stores the players of organizations. Every method but
+// GetAuditLog only sees the players of organization orgId, and changes are
+// audited under the user actorId, which may be empty.
+type PlayerRepository interface {
+	// GetPlayer returns db.ErrPlayerNotFound for a name no live player has.
+	GetPlayer(ctx context.Context, orgId string, name string) (*model.Player, error)
+	// GetPlayers returns up to first players matching filter, ordered by
+	// name, starting after the name after, and whether there are more.
+	GetPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, first int, after string) ([]*model.Player, bool, error)
+	// StreamPlayers calls fn with every player matching filter, ordered by
+	// name, and stops at the first error fn returns.
+	StreamPlayers(ctx context.Context, orgId string, filter *model.PlayerFilter, fn func(*model.Player) error) error
+
+	CreatePlayer(ctx context.Context, orgId string, actorId string, player model.InputPlayer) (*model.Player, error)
+	// UpdatePlayer changes the fields an update sets on the player it names,
+	// if it is still at expectedVersion.
+	UpdatePlayer(ctx context.Context, orgId string, actorId string, player model.InputUpdatePlayer, expectedVersion int) (*model.Player, error)
+	// DeletePlayer moves a player to the trash, if it is still at
+	// expectedVersion.
+	DeletePlayer(ctx context.Context, orgId string, actorId string, name string, expectedVersion int) (*model.Player, error)
+
+	// The bulk methods apply every item and report each outcome. An atomic
+	// batch is only written when every item succeeded, otherwise the items
+	// that succeeded are. They report whether anything was written.
+	CreatePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error)
+	UpsertPlayers(ctx context.Context, orgId string, actorId string, players []*model.InputPlayer, atomic bool) ([]BulkResult, bool, error)
+	DeletePlayers(ctx context.Context, orgId string, actorId string, players []*model.InputDeletePlayer, atomic bool) ([]BulkResult, bool, error)
+
+	// GetDeletedPlayers returns the players in the trash, most recently
+	// deleted first.
+	GetDeletedPlayers(ctx context.Context, orgId string) ([]*model.Player, error)
+	// RestorePlayer takes a player out of the trash, or returns
+	// db.ErrPlayerNameTaken when another player has its name.
+	RestorePlayer(ctx context.Context, orgId string, actorId string, id string) (*model.Player, error)
+
+	// GetPlayerStats returns the stored stat lines of a player, newest season
+	// first, narrowed down by season and seasonType when they are set.
+	GetPlayerStats(ctx context.Context, orgId string, playerId string, season *string, seasonType *model.SeasonType) ([]*model.Stats, error)
+	GetPlayerCareer(ctx context.Context, orgId string, player *model.Player, seasonType model.SeasonType) (*model.Career, error)
+
+	// GetPlayerHistory returns up to first changes to a player, newest first,
+	// starting before the entry with id before (0 for the newest).
+	GetPlayerHistory(ctx context.Context, orgId string, playerId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error)
+	// GetAuditLog pages through the changes like GetPlayerHistory, across
+	// organizations or of one when orgId is set.
+	GetAuditLog(ctx context.Context, orgId string, first int, before int64) ([]*model.PlayerAuditEntry, bool, error)
+}
+
+// UserRepository stores user accounts. Lookups by username or id return an
+// empty id or a nil user when there is no such user, and changes to a user
+// that does not exist are no-ops.
+type UserRepository interface {
+	GetUserId(ctx context.Context, username string) (string, error)
+	GetUser(ctx context.Context, username string) (*model.User, error)
+	GetUserById(ctx context.Context, id string) (*model.User, error)
+	// GetUsers returns up to first users matching filter, ordered by
+	// username, starting after the username after, and whether there are
+	// more.
+	GetUsers(ctx context.Context, filter *model.UserFilter, first int, after string) ([]*model.User, bool, error)
+	// GetUserStatus returns what authentication needs to know about a user,
+	// with Exists false for a user that does not exist.
+	GetUserStatus(ctx context.Context, id string) (*UserStatus, error)
+
+	// CreateUser creates a user and returns its id. With an invitation code
+	// hash the invitation is used up and the user gets its role and joins
+	// its organization, or db.ErrInvalidInvitation is returned for one that
+	// cannot be used. Without one the user joins the default organization as
+	// a viewer. db.ErrNoOrganization is returned when there is no
+	// organization to join.
+	CreateUser(ctx context.Context, user model.InputUser, invitationHash string) (string, error)
+	// Authenticate checks a user's password.
+	Authenticate(ctx context.Context, user model.InputUser) (bool, error)
+	// UpdateUsername returns db.ErrUsernameTaken when another user has the
+	// new username.
+	UpdateUsername(ctx context.Context, user model.UpdateUsername) error
+	UpdatePassword(ctx context.Context, user model.UpdatePassword) error
+	DeleteUser(ctx context.Context, username string) error
+	// EraseUser deletes a user and everything that belongs to them, keeping
+	// the audit rows about them under a pseudonym.
+	EraseUser(ctx context.Context, userId string) error
+	// ExportUserData collects a user's data, nil if there is no such user.
+	ExportUserData(ctx context.Context, userId string) (*model.UserExport, error)
+
+	// The admin changes return the user's id, empty if there is no such
+	// user. Disabling a user, changing their role and ForceLogout end their
+	// sessions.
+	SetUserDisabled(ctx context.Context, username string, disabled bool, actorId string) (string, error)
+	SetUserRole(ctx context.Context, username string, role model.Role, actorId string) (string, error)
+	ForceLogout(ctx context.Context, username string, actorId string) (string, error)
+
+	// CreateInvitation stores the hash of an invitation code CreateUser can
+	// use up until expiresAt. The invited user joins organization orgId with
+	// orgRole.
+	CreateInvitation(ctx context.Context, codeHash string, role model.Role, orgId string, orgRole model.OrgRole, createdBy string, expiresAt time.Time) error
+	InvitationValid(ctx context.Context, codeHash string) (bool, error)
+
+	// CreatePasswordReset replaces any outstanding reset of a user.
+	CreatePasswordReset(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error
+	PasswordResetValid(ctx context.Context, tokenHash string) (bool, error)
+	// ResetPassword uses up a reset token, sets the password and ends the
+	// user's sessions. It returns the user's id and username, both empty
+	// for a token that cannot be used.
+	ResetPassword(ctx context.Context, tokenHash string, hashedPassword string) (string, string, error)
+}
+
+// AuthRepository keeps what logging in needs beyond the account itself:
+// failed attempts, second factors, login challenges and the auth audit log.
+type AuthRepository interface {
+	// ReserveLoginAttempt returns a db.LoginThrottledError while the account
+	// from this address, or the address, has to wait before trying again.
+	// Otherwise it reserves the attempt, atomically with the check, and the
+	// attempt counts like a failure until RecordLoginFailure or
+	// RecordLoginSuccess ends it, or db.AttemptTimeout passes.
+	ReserveLoginAttempt(ctx context.Context, username string, ip string) error
+	RecordLoginFailure(ctx context.Context, username string, ip string) error
+	// RecordLoginSuccess clears the failures of the account from the
+	// address, ends the address's attempt and records when the account
+	// logged in.
+	RecordLoginSuccess(ctx context.Context, username string, ip string) error
+	// ClearLoginFailures lifts the lockouts of an account from every
+	// address, UnlockAccount does the same and audits it.
+	ClearLoginFailures(ctx context.Context, username string) error
+	UnlockAccount(ctx context.Context, username string, actorId string) error
+	// ReservePasswordReset counts a reset request against the address and
+	// the username. It returns a db.ResetThrottledError when the address
+	// has requested too many, and false when the account has been sent
+	// enough reset mails for now, whether or not it exists.
+	ReservePasswordReset(ctx context.Context, username string, ip string) (bool, error)
+
+	// SetTotpSecret returns false when two factor authentication is enabled
+	// already.
+	SetTotpSecret(ctx context.Context, userId string, secret string) (bool, error)
+	// GetTotp returns a user's secret, empty if they never enrolled, and
+	// whether it has been confirmed.
+	GetTotp(ctx context.Context, userId string) (string, bool, error)
+	// UseTotpStep returns false for a step that is not newer than the last
+	// one used.
+	UseTotpStep(ctx context.Context, userId string, step int64) (bool, error)
+	EnableTotp(ctx context.Context, userId string, step int64, codeHashes []string) error
+	DisableTotp(ctx context.Context, userId string) error
+	// UseRecoveryCode returns false for a code that is unknown or used.
+	UseRecoveryCode(ctx context.Context, userId string, codeHash string) (bool, error)
+
+	CreateLoginChallenge(ctx context.Context, userId string, tokenHash string, expiresAt time.Time) error
+	// ReserveChallengeAttempt counts an attempt at a challenge, atomically
+	// with checking db.MaxChallengeAttempts, and returns the user it was
+	// issued to, empty if it is unknown, expired or used up.
+	ReserveChallengeAttempt(ctx context.Context, tokenHash string) (string, error)
+	DeleteLoginChallenge(ctx context.Context, tokenHash string) error
+
+	// InsertAuthAudit records a security relevant event about the account
+	// holding username, caused by the user actorId, which may be empty.
+	InsertAuthAudit(ctx context.Context, event string, username string, ip string, actorId string) error
+}
+
+// OrganizationRepository stores organizations and who belongs to them.
+// Lookups of a membership return an empty role for users that are not
+// members.
+type OrganizationRepository interface {
+	// CreateOrganization makes its creator an admin of it.
+	CreateOrganization(ctx context.Context, name string, creatorId string) (*model.Organization, error)
+	OrganizationExists(ctx context.Context, orgId string) (bool, error)
+	GetMembership(ctx context.Context, userId string, orgId string) (model.OrgRole, error)
+	// GetDefaultMembership returns the organization a user joined first.
+	GetDefaultMembership(ctx context.Context, userId string) (string, model.OrgRole, error)
+	// CanEditAnyOrganization reports whether a user is an editor or admin
+	// of any organization.
+	CanEditAnyOrganization(ctx context.Context, userId string) (bool, error)
+	// GetOrganizations returns the organizations a user belongs to, or all
+	// of them, ordered by name.
+	GetOrganizations(ctx context.Context, userId string, all bool) ([]*model.Organization, error)
+	GetOrganizationMembers(ctx context.Context, orgId string) ([]*model.OrganizationMember, error)
+	// Changing the role of a member or removing them ends their sessions.
+	SetOrganizationMember(ctx context.Context, orgId string, userId string, role model.OrgRole) error
+	RemoveOrganizationMember(ctx context.Context, orgId string, userId string) error
+}
+
+// SettingsRepository stores the settings admins change at runtime.
+type SettingsRepository interface {
+	// RequireTwoFactor is off until an admin turns it on.
+	RequireTwoFactor(ctx context.Context) (bool, error)
+	SetRequireTwoFactor(ctx context.Context, required bool) error
+	// GetRegistrationMode is open until an admin changes it.
+	GetRegistrationMode(ctx context.Context) (model.RegistrationMode, error)
+	SetRegistrationMode(ctx context.Context, mode model.RegistrationMode) error
+}
@@ -0,0 +1,239 @@
+package repotest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+)
+
+var authChecks = []check{
+	{"Lockout", checkLockout},
+	{"ConcurrentLogins", checkConcurrentLogins},
+	{"PasswordResetThrottle", checkPasswordResetThrottle},
+	{"TwoFactor", checkTwoFactor},
+	{"LoginChallenge", checkLoginChallenge},
+}
+
+func throttled(t *testing.T, err error) *db.LoginThrottledError {
+	t.Helper()
+
+	var throttledErr *db.LoginThrottledError
+	if !errors.As(err, &throttledErr) {
+		t.Fatalf("got error %v, want a throttled login", err)
+	}
+	return throttledErr
+}
+
+func checkLockout(t *testing.T, s Setup) {
+	username := unique("ada")
+	createUser(t, s, username)
+	// Without an address only the account is counted
+	const ip = ""
+
+	mustNot(t, s.Auth.ReserveLoginAttempt(ctx, username, ip))
+	mustNot(t, s.Auth.RecordLoginFailure(ctx, username, ip))
+
+	// The first few failures are free, after that every attempt has to wait
+	for i := 0; i < 2; i++ {
+		mustNot(t, s.Auth.RecordLoginFailure(ctx, username, ip))
+	}
+	if throttled(t, s.Auth.ReserveLoginAttempt(ctx, username, ip)).Locked {
+		t.Fatal("the account is locked after three failures")
+	}
+
+	mustNot(t, s.Auth.RecordLoginSuccess(ctx, username, ip))
+	mustNot(t, s.Auth.ReserveLoginAttempt(ctx, username, ip))
+
+	user, err := s.Users.GetUser(ctx, username)
+	mustNot(t, err)
+	if user.LastLoginAt == nil {
+		t.Fatalf("got user %+v, want the login recorded", user)
+	}
+
+	for i := 0; i < db.AccountLockThreshold; i++ {
+		mustNot(t, s.Auth.RecordLoginFailure(ctx, username, ip))
+	}
+	if !throttled(t, s.Auth.ReserveLoginAttempt(ctx, username, ip)).Locked {
+		t.Fatal("the account is not locked at the threshold")
+	}
+
+	// The lock only holds for the address that failed
+	mustNot(t, s.Auth.ReserveLoginAttempt(ctx, username, "192.0.2.2"))
+
+	mustNot(t, s.Auth.UnlockAccount(ctx, username, ""))
+	mustNot(t, s.Auth.ReserveLoginAttempt(ctx, username, ip))
+}
+
+func checkConcurrentLogins(t *testing.T, s Setup) {
+	username := unique("ada")
+	createUser(t, s, username)
+	const ip = "192.0.2.3"
+
+	// Attempts whose outcome is not known yet count like failures, so
+	// guesses sent at once cannot all get past the check
+	for i := 0; i < 3; i++ {
+		mustNot(t, s.Auth.ReserveLoginAttempt(ctx, username, ip))
+	}
+	if throttled(t, s.Auth.ReserveLoginAttempt(ctx, username, ip)).Locked {
+		t.Fatal("attempts in flight locked the account")
+	}
+
+	// A login from the address gives its attempt back
+	other := unique("bo")
+	createUser(t, s, other)
+	for i := 0; i < 3; i++ {
+		mustNot(t, s.Auth.ReserveLoginAttempt(ctx, other, "192.0.2.4"))
+		mustNot(t, s.Auth.RecordLoginSuccess(ctx, other, "192.0.2.4"))
+	}
+	mustNot(t, s.Auth.ReserveLoginAttempt(ctx, other, "192.0.2.4"))
+}
+
+func checkPasswordResetThrottle(t *testing.T, s Setup) {
+	username := unique("ada")
+	createUser(t, s, username)
+	// Addresses are counted for an hour, a fixed one would be throttled on
+	// the next run against the same database
+	ip := unique("address")
+
+	for i := 0; i < db.ResetAccountLimit; i++ {
+		allowed, err := s.Auth.ReservePasswordReset(ctx, username, ip)
+		mustNot(t, err)
+		if !allowed {
+			t.Fatalf("reset %d was throttled", i+1)
+		}
+	}
+	allowed, err := s.Auth.ReservePasswordReset(ctx, strings.ToUpper(username), ip)
+	mustNot(t, err)
+	if allowed {
+		t.Fatal("an account was sent more resets than the limit")
+	}
+
+	// Unknown usernames are counted the same
+	unknown := unique("nobody")
+	for i := 0; i < db.ResetAccountLimit; i++ {
+		_, err := s.Auth.ReservePasswordReset(ctx, unknown, "")
+		mustNot(t, err)
+	}
+	allowed, err = s.Auth.ReservePasswordReset(ctx, unknown, "")
+	mustNot(t, err)
+	if allowed {
+		t.Fatal("an unknown username was not throttled")
+	}
+
+	// Past its limit the address has to wait, whichever account it asks for
+	for i := db.ResetAccountLimit + 1; i < db.ResetIpLimit; i++ {
+		_, err := s.Auth.ReservePasswordReset(ctx, unique("bo"), ip)
+		mustNot(t, err)
+	}
+	var throttledErr *db.ResetThrottledError
+	if _, err := s.Auth.ReservePasswordReset(ctx, unique("bo"), ip); !errors.As(err, &throttledErr) || throttledErr.RetryAfter <= 0 {
+		t.Fatalf("got error %v, want a throttled reset", err)
+	}
+}
+
+func checkTwoFactor(t *testing.T, s Setup) {
+	id := createUser(t, s, unique("ada"))
+
+	secret, enabled, err := s.Auth.GetTotp(ctx, id)
+	mustNot(t, err)
+	if secret != "" || enabled {
+		t.Fatalf("got secret %q enabled %v, want none", secret, enabled)
+	}
+
+	set, err := s.Auth.SetTotpSecret(ctx, id, "first")
+	mustNot(t, err)
+	if !set {
+		t.Fatal("could not set a secret before enabling")
+	}
+
+	mustNot(t, s.Auth.EnableTotp(ctx, id, 10, []string{"code-a", "code-b"}))
+
+	secret, enabled, err = s.Auth.GetTotp(ctx, id)
+	mustNot(t, err)
+	if secret != "first" || !enabled {
+		t.Fatalf("got secret %q enabled %v, want first enabled", secret, enabled)
+	}
+
+	set, err = s.Auth.SetTotpSecret(ctx, id, "second")
+	mustNot(t, err)
+	if set {
+		t.Fatal("replaced the secret while enabled")
+	}
+
+	// A step is only accepted once and never after a later one
+	for _, c := range []struct {
+		step int64
+		want bool
+	}{{10, false}, {12, true}, {12, false}, {11, false}} {
+		used, err := s.Auth.UseTotpStep(ctx, id, c.step)
+		mustNot(t, err)
+		if used != c.want {
+			t.Fatalf("got %v for step %d, want %v", used, c.step, c.want)
+		}
+	}
+
+	for _, c := range []struct {
+		code string
+		want bool
+	}{{"code-a", true}, {"code-a", false}, {"unknown", false}} {
+		used, err := s.Auth.UseRecoveryCode(ctx, id, c.code)
+		mustNot(t, err)
+		if used != c.want {
+			t.Fatalf("got %v for recovery code %s, want %v", used, c.code, c.want)
+		}
+	}
+
+	mustNot(t, s.Auth.DisableTotp(ctx, id))
+
+	secret, enabled, err = s.Auth.GetTotp(ctx, id)
+	mustNot(t, err)
+	if secret != "" || enabled {
+		t.Fatalf("got secret %q enabled %v after disabling, want none", secret, enabled)
+	}
+	used, err := s.Auth.UseRecoveryCode(ctx, id, "code-b")
+	mustNot(t, err)
+	if used {
+		t.Fatal("a recovery code outlived two factor authentication")
+	}
+}
+
+func checkLoginChallenge(t *testing.T, s Setup) {
+	id := createUser(t, s, unique("ada"))
+
+	token := unique("challenge")
+	mustNot(t, s.Auth.CreateLoginChallenge(ctx, id, token, time.Now().Add(time.Minute)))
+
+	// Every attempt counts, whatever the code turns out to be
+	for i := 0; i < db.MaxChallengeAttempts; i++ {
+		userId, err := s.Auth.ReserveChallengeAttempt(ctx, token)
+		mustNot(t, err)
+		if userId != id {
+			t.Fatalf("got user %s for attempt %d, want %s", userId, i+1, id)
+		}
+	}
+	userId, err := s.Auth.ReserveChallengeAttempt(ctx, token)
+	mustNot(t, err)
+	if userId != "" {
+		t.Fatal("a challenge outlived its attempts")
+	}
+
+	expired := unique("challenge")
+	mustNot(t, s.Auth.CreateLoginChallenge(ctx, id, expired, time.Now().Add(-time.Minute)))
+	userId, err = s.Auth.ReserveChallengeAttempt(ctx, expired)
+	mustNot(t, err)
+	if userId != "" {
+		t.Fatal("an expired challenge was accepted")
+	}
+
+	answered := unique("challenge")
+	mustNot(t, s.Auth.CreateLoginChallenge(ctx, id, answered, time.Now().Add(time.Minute)))
+	mustNot(t, s.Auth.DeleteLoginChallenge(ctx, answered))
+	userId, err = s.Auth.ReserveChallengeAttempt(ctx, answered)
+	mustNot(t, err)
+	if userId != "" {
+		t.Fatal("a deleted challenge was accepted")
+	}
+}
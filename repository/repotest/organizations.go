@@ -0,0 +1,175 @@
+package repotest
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+var organizationChecks = []check{
+	{"Organizations", checkOrganizations},
+	{"OrganizationMembers", checkOrganizationMembers},
+	{"Settings", checkSettings},
+}
+
+func checkOrganizations(t *testing.T, s Setup) {
+	creator := createUser(t, s, unique("ada"))
+	name := unique("club")
+
+	org, err := s.Orgs.CreateOrganization(ctx, name, creator)
+	mustNot(t, err)
+	if org.ID == "" || org.Name != name || org.Role == nil || *org.Role != model.OrgRoleAdmin {
+		t.Fatalf("got organization %+v, want %s with the creator as admin", org, name)
+	}
+
+	if _, err := s.Orgs.CreateOrganization(ctx, name, creator); err == nil {
+		t.Fatal("created two organizations with the same name")
+	}
+
+	exists, err := s.Orgs.OrganizationExists(ctx, org.ID)
+	mustNot(t, err)
+	if !exists {
+		t.Fatal("the created organization does not exist")
+	}
+
+	role, err := s.Orgs.GetMembership(ctx, creator, org.ID)
+	mustNot(t, err)
+	if role != model.OrgRoleAdmin {
+		t.Fatalf("got role %q, want the creator as admin", role)
+	}
+
+	editor, err := s.Orgs.CanEditAnyOrganization(ctx, creator)
+	mustNot(t, err)
+	if !editor {
+		t.Fatal("the creator cannot edit their organization")
+	}
+
+	// A user who registered without an invitation is a viewer of the
+	// default organization only
+	stranger := createUser(t, s, unique("bo"))
+	orgId, role, err := s.Orgs.GetDefaultMembership(ctx, stranger)
+	mustNot(t, err)
+	if orgId != db.DefaultOrganizationId || role != model.OrgRoleViewer {
+		t.Fatalf("got default membership %s %s, want %s viewer", orgId, role, db.DefaultOrganizationId)
+	}
+
+	organizations, err := s.Orgs.GetOrganizations(ctx, stranger, false)
+	mustNot(t, err)
+	if len(organizations) != 1 || organizations[0].ID != db.DefaultOrganizationId {
+		t.Fatalf("got organizations %+v, want the default one", organizations)
+	}
+
+	// Every organization, with a role only where the user is a member
+	organizations, err = s.Orgs.GetOrganizations(ctx, stranger, true)
+	mustNot(t, err)
+	found := false
+	for _, organization := range organizations {
+		member := organization.ID == db.DefaultOrganizationId
+		if (organization.Role != nil) != member {
+			t.Fatalf("got role %v in %s, want one only in the default organization", organization.Role, organization.Name)
+		}
+		found = found || organization.ID == org.ID
+	}
+	if !found {
+		t.Fatalf("all organizations are missing %s", name)
+	}
+}
+
+func checkOrganizationMembers(t *testing.T, s Setup) {
+	creator := createUser(t, s, unique("ada"))
+	org, err := s.Orgs.CreateOrganization(ctx, unique("club"), creator)
+	mustNot(t, err)
+
+	memberName := unique("bo")
+	member := createUser(t, s, memberName)
+
+	// Joining does not end sessions, a change of role does
+	mustNot(t, s.Orgs.SetOrganizationMember(ctx, org.ID, member, model.OrgRoleViewer))
+	status, err := s.Users.GetUserStatus(ctx, member)
+	mustNot(t, err)
+	if !status.TokensValidAfter.IsZero() {
+		t.Fatalf("got status %+v, want the sessions kept on joining", status)
+	}
+
+	role, err := s.Orgs.GetMembership(ctx, member, org.ID)
+	mustNot(t, err)
+	if role != model.OrgRoleViewer {
+		t.Fatalf("got role %s, want viewer", role)
+	}
+	editor, err := s.Orgs.CanEditAnyOrganization(ctx, member)
+	mustNot(t, err)
+	if editor {
+		t.Fatal("a viewer can edit")
+	}
+
+	// Timestamps may come from another clock, so allow for some drift
+	before := time.Now().Add(-time.Minute)
+
+	mustNot(t, s.Orgs.SetOrganizationMember(ctx, org.ID, member, model.OrgRoleEditor))
+	status, err = s.Users.GetUserStatus(ctx, member)
+	mustNot(t, err)
+	if status.TokensValidAfter.Before(before) {
+		t.Fatalf("got status %+v, want the sessions ended by the new role", status)
+	}
+	editor, err = s.Orgs.CanEditAnyOrganization(ctx, member)
+	mustNot(t, err)
+	if !editor {
+		t.Fatal("an editor cannot edit")
+	}
+
+	members, err := s.Orgs.GetOrganizationMembers(ctx, org.ID)
+	mustNot(t, err)
+	if len(members) != 2 || members[0].UserID != creator || members[1].Username != memberName || members[1].Role != model.OrgRoleEditor {
+		t.Fatalf("got members %+v, want ada the admin and bo the editor", members)
+	}
+
+	organizations, err := s.Orgs.GetOrganizations(ctx, member, false)
+	mustNot(t, err)
+	found := false
+	for _, organization := range organizations {
+		found = found || (organization.ID == org.ID && *organization.Role == model.OrgRoleEditor)
+	}
+	if len(organizations) != 2 || !found {
+		t.Fatalf("got organizations %+v, want the default one and the one with the editor role", organizations)
+	}
+
+	// Removing a member ends their sessions, which may still act in it
+	viewer := createUser(t, s, unique("cy"))
+	mustNot(t, s.Orgs.SetOrganizationMember(ctx, org.ID, viewer, model.OrgRoleViewer))
+	mustNot(t, s.Orgs.RemoveOrganizationMember(ctx, org.ID, viewer))
+
+	role, err = s.Orgs.GetMembership(ctx, viewer, org.ID)
+	mustNot(t, err)
+	if role != "" {
+		t.Fatalf("got role %s for a removed member", role)
+	}
+	status, err = s.Users.GetUserStatus(ctx, viewer)
+	mustNot(t, err)
+	if status.TokensValidAfter.Before(before) {
+		t.Fatalf("got status %+v, want the sessions ended by the removal", status)
+	}
+}
+
+func checkSettings(t *testing.T, s Setup) {
+	required, err := s.Settings.RequireTwoFactor(ctx)
+	mustNot(t, err)
+	mode, err := s.Settings.GetRegistrationMode(ctx)
+	mustNot(t, err)
+	defer func() {
+		mustNot(t, s.Settings.SetRequireTwoFactor(ctx, required))
+		mustNot(t, s.Settings.SetRegistrationMode(ctx, mode))
+	}()
+
+	mustNot(t, s.Settings.SetRequireTwoFactor(ctx, !required))
+	mustNot(t, s.Settings.SetRegistrationMode(ctx, model.RegistrationModeInviteOnly))
+
+	gotRequired, err := s.Settings.RequireTwoFactor(ctx)
+	mustNot(t, err)
+	gotMode, err := s.Settings.GetRegistrationMode(ctx)
+	mustNot(t, err)
+	if gotRequired == required || gotMode != model.RegistrationModeInviteOnly {
+		t.Fatalf("got %v and %s, want %v and invite_only", gotRequired, gotMode, !required)
+	}
+}
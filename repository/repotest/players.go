@@ -0,0 +1,709 @@
+package repotest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/validate"
+)
+
+var playerChecks = []check{
+	{"CreateAndGetPlayer", checkCreateAndGetPlayer},
+	{"CreatePlayerChecks", checkCreatePlayerChecks},
+	{"UpdatePlayer", checkUpdatePlayer},
+	{"UpdatePlayerSeasonType", checkUpdatePlayerSeasonType},
+	{"ClearProfile", checkClearProfile},
+	{"DeleteAndRestorePlayer", checkDeleteAndRestorePlayer},
+	{"GetPlayers", checkGetPlayers},
+	{"StreamPlayers", checkStreamPlayers},
+	{"BulkPlayers", checkBulkPlayers},
+	{"PlayerCareer", checkPlayerCareer},
+	{"PlayerHistory", checkPlayerHistory},
+}
+
+// newPlayer returns a valid player at a lineup position.
+func newPlayer(name string, primary model.LineupPosition) model.InputPlayer {
+	return model.InputPlayer{
+		Name:            name,
+		PrimaryPosition: &primary,
+		Age:             intPointer(25),
+		Experience:      intPointer(3),
+		Stats: &model.InputStats{
+			Season:     "2022-23",
+			SeasonType: model.SeasonTypeRegular,
+			Points:     20,
+			ThreePt:    2,
+			Rebounds:   5,
+			Assists:    4,
+			Steals:     1,
+			Blocks:     0.5,
+			TurnOvers:  2,
+			Mp:         30,
+		},
+	}
+}
+
+func names(players []*model.Player) []string {
+	result := make([]string, len(players))
+	for i, player := range players {
+		result[i] = player.Name
+	}
+	return result
+}
+
+func sameNames(t *testing.T, players []*model.Player, want ...string) {
+	t.Helper()
+
+	got := names(players)
+	if len(got) != len(want) {
+		t.Fatalf("got players %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("got players %v, want %v", got, want)
+		}
+	}
+}
+
+func checkCreateAndGetPlayer(t *testing.T, s Setup) {
+	input := newPlayer("Ada", model.LineupPositionC)
+	input.EligiblePositions = []model.LineupPosition{model.LineupPositionPf, model.LineupPositionC}
+	input.Profile = &model.InputProfile{DraftYear: intPointer(2018), College: stringPointer("Duke")}
+
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", input)
+	mustNot(t, err)
+
+	if created.ID == "" || created.Name != "Ada" || created.Version != 1 || created.DeletedAt != nil {
+		t.Fatalf("unexpected created player %+v", created)
+	}
+	if created.Pos != model.PositionCenter || created.PrimaryPosition == nil || *created.PrimaryPosition != model.LineupPositionC {
+		t.Fatalf("got positions %v %v, want center and C", created.Pos, created.PrimaryPosition)
+	}
+	if len(created.EligiblePositions) != 2 || created.EligiblePositions[0] != model.LineupPositionC || created.EligiblePositions[1] != model.LineupPositionPf {
+		t.Fatalf("got eligible positions %v, want [C PF]", created.EligiblePositions)
+	}
+	if created.Age != 25 || created.Experience != 3 {
+		t.Fatalf("got age %d and experience %d, want 25 and 3", created.Age, created.Experience)
+	}
+	if created.Profile.DraftYear == nil || *created.Profile.DraftYear != 2018 || created.Profile.College == nil || *created.Profile.College != "Duke" {
+		t.Fatalf("unexpected profile %+v", created.Profile)
+	}
+	if created.Stats.Season != "2022-23" || created.Stats.SeasonType != model.SeasonTypeRegular || created.Stats.Points != 20 {
+		t.Fatalf("unexpected stats %+v", created.Stats)
+	}
+
+	got, err := s.Players.GetPlayer(ctx, s.OrgID, "Ada")
+	mustNot(t, err)
+	if got.ID != created.ID || got.Version != 1 || got.Stats.Mp != 30 {
+		t.Fatalf("got %+v, want the created player", got)
+	}
+
+	// A player without a season type plays the regular season
+	input = newPlayer("Bo", model.LineupPositionPg)
+	input.Stats.SeasonType = ""
+	created, err = s.Players.CreatePlayer(ctx, s.OrgID, "", input)
+	mustNot(t, err)
+	if created.Stats.SeasonType != model.SeasonTypeRegular {
+		t.Fatalf("got season type %s, want REGULAR", created.Stats.SeasonType)
+	}
+
+	_, err = s.Players.GetPlayer(ctx, s.OrgID, "Nobody")
+	mustBe(t, err, db.ErrPlayerNotFound)
+
+	_, err = s.Players.GetPlayer(ctx, s.OtherOrgID, "Ada")
+	mustBe(t, err, db.ErrPlayerNotFound)
+}
+
+func checkCreatePlayerChecks(t *testing.T, s Setup) {
+	_, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionSg))
+	mustNot(t, err)
+
+	_, err = s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionSg))
+	mustBe(t, err, db.ErrPlayerNameTaken)
+
+	// Names only have to be unique within an organization
+	_, err = s.Players.CreatePlayer(ctx, s.OtherOrgID, "", newPlayer("Ada", model.LineupPositionSg))
+	mustNot(t, err)
+
+	invalid := newPlayer("Bo", model.LineupPositionSg)
+	invalid.Stats.Mp = 99
+	_, err = s.Players.CreatePlayer(ctx, s.OrgID, "", invalid)
+
+	var validationErr *validate.Error
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+	if len(validationErr.Fields) != 1 || validationErr.Fields[0].Path != "stats.mp" {
+		t.Fatalf("got field errors %+v, want one for stats.mp", validationErr.Fields)
+	}
+
+	_, err = s.Players.GetPlayer(ctx, s.OrgID, "Bo")
+	mustBe(t, err, db.ErrPlayerNotFound)
+}
+
+func checkUpdatePlayer(t *testing.T, s Setup) {
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionSg))
+	mustNot(t, err)
+
+	updated, err := s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{Points: floatPointer(24.5)},
+	}, created.Version)
+	mustNot(t, err)
+
+	if updated.ID != created.ID || updated.Version != 2 {
+		t.Fatalf("got id %s at version %d, want %s at 2", updated.ID, updated.Version, created.ID)
+	}
+	if updated.Stats.Points != 24.5 || updated.Stats.Rebounds != 5 || updated.Age != 25 || updated.Pos != model.PositionGuard {
+		t.Fatalf("only points should have changed, got %+v %+v", updated, updated.Stats)
+	}
+
+	// A lineup position in another group moves the player to that group
+	updated, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:              stringPointer("Ada"),
+		PrimaryPosition:   positionPointer(model.LineupPositionSf),
+		EligiblePositions: []model.LineupPosition{model.LineupPositionSf},
+	}, updated.Version)
+	mustNot(t, err)
+	if updated.Pos != model.PositionForward || len(updated.EligiblePositions) != 1 {
+		t.Fatalf("got %s %v, want forward [SF]", updated.Pos, updated.EligiblePositions)
+	}
+
+	_, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{Points: floatPointer(1)},
+	}, created.Version)
+
+	var conflict *db.VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got error %v, want a version conflict", err)
+	}
+	if conflict.Current.Version != 3 || conflict.Current.Stats.Points != 24.5 {
+		t.Fatalf("got current %+v, want the player at version 3", conflict.Current)
+	}
+
+	_, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{Mp: floatPointer(-1)},
+	}, 3)
+	var validationErr *validate.Error
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a validation error", err)
+	}
+
+	got, err := s.Players.GetPlayer(ctx, s.OrgID, "Ada")
+	mustNot(t, err)
+	if got.Version != 3 || got.Stats.Mp != 30 {
+		t.Fatalf("a rejected update changed the player to %+v", got)
+	}
+
+	_, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{Name: stringPointer("Nobody")}, 1)
+	mustBe(t, err, db.ErrPlayerNotFound)
+
+	_, err = s.Players.UpdatePlayer(ctx, s.OtherOrgID, "", model.InputUpdatePlayer{Name: stringPointer("Ada")}, 3)
+	mustBe(t, err, db.ErrPlayerNotFound)
+}
+
+func positionPointer(position model.LineupPosition) *model.LineupPosition {
+	return &position
+}
+
+func checkClearProfile(t *testing.T, s Setup) {
+	player := newPlayer("Ada", model.LineupPositionPg)
+	player.Profile = &model.InputProfile{
+		College:    stringPointer("Duke"),
+		Country:    stringPointer("Canada"),
+		DraftYear:  intPointer(2019),
+		DraftRound: intPointer(1),
+	}
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", player)
+	mustNot(t, err)
+
+	// A field that is cleared and set takes its new value
+	updated, err := s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:         stringPointer("Ada"),
+		Profile:      &model.InputProfile{Country: stringPointer("Nigeria")},
+		ClearProfile: []model.ProfileField{model.ProfileFieldCollege, model.ProfileFieldCountry},
+	}, created.Version)
+	mustNot(t, err)
+	if updated.Profile.College != nil || updated.Profile.Country == nil || *updated.Profile.Country != "Nigeria" || updated.Profile.DraftYear == nil {
+		t.Fatalf("got profile %+v, want no college and the new country", updated.Profile)
+	}
+
+	got, err := s.Players.GetPlayer(ctx, s.OrgID, "Ada")
+	mustNot(t, err)
+	if got.Profile.College != nil {
+		t.Fatalf("the cleared college was stored as %q", *got.Profile.College)
+	}
+
+	// The cleared profile is checked like any other
+	_, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:         stringPointer("Ada"),
+		ClearProfile: []model.ProfileField{model.ProfileFieldDraftYear},
+	}, updated.Version)
+	var validationErr *validate.Error
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got error %v, want a draft round without a draft year rejected", err)
+	}
+}
+
+func checkUpdatePlayerSeasonType(t *testing.T, s Setup) {
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionPg))
+	mustNot(t, err)
+
+	playoffs := model.SeasonTypePlayoffs
+	updated, err := s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{SeasonType: &playoffs, Points: floatPointer(31)},
+	}, created.Version)
+	mustNot(t, err)
+
+	// Without a stored playoff line the new line starts from the current one
+	if updated.Stats.SeasonType != playoffs || updated.Stats.Points != 31 || updated.Stats.Rebounds != 5 {
+		t.Fatalf("unexpected playoff line %+v", updated.Stats)
+	}
+
+	regular := model.SeasonTypeRegular
+	updated, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{SeasonType: &regular, Rebounds: floatPointer(7)},
+	}, updated.Version)
+	mustNot(t, err)
+
+	// Going back starts from the stored regular season line
+	if updated.Stats.Points != 20 || updated.Stats.Rebounds != 7 {
+		t.Fatalf("got regular season line %+v, want 20 points and 7 rebounds", updated.Stats)
+	}
+
+	updated, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{Season: stringPointer("2023-24"), Points: floatPointer(22)},
+	}, updated.Version)
+	mustNot(t, err)
+
+	lines, err := s.Players.GetPlayerStats(ctx, s.OrgID, created.ID, nil, nil)
+	mustNot(t, err)
+
+	want := []struct {
+		season     string
+		seasonType model.SeasonType
+		points     float64
+	}{
+		{"2023-24", model.SeasonTypeRegular, 22},
+		{"2022-23", model.SeasonTypeRegular, 20},
+		{"2022-23", model.SeasonTypePlayoffs, 31},
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d stat lines, want %d", len(lines), len(want))
+	}
+	for i, line := range lines {
+		if line.Season != want[i].season || line.SeasonType != want[i].seasonType || line.Points != want[i].points {
+			t.Fatalf("got line %d %+v, want %+v", i, line, want[i])
+		}
+	}
+
+	lines, err = s.Players.GetPlayerStats(ctx, s.OrgID, created.ID, stringPointer("2022-23"), &playoffs)
+	mustNot(t, err)
+	if len(lines) != 1 || lines[0].Points != 31 {
+		t.Fatalf("got lines %+v, want the 2022-23 playoff line", lines)
+	}
+
+	lines, err = s.Players.GetPlayerStats(ctx, s.OtherOrgID, created.ID, nil, nil)
+	mustNot(t, err)
+	if len(lines) != 0 {
+		t.Fatalf("got %d lines of a player of another organization", len(lines))
+	}
+}
+
+func checkDeleteAndRestorePlayer(t *testing.T, s Setup) {
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionPg))
+	mustNot(t, err)
+
+	_, err = s.Players.DeletePlayer(ctx, s.OrgID, "", "Ada", created.Version+1)
+	var conflict *db.VersionConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("got error %v, want a version conflict", err)
+	}
+
+	deleted, err := s.Players.DeletePlayer(ctx, s.OrgID, "", "Ada", created.Version)
+	mustNot(t, err)
+	if deleted.DeletedAt == nil || deleted.Version != 2 {
+		t.Fatalf("got deleted player %+v, want it in the trash at version 2", deleted)
+	}
+
+	_, err = s.Players.GetPlayer(ctx, s.OrgID, "Ada")
+	mustBe(t, err, db.ErrPlayerNotFound)
+	_, err = s.Players.DeletePlayer(ctx, s.OrgID, "", "Ada", deleted.Version)
+	mustBe(t, err, db.ErrPlayerNotFound)
+
+	trash, err := s.Players.GetDeletedPlayers(ctx, s.OrgID)
+	mustNot(t, err)
+	if len(trash) != 1 || trash[0].ID != created.ID {
+		t.Fatalf("got trash %v, want Ada", names(trash))
+	}
+
+	trash, err = s.Players.GetDeletedPlayers(ctx, s.OtherOrgID)
+	mustNot(t, err)
+	if len(trash) != 0 {
+		t.Fatalf("got trash %v of another organization", names(trash))
+	}
+
+	// The name is free again, until the player is restored
+	replacement, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Ada", model.LineupPositionSg))
+	mustNot(t, err)
+
+	_, err = s.Players.RestorePlayer(ctx, s.OrgID, "", created.ID)
+	mustBe(t, err, db.ErrPlayerNameTaken)
+
+	_, err = s.Players.DeletePlayer(ctx, s.OrgID, "", "Ada", replacement.Version)
+	mustNot(t, err)
+
+	_, err = s.Players.RestorePlayer(ctx, s.OtherOrgID, "", created.ID)
+	mustBe(t, err, db.ErrPlayerNotFound)
+
+	restored, err := s.Players.RestorePlayer(ctx, s.OrgID, "", created.ID)
+	mustNot(t, err)
+	if restored.ID != created.ID || restored.DeletedAt != nil || restored.Version != 3 {
+		t.Fatalf("got restored player %+v, want Ada back at version 3", restored)
+	}
+
+	_, err = s.Players.RestorePlayer(ctx, s.OrgID, "", created.ID)
+	mustBe(t, err, db.ErrPlayerNotFound)
+
+	got, err := s.Players.GetPlayer(ctx, s.OrgID, "Ada")
+	mustNot(t, err)
+	if got.ID != created.ID {
+		t.Fatalf("got player %s, want the restored one", got.ID)
+	}
+}
+
+func checkGetPlayers(t *testing.T, s Setup) {
+	for _, input := range []model.InputPlayer{
+		newPlayer("Ada", model.LineupPositionPg),
+		newPlayer("Bo", model.LineupPositionSf),
+		newPlayer("Cy", model.LineupPositionC),
+		newPlayer("Di", model.LineupPositionSg),
+	} {
+		if input.Name == "Cy" {
+			input.EligiblePositions = []model.LineupPosition{model.LineupPositionC, model.LineupPositionPf}
+			input.Stats.Season = "2023-24"
+		}
+		_, err := s.Players.CreatePlayer(ctx, s.OrgID, "", input)
+		mustNot(t, err)
+	}
+	_, err := s.Players.CreatePlayer(ctx, s.OtherOrgID, "", newPlayer("Ed", model.LineupPositionPg))
+	mustNot(t, err)
+	_, err = s.Players.DeletePlayer(ctx, s.OrgID, "", "Di", 1)
+	mustNot(t, err)
+
+	players, hasNext, err := s.Players.GetPlayers(ctx, s.OrgID, nil, 2, "")
+	mustNot(t, err)
+	sameNames(t, players, "Ada", "Bo")
+	if !hasNext {
+		t.Fatal("want another page")
+	}
+
+	players, hasNext, err = s.Players.GetPlayers(ctx, s.OrgID, nil, 2, "Bo")
+	mustNot(t, err)
+	sameNames(t, players, "Cy")
+	if hasNext {
+		t.Fatal("want no other page")
+	}
+
+	forward := model.PositionForward
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, &model.PlayerFilter{Pos: &forward}, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Bo", "Cy")
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, &model.PlayerFilter{Position: positionPointer(model.LineupPositionPf)}, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Cy")
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, &model.PlayerFilter{Name: stringPointer("a")}, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Ada")
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, &model.PlayerFilter{Season: stringPointer("2022-23")}, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Ada", "Bo")
+
+	playoffs := model.SeasonTypePlayoffs
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, &model.PlayerFilter{SeasonType: &playoffs}, 10, "")
+	mustNot(t, err)
+	sameNames(t, players)
+}
+
+func checkStreamPlayers(t *testing.T, s Setup) {
+	for _, name := range []string{"Cy", "Ada", "Bo"} {
+		_, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer(name, model.LineupPositionPg))
+		mustNot(t, err)
+	}
+
+	streamed := []*model.Player{}
+	err := s.Players.StreamPlayers(ctx, s.OrgID, nil, func(player *model.Player) error {
+		streamed = append(streamed, player)
+		return nil
+	})
+	mustNot(t, err)
+	sameNames(t, streamed, "Ada", "Bo", "Cy")
+
+	stop := errors.New("stop")
+	calls := 0
+	err = s.Players.StreamPlayers(ctx, s.OrgID, nil, func(player *model.Player) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Fatalf("got error %v after %d calls, want stop after 1", err, calls)
+	}
+}
+
+func checkBulkPlayers(t *testing.T, s Setup) {
+	invalid := newPlayer("Cy", model.LineupPositionC)
+	invalid.Stats.Points = -1
+	batch := []*model.InputPlayer{
+		playerPointer(newPlayer("Ada", model.LineupPositionPg)),
+		&invalid,
+		playerPointer(newPlayer("Bo", model.LineupPositionSf)),
+	}
+
+	results, committed, err := s.Players.CreatePlayers(ctx, s.OrgID, "", batch, true)
+	mustNot(t, err)
+	if committed || len(results) != 3 || results[0].Err != nil || results[1].Err == nil || results[2].Err != nil {
+		t.Fatalf("got committed %v and results %+v, want a rolled back batch with the second item failed", committed, results)
+	}
+	if results[0].Player == nil || results[0].Player.Name != "Ada" {
+		t.Fatalf("got %+v, want the player the first item would have written", results[0].Player)
+	}
+
+	players, _, err := s.Players.GetPlayers(ctx, s.OrgID, nil, 10, "")
+	mustNot(t, err)
+	sameNames(t, players)
+
+	results, committed, err = s.Players.CreatePlayers(ctx, s.OrgID, "", batch, false)
+	mustNot(t, err)
+	if !committed || results[1].Err == nil {
+		t.Fatalf("got committed %v and results %+v, want a committed batch with the second item failed", committed, results)
+	}
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, nil, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Ada", "Bo")
+
+	// An upsert overwrites the players that exist, whatever their version
+	overwrite := newPlayer("Ada", model.LineupPositionSg)
+	overwrite.Stats.Points = 12
+	results, committed, err = s.Players.UpsertPlayers(ctx, s.OrgID, "", []*model.InputPlayer{
+		&overwrite,
+		playerPointer(newPlayer("Cy", model.LineupPositionC)),
+	}, true)
+	mustNot(t, err)
+	if !committed || results[0].Err != nil || results[1].Err != nil {
+		t.Fatalf("got committed %v and results %+v, want both items written", committed, results)
+	}
+	if results[0].Player.Version != 2 || results[0].Player.Stats.Points != 12 || *results[0].Player.PrimaryPosition != model.LineupPositionSg {
+		t.Fatalf("got %+v, want Ada overwritten", results[0].Player)
+	}
+	if len(results[0].Player.EligiblePositions) != 1 {
+		t.Fatalf("got eligible positions %v, want them replaced by [SG]", results[0].Player.EligiblePositions)
+	}
+	if results[1].Player.Version != 1 {
+		t.Fatalf("got %+v, want Cy created", results[1].Player)
+	}
+
+	results, committed, err = s.Players.DeletePlayers(ctx, s.OrgID, "", []*model.InputDeletePlayer{
+		{Name: "Ada", ExpectedVersion: 2},
+		{Name: "Bo", ExpectedVersion: 5},
+	}, true)
+	mustNot(t, err)
+	var conflict *db.VersionConflictError
+	if committed || results[0].Err != nil || !errors.As(results[1].Err, &conflict) {
+		t.Fatalf("got committed %v and results %+v, want a version conflict for Bo", committed, results)
+	}
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, nil, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Ada", "Bo", "Cy")
+
+	results, committed, err = s.Players.DeletePlayers(ctx, s.OrgID, "", []*model.InputDeletePlayer{
+		{Name: "Ada", ExpectedVersion: 2},
+		{Name: "Nobody", ExpectedVersion: 1},
+	}, false)
+	mustNot(t, err)
+	if !committed || results[0].Err != nil || !errors.Is(results[1].Err, db.ErrPlayerNotFound) {
+		t.Fatalf("got committed %v and results %+v, want Ada deleted", committed, results)
+	}
+
+	players, _, err = s.Players.GetPlayers(ctx, s.OrgID, nil, 10, "")
+	mustNot(t, err)
+	sameNames(t, players, "Bo", "Cy")
+}
+
+func playerPointer(player model.InputPlayer) *model.InputPlayer {
+	return &player
+}
+
+func checkPlayerCareer(t *testing.T, s Setup) {
+	input := newPlayer("Ada", model.LineupPositionPg)
+	input.Stats.GamesPlayed = intPointer(70)
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, "", input)
+	mustNot(t, err)
+
+	// 2022-23: 20 points in 30 minutes over 70 games, 2023-24: 30 points in
+	// 10 minutes over 10 games and a playoff line that does not count towards
+	// the regular season
+	player, err := s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name: stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{
+			Season:      stringPointer("2023-24"),
+			Points:      floatPointer(30),
+			Mp:          floatPointer(10),
+			Rebounds:    floatPointer(5),
+			GamesPlayed: intPointer(10),
+		},
+	}, created.Version)
+	mustNot(t, err)
+
+	playoffs := model.SeasonTypePlayoffs
+	player, err = s.Players.UpdatePlayer(ctx, s.OrgID, "", model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{SeasonType: &playoffs, Points: floatPointer(40), Mp: floatPointer(40)},
+	}, player.Version)
+	mustNot(t, err)
+
+	career, err := s.Players.GetPlayerCareer(ctx, s.OrgID, player, model.SeasonTypeRegular)
+	mustNot(t, err)
+
+	if career.SeasonType != model.SeasonTypeRegular || career.Seasons != 2 {
+		t.Fatalf("got %s career over %d seasons, want REGULAR over 2", career.SeasonType, career.Seasons)
+	}
+	if career.FirstSeason == nil || *career.FirstSeason != "2022-23" || career.LastSeason == nil || *career.LastSeason != "2023-24" {
+		t.Fatalf("got seasons %v to %v, want 2022-23 to 2023-24", career.FirstSeason, career.LastSeason)
+	}
+	if career.GamesPlayed == nil || *career.GamesPlayed != 80 {
+		t.Fatalf("got %v games played, want 80", career.GamesPlayed)
+	}
+	// 20 * 70 + 30 * 10 points in 30 * 70 + 10 * 10 minutes
+	if career.Totals == nil || !near(career.Totals.Points, 1700) || !near(career.Totals.Mp, 2200) {
+		t.Fatalf("got totals %+v, want 1700 points in 2200 minutes", career.Totals)
+	}
+	// (20 * 30 + 30 * 10) / 40
+	if !near(career.Averages.Points, 22.5) || !near(career.Averages.Mp, 20) {
+		t.Fatalf("got averages %+v, want 22.5 points in 20 minutes", career.Averages)
+	}
+
+	highs := map[string]*model.StatHigh{}
+	for _, high := range career.CareerHighs {
+		highs[high.Stat] = high
+	}
+	if len(career.CareerHighs) != 8 {
+		t.Fatalf("got %d career highs, want one per stat", len(career.CareerHighs))
+	}
+	if high := highs["points"]; high == nil || high.Value != 30 || high.Season != "2023-24" {
+		t.Fatalf("got points high %+v, want 30 in 2023-24", high)
+	}
+	// Ties go to the latest season
+	if high := highs["rebounds"]; high == nil || high.Value != 5 || high.Season != "2023-24" {
+		t.Fatalf("got rebounds high %+v, want 5 in 2023-24", high)
+	}
+
+	seasonHighs := map[string]*model.StatHigh{}
+	for _, high := range career.SeasonHighs {
+		seasonHighs[high.Stat] = high
+	}
+	if high := seasonHighs["points"]; high == nil || high.Value != 40 || high.SeasonType != playoffs {
+		t.Fatalf("got season points high %+v, want 40 in the playoffs", high)
+	}
+
+	career, err = s.Players.GetPlayerCareer(ctx, s.OrgID, player, model.SeasonTypePlayIn)
+	mustNot(t, err)
+	if career.Seasons != 0 || career.FirstSeason != nil || len(career.CareerHighs) != 0 || career.Totals == nil || career.Totals.Points != 0 {
+		t.Fatalf("got play-in career %+v, want an empty one", career)
+	}
+
+	// Without games played per game values cannot be added up
+	other, err := s.Players.CreatePlayer(ctx, s.OrgID, "", newPlayer("Bo", model.LineupPositionPg))
+	mustNot(t, err)
+	career, err = s.Players.GetPlayerCareer(ctx, s.OrgID, other, model.SeasonTypeRegular)
+	mustNot(t, err)
+	if career.Totals != nil || career.GamesPlayed != nil || !near(career.Averages.Points, 20) {
+		t.Fatalf("got career %+v, want averages without totals", career)
+	}
+}
+
+func checkPlayerHistory(t *testing.T, s Setup) {
+	actorId, err := s.Users.CreateUser(ctx, model.InputUser{Username: unique("editor"), Password: "correct horse"}, "")
+	mustNot(t, err)
+	actor, err := s.Users.GetUserById(ctx, actorId)
+	mustNot(t, err)
+
+	created, err := s.Players.CreatePlayer(ctx, s.OrgID, actorId, newPlayer("Ada", model.LineupPositionPg))
+	mustNot(t, err)
+	updated, err := s.Players.UpdatePlayer(ctx, s.OrgID, actorId, model.InputUpdatePlayer{
+		Name:  stringPointer("Ada"),
+		Stats: &model.InputUpdateStats{Points: floatPointer(21)},
+	}, created.Version)
+	mustNot(t, err)
+
+	// An update that changes nothing is not recorded
+	updated, err = s.Players.UpdatePlayer(ctx, s.OrgID, actorId, model.InputUpdatePlayer{Name: stringPointer("Ada")}, updated.Version)
+	mustNot(t, err)
+	_, err = s.Players.DeletePlayer(ctx, s.OrgID, "", "Ada", updated.Version)
+	mustNot(t, err)
+
+	_, err = s.Players.CreatePlayer(ctx, s.OtherOrgID, actorId, newPlayer("Ada", model.LineupPositionPg))
+	mustNot(t, err)
+
+	entries, hasNext, err := s.Players.GetPlayerHistory(ctx, s.OrgID, created.ID, 2, 0)
+	mustNot(t, err)
+	if len(entries) != 2 || !hasNext {
+		t.Fatalf("got %d entries and more %v, want 2 and more", len(entries), hasNext)
+	}
+	if entries[0].Action != model.AuditActionDelete || entries[1].Action != model.AuditActionUpdate {
+		t.Fatalf("got actions %s and %s, want delete and update", entries[0].Action, entries[1].Action)
+	}
+	if entries[0].ActorID != nil {
+		t.Fatalf("got actor %v for a change without one", *entries[0].ActorID)
+	}
+	if entries[1].ActorID == nil || *entries[1].ActorID != actorId || entries[1].ActorUsername == nil || *entries[1].ActorUsername != actor.Username {
+		t.Fatalf("got actor %v %v, want %s", entries[1].ActorID, entries[1].ActorUsername, actor.Username)
+	}
+	if len(entries[1].Changes) != 1 || entries[1].Changes[0].Field != "stats.points" ||
+		*entries[1].Changes[0].Before != "20" || *entries[1].Changes[0].After != "21" {
+		t.Fatalf("got changes %+v, want points from 20 to 21", entries[1].Changes)
+	}
+	if entries[0].PlayerID != created.ID || entries[0].PlayerName != "Ada" || entries[0].OrganizationID != s.OrgID {
+		t.Fatalf("unexpected entry %+v", entries[0])
+	}
+
+	before, err := strconv.ParseInt(entries[1].ID, 10, 64)
+	mustNot(t, err)
+	entries, hasNext, err = s.Players.GetPlayerHistory(ctx, s.OrgID, created.ID, 2, before)
+	mustNot(t, err)
+	if len(entries) != 1 || hasNext || entries[0].Action != model.AuditActionCreate {
+		t.Fatalf("got %d entries and more %v, want the create entry", len(entries), hasNext)
+	}
+	if entries[0].Changes[0].Before != nil {
+		t.Fatalf("got before value %v for a created player", *entries[0].Changes[0].Before)
+	}
+
+	entries, _, err = s.Players.GetAuditLog(ctx, s.OrgID, 10, 0)
+	mustNot(t, err)
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries in the organization's audit log, want 3", len(entries))
+	}
+	for _, entry := range entries {
+		if entry.OrganizationID != s.OrgID {
+			t.Fatalf("got an entry of organization %s", entry.OrganizationID)
+		}
+	}
+
+	entries, _, err = s.Players.GetAuditLog(ctx, s.OtherOrgID, 10, 0)
+	mustNot(t, err)
+	if len(entries) != 1 || entries[0].Action != model.AuditActionCreate {
+		t.Fatalf("got %d entries in the other organization's audit log, want its create entry", len(entries))
+	}
+}
@@ -0,0 +1,105 @@
+// Package repotest is the conformance suite for the repositories in package
+// repository. It is a plain package so the tests of every implementation can
+// run the same checks:
+//
+//	func TestStore(t *testing.T) {
+//		repotest.Run(t, func(t *testing.T) repotest.Setup {
+//			store := memory.New()
+//			return repotest.Setup{
+//				Players: store, Users: store, Auth: store, Orgs: store, Settings: store,
+//				OrgID: "a", OtherOrgID: "b",
+//			}
+//		})
+//	}
+//
+// Against Postgres, as TestPostgres in package repository does, connect and
+// migrate once and create the two organizations of every setup with
+// db.CreateOrganization. Users, organizations and settings are shared by
+// every setup there, so the suite gives each user and organization it creates
+// a name of its own and puts the settings back the way it found them.
+package repotest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/mattmazer1/graphql-api/repository"
+)
+
+// Setup is what one check of the suite runs against: repositories and two
+// organizations without players, to check they are kept apart.
+type Setup struct {
+	Players    repository.PlayerRepository
+	Users      repository.UserRepository
+	Auth       repository.AuthRepository
+	Orgs       repository.OrganizationRepository
+	Settings   repository.SettingsRepository
+	OrgID      string
+	OtherOrgID string
+}
+
+type check struct {
+	name string
+	run  func(t *testing.T, s Setup)
+}
+
+// Run runs every check of the suite as a subtest, each with a setup of its
+// own.
+func Run(t *testing.T, setup func(t *testing.T) Setup) {
+	checks := []check{}
+	for _, group := range [][]check{playerChecks, userChecks, authChecks, organizationChecks} {
+		checks = append(checks, group...)
+	}
+
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.run(t, setup(t))
+		})
+	}
+}
+
+var ctx = context.Background()
+
+// unique returns name with a random suffix.
+func unique(name string) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return name + "_" + hex.EncodeToString(b)
+}
+
+func mustNot(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func mustBe(t *testing.T, err error, target error) {
+	t.Helper()
+	if !errors.Is(err, target) {
+		t.Fatalf("got error %v, want %v", err, target)
+	}
+}
+
+func near(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func intPointer(n int) *int {
+	return &n
+}
+
+func floatPointer(f float64) *float64 {
+	return &f
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
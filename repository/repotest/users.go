@@ -0,0 +1,414 @@
+package repotest
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/mattmazer1/graphql-api/database"
+	"github.com/mattmazer1/graphql-api/graph/model"
+	"github.com/mattmazer1/graphql-api/utils"
+)
+
+var userChecks = []check{
+	{"CreateAndGetUser", checkCreateAndGetUser},
+	{"Authenticate", checkAuthenticate},
+	{"UpdateUser", checkUpdateUser},
+	{"GetUsers", checkGetUsers},
+	{"ChangeUser", checkChangeUser},
+	{"DeleteUser", checkDeleteUser},
+	{"Invitation", checkInvitation},
+	{"PasswordReset", checkPasswordReset},
+	{"EraseAndExportUser", checkEraseAndExportUser},
+}
+
+func createUser(t *testing.T, s Setup, username string) string {
+	t.Helper()
+
+	id, err := s.Users.CreateUser(ctx, model.InputUser{Username: username, Password: "correct horse"}, "")
+	mustNot(t, err)
+	return id
+}
+
+func checkCreateAndGetUser(t *testing.T, s Setup) {
+	username := unique("ada")
+	id, err := s.Users.CreateUser(ctx, model.InputUser{
+		Username: username,
+		Password: "correct horse",
+		Email:    stringPointer("ada@example.com"),
+	}, "")
+	mustNot(t, err)
+	if id == "" {
+		t.Fatal("got no id for a created user")
+	}
+
+	user, err := s.Users.GetUser(ctx, username)
+	mustNot(t, err)
+	if user == nil || user.ID != id || user.Username != username || user.Role != model.RoleUser {
+		t.Fatalf("got user %+v, want %s with the user role", user, username)
+	}
+	if user.Email == nil || *user.Email != "ada@example.com" || *user.Disabled || *user.TwoFactorEnabled || user.CreatedAt == "" {
+		t.Fatalf("unexpected user %+v", user)
+	}
+
+	byId, err := s.Users.GetUserById(ctx, id)
+	mustNot(t, err)
+	if byId == nil || byId.Username != username {
+		t.Fatalf("got user %+v by id, want %s", byId, username)
+	}
+
+	gotId, err := s.Users.GetUserId(ctx, username)
+	mustNot(t, err)
+	if gotId != id {
+		t.Fatalf("got id %s, want %s", gotId, id)
+	}
+
+	_, err = s.Users.CreateUser(ctx, model.InputUser{Username: username, Password: "other password"}, "")
+	mustBe(t, err, db.ErrUsernameTaken)
+
+	_, err = s.Users.CreateUser(ctx, model.InputUser{Username: unique("bo"), Password: "correct horse"}, "unknown")
+	mustBe(t, err, db.ErrInvalidInvitation)
+
+	missing := unique("nobody")
+	user, err = s.Users.GetUser(ctx, missing)
+	mustNot(t, err)
+	if user != nil {
+		t.Fatalf("got user %+v, want none", user)
+	}
+	gotId, err = s.Users.GetUserId(ctx, missing)
+	mustNot(t, err)
+	if gotId != "" {
+		t.Fatalf("got id %s, want none", gotId)
+	}
+}
+
+func checkAuthenticate(t *testing.T, s Setup) {
+	username := unique("ada")
+	createUser(t, s, username)
+
+	for _, c := range []struct {
+		username string
+		password string
+		want     bool
+	}{
+		{username, "correct horse", true},
+		{username, "wrong horse", false},
+		{unique("nobody"), "correct horse", false},
+	} {
+		got, err := s.Users.Authenticate(ctx, model.InputUser{Username: c.username, Password: c.password})
+		mustNot(t, err)
+		if got != c.want {
+			t.Fatalf("got %v for %s with %q, want %v", got, c.username, c.password, c.want)
+		}
+	}
+}
+
+func checkUpdateUser(t *testing.T, s Setup) {
+	username := unique("ada")
+	id := createUser(t, s, username)
+	taken := unique("bo")
+	createUser(t, s, taken)
+
+	err := s.Users.UpdateUsername(ctx, model.UpdateUsername{ID: &id, OldUsername: username, NewUsername: taken})
+	mustBe(t, err, db.ErrUsernameTaken)
+
+	renamed := unique("cy")
+	err = s.Users.UpdateUsername(ctx, model.UpdateUsername{ID: &id, OldUsername: username, NewUsername: renamed})
+	mustNot(t, err)
+
+	user, err := s.Users.GetUser(ctx, username)
+	mustNot(t, err)
+	if user != nil {
+		t.Fatalf("the old username still finds %+v", user)
+	}
+	user, err = s.Users.GetUser(ctx, renamed)
+	mustNot(t, err)
+	if user == nil || user.ID != id {
+		t.Fatalf("got user %+v, want %s", user, id)
+	}
+
+	// Timestamps may come from another clock, so allow for some drift
+	before := time.Now().Add(-time.Minute)
+
+	err = s.Users.UpdatePassword(ctx, model.UpdatePassword{ID: &id, Username: renamed, NewPassword: "battery staple"})
+	mustNot(t, err)
+
+	status, err := s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if status.TokensValidAfter.Before(before) {
+		t.Fatalf("got status %+v, want the sessions ended by the new password", status)
+	}
+
+	correct, err := s.Users.Authenticate(ctx, model.InputUser{Username: renamed, Password: "battery staple"})
+	mustNot(t, err)
+	if !correct {
+		t.Fatal("the new password is not accepted")
+	}
+	correct, err = s.Users.Authenticate(ctx, model.InputUser{Username: renamed, Password: "correct horse"})
+	mustNot(t, err)
+	if correct {
+		t.Fatal("the old password is still accepted")
+	}
+}
+
+func checkGetUsers(t *testing.T, s Setup) {
+	// Users may be shared with other setups, so the filter keeps to the ones
+	// made here
+	prefix := unique("team")
+	for _, name := range []string{"cy", "ada", "bo"} {
+		createUser(t, s, prefix+"_"+name)
+	}
+
+	filter := &model.UserFilter{Username: &prefix}
+	users, hasNext, err := s.Users.GetUsers(ctx, filter, 2, "")
+	mustNot(t, err)
+	if len(users) != 2 || !hasNext || users[0].Username != prefix+"_ada" || users[1].Username != prefix+"_bo" {
+		t.Fatalf("got %d users and more %v, want ada and bo and more", len(users), hasNext)
+	}
+
+	users, hasNext, err = s.Users.GetUsers(ctx, filter, 2, users[1].Username)
+	mustNot(t, err)
+	if len(users) != 1 || hasNext || users[0].Username != prefix+"_cy" {
+		t.Fatalf("got %d users and more %v, want only cy", len(users), hasNext)
+	}
+
+	disabled := true
+	users, _, err = s.Users.GetUsers(ctx, &model.UserFilter{Username: &prefix, Disabled: &disabled}, 10, "")
+	mustNot(t, err)
+	if len(users) != 0 {
+		t.Fatalf("got %d disabled users, want none", len(users))
+	}
+}
+
+func checkChangeUser(t *testing.T, s Setup) {
+	username := unique("ada")
+	id := createUser(t, s, username)
+
+	status, err := s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if !status.Exists || status.Disabled {
+		t.Fatalf("got status %+v, want an enabled user", status)
+	}
+	// Timestamps may come from another clock, so allow for some drift
+	before := time.Now().Add(-time.Minute)
+
+	changedId, err := s.Users.SetUserDisabled(ctx, username, true, "")
+	mustNot(t, err)
+	if changedId != id {
+		t.Fatalf("got id %s, want %s", changedId, id)
+	}
+	status, err = s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if !status.Disabled || status.TokensValidAfter.Before(before) {
+		t.Fatalf("got status %+v, want a disabled user without sessions", status)
+	}
+
+	_, err = s.Users.SetUserDisabled(ctx, username, false, "")
+	mustNot(t, err)
+	status, err = s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if status.Disabled {
+		t.Fatalf("got status %+v, want an enabled user", status)
+	}
+
+	changedId, err = s.Users.SetUserRole(ctx, username, model.RoleEditor, "")
+	mustNot(t, err)
+	if changedId != id {
+		t.Fatalf("got id %s, want %s", changedId, id)
+	}
+	user, err := s.Users.GetUserById(ctx, id)
+	mustNot(t, err)
+	if user.Role != model.RoleEditor {
+		t.Fatalf("got role %s, want editor", user.Role)
+	}
+
+	changedId, err = s.Users.ForceLogout(ctx, username, "")
+	mustNot(t, err)
+	if changedId != id {
+		t.Fatalf("got id %s, want %s", changedId, id)
+	}
+
+	missing := unique("nobody")
+	for name, change := range map[string]func() (string, error){
+		"SetUserDisabled": func() (string, error) { return s.Users.SetUserDisabled(ctx, missing, true, "") },
+		"SetUserRole":     func() (string, error) { return s.Users.SetUserRole(ctx, missing, model.RoleAdmin, "") },
+		"ForceLogout":     func() (string, error) { return s.Users.ForceLogout(ctx, missing, "") },
+	} {
+		changedId, err := change()
+		mustNot(t, err)
+		if changedId != "" {
+			t.Fatalf("%s got id %s for a missing user", name, changedId)
+		}
+	}
+}
+
+func checkDeleteUser(t *testing.T, s Setup) {
+	username := unique("ada")
+	id := createUser(t, s, username)
+
+	err := s.Users.DeleteUser(ctx, username)
+	mustNot(t, err)
+
+	user, err := s.Users.GetUser(ctx, username)
+	mustNot(t, err)
+	if user != nil {
+		t.Fatalf("got deleted user %+v", user)
+	}
+
+	status, err := s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if status.Exists {
+		t.Fatalf("got status %+v, want no user", status)
+	}
+
+	// The name can be taken again
+	createUser(t, s, username)
+}
+
+func checkInvitation(t *testing.T, s Setup) {
+	creator := createUser(t, s, unique("ada"))
+
+	code := unique("invitation")
+	mustNot(t, s.Users.CreateInvitation(ctx, code, model.RoleEditor, s.OrgID, model.OrgRoleEditor, creator, time.Now().Add(time.Hour)))
+
+	valid, err := s.Users.InvitationValid(ctx, code)
+	mustNot(t, err)
+	if !valid {
+		t.Fatal("a new invitation is not valid")
+	}
+
+	id, err := s.Users.CreateUser(ctx, model.InputUser{Username: unique("bo"), Password: "correct horse"}, code)
+	mustNot(t, err)
+	user, err := s.Users.GetUserById(ctx, id)
+	mustNot(t, err)
+	if user.Role != model.RoleEditor {
+		t.Fatalf("got role %s, want the invitation's editor role", user.Role)
+	}
+	orgRole, err := s.Orgs.GetMembership(ctx, id, s.OrgID)
+	mustNot(t, err)
+	if orgRole != model.OrgRoleEditor {
+		t.Fatalf("got organization role %q, want the invitation's editor role", orgRole)
+	}
+
+	valid, err = s.Users.InvitationValid(ctx, code)
+	mustNot(t, err)
+	if valid {
+		t.Fatal("a used invitation is still valid")
+	}
+	_, err = s.Users.CreateUser(ctx, model.InputUser{Username: unique("cy"), Password: "correct horse"}, code)
+	mustBe(t, err, db.ErrInvalidInvitation)
+
+	expired := unique("invitation")
+	mustNot(t, s.Users.CreateInvitation(ctx, expired, model.RoleUser, s.OrgID, model.OrgRoleViewer, creator, time.Now().Add(-time.Minute)))
+	valid, err = s.Users.InvitationValid(ctx, expired)
+	mustNot(t, err)
+	if valid {
+		t.Fatal("an expired invitation is valid")
+	}
+}
+
+func checkPasswordReset(t *testing.T, s Setup) {
+	username := unique("ada")
+	id := createUser(t, s, username)
+
+	// A new reset replaces the one before
+	replaced := unique("reset")
+	mustNot(t, s.Users.CreatePasswordReset(ctx, id, replaced, time.Now().Add(time.Hour)))
+	token := unique("reset")
+	mustNot(t, s.Users.CreatePasswordReset(ctx, id, token, time.Now().Add(time.Hour)))
+
+	valid, err := s.Users.PasswordResetValid(ctx, replaced)
+	mustNot(t, err)
+	if valid {
+		t.Fatal("a replaced reset is still valid")
+	}
+	valid, err = s.Users.PasswordResetValid(ctx, token)
+	mustNot(t, err)
+	if !valid {
+		t.Fatal("a new reset is not valid")
+	}
+
+	hashedPassword, err := utils.HashPasswordContext(ctx, "battery staple")
+	mustNot(t, err)
+
+	// Timestamps may come from another clock, so allow for some drift
+	before := time.Now().Add(-time.Minute)
+
+	resetId, resetUsername, err := s.Users.ResetPassword(ctx, token, hashedPassword)
+	mustNot(t, err)
+	if resetId != id || resetUsername != username {
+		t.Fatalf("got %s %s, want %s %s", resetId, resetUsername, id, username)
+	}
+
+	status, err := s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if status.TokensValidAfter.Before(before) {
+		t.Fatalf("got status %+v, want the sessions ended by the reset", status)
+	}
+	correct, err := s.Users.Authenticate(ctx, model.InputUser{Username: username, Password: "battery staple"})
+	mustNot(t, err)
+	if !correct {
+		t.Fatal("the reset password is not accepted")
+	}
+
+	resetId, _, err = s.Users.ResetPassword(ctx, token, hashedPassword)
+	mustNot(t, err)
+	if resetId != "" {
+		t.Fatal("a reset token was used twice")
+	}
+}
+
+func checkEraseAndExportUser(t *testing.T, s Setup) {
+	admin := createUser(t, s, unique("ada"))
+	code := unique("invitation")
+	mustNot(t, s.Users.CreateInvitation(ctx, code, model.RoleUser, s.OrgID, model.OrgRoleEditor, admin, time.Now().Add(time.Hour)))
+
+	username := unique("bo")
+	id, err := s.Users.CreateUser(ctx, model.InputUser{Username: username, Password: "correct horse"}, code)
+	mustNot(t, err)
+
+	mustNot(t, s.Auth.InsertAuthAudit(ctx, db.AuditPasswordReset, username, "192.0.2.1", ""))
+	_, err = s.Users.SetUserDisabled(ctx, username, false, admin)
+	mustNot(t, err)
+	_, err = s.Players.CreatePlayer(ctx, s.OrgID, id, newPlayer("Ada", model.LineupPositionPg))
+	mustNot(t, err)
+
+	export, err := s.Users.ExportUserData(ctx, id)
+	mustNot(t, err)
+	if export == nil || export.Account.ID != id {
+		t.Fatalf("got export %+v, want one of %s", export, username)
+	}
+	if len(export.Invitations) != 1 || export.Invitations[0].Created || export.Invitations[0].UsedAt == nil {
+		t.Fatalf("got invitations %+v, want the one the user used", export.Invitations)
+	}
+	if len(export.SecurityEvents) != 2 || export.SecurityEvents[0].Ip == nil || *export.SecurityEvents[0].Ip != "192.0.2.1" {
+		t.Fatalf("got security events %+v, want the reset from 192.0.2.1 and the admin change", export.SecurityEvents)
+	}
+	if len(export.PlayerChanges) != 1 || export.PlayerChanges[0].Action != model.AuditActionCreate {
+		t.Fatalf("got player changes %+v, want the created player", export.PlayerChanges)
+	}
+
+	mustNot(t, s.Users.EraseUser(ctx, id))
+
+	export, err = s.Users.ExportUserData(ctx, id)
+	mustNot(t, err)
+	if export != nil {
+		t.Fatalf("got export %+v of an erased user", export)
+	}
+	status, err := s.Users.GetUserStatus(ctx, id)
+	mustNot(t, err)
+	if status.Exists {
+		t.Fatal("an erased user still exists")
+	}
+
+	// What the admin did to the erased user stays in their export, under
+	// the pseudonym
+	export, err = s.Users.ExportUserData(ctx, admin)
+	mustNot(t, err)
+	authored := export.AuthoredChanges
+	if len(authored) != 1 || authored[0].Username == nil || *authored[0].Username != db.ErasedUsername(id) {
+		t.Fatalf("got authored changes %+v, want one about %s", authored, db.ErasedUsername(id))
+	}
+	if len(export.Invitations) != 1 || !export.Invitations[0].Created {
+		t.Fatalf("got invitations %+v, want the one the admin created", export.Invitations)
+	}
+}
@@ -1,19 +1,28 @@
 package main
 
 import (
+	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
 	"github.com/99designs/gqlgen/graphql/handler/transport"
 	"github.com/99designs/gqlgen/graphql/playground"
 	"github.com/go-chi/chi/v5"
 	_ "github.com/lib/pq"
 	db "github.com/mattmazer1/graphql-api/database"
 	"github.com/mattmazer1/graphql-api/graph"
+	"github.com/mattmazer1/graphql-api/mailer"
 	"github.com/mattmazer1/graphql-api/middleware"
 	nat "github.com/mattmazer1/graphql-api/nats"
+	"github.com/mattmazer1/graphql-api/playercsv"
+	"github.com/mattmazer1/graphql-api/repository"
+	"github.com/mattmazer1/graphql-api/utils"
 	"github.com/rs/cors"
 )
 
@@ -28,6 +37,7 @@ func main() {
 		Debug:            true,
 	}).Handler)
 
+	router.Use(middleware.ClientIPMiddleware())
 	router.Use(middleware.AuthMiddleware())
 
 	port := os.Getenv("PORT")
@@ -35,18 +45,78 @@ func main() {
 		port = defaultPort
 	}
 
-	db.InitDB()
-	defer db.CloseDB()
+	conn, err := db.Connect()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	db.StartPlayerPurge(ctx, conn)
 
 	nat.ConnectNat()
 	defer nat.CloseNat()
 
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: &graph.Resolver{}}))
+	utils.InitHashPool()
+	utils.InitKeys()
+
+	repo := repository.NewPostgres(conn)
+	middleware.SetStatusSource(repo)
+
+	srv := handler.New(graph.NewExecutableSchema(graph.Config{
+		Resolvers: &graph.Resolver{
+			Mailer:       mailer.NewMailer(),
+			PlayerRepo:   repo,
+			UserRepo:     repo,
+			AuthRepo:     repo,
+			OrgRepo:      repo,
+			SettingsRepo: repo,
+		},
+		Directives: graph.DirectiveRoot{
+			Private: graph.Private,
+		},
+	}))
 
-	srv.AddTransport(&transport.Websocket{})
+	// Same setup as handler.NewDefaultServer, which registers its own
+	// websocket transport first and would shadow ours. Both the graphql-ws
+	// and graphql-transport-ws subprotocols are negotiated, each with its own
+	// keep alive.
+	srv.AddTransport(&transport.Websocket{
+		InitFunc:              middleware.WebsocketInit,
+		KeepAlivePingInterval: 10 * time.Second,
+		PingPongInterval:      10 * time.Second,
+	})
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(transport.GET{})
+	srv.AddTransport(transport.POST{})
+	srv.AddTransport(transport.MultipartForm{})
+
+	srv.SetQueryCache(lru.New(1000))
+	srv.SetErrorPresenter(graph.ErrorPresenter)
+
+	srv.Use(extension.Introspection{})
+	srv.Use(extension.AutomaticPersistedQuery{
+		Cache: lru.New(100),
+	})
 
 	router.Handle("/", playground.Handler("GraphQL playground", "/query"))
 	router.Handle("/query", srv)
+	router.Get("/.well-known/jwks.json", utils.JWKSHandler)
+	router.Get("/export/players.csv", playercsv.ExportHandler(repo))
+
+	// The metrics are only served on an internal address, never next to the
+	// api, so they are not exposed to its clients
+	if debugAddr := os.Getenv("DEBUG_ADDR"); debugAddr != "" {
+		debug := http.NewServeMux()
+		debug.Handle("/debug/vars", expvar.Handler())
+
+		go func() {
+			log.Printf("serving metrics on %s/debug/vars", debugAddr)
+			log.Fatal(http.ListenAndServe(debugAddr, debug))
+		}()
+	}
 
 	log.Printf("connect to http://localhost:%s/ for GraphQL playground", port)
 	log.Fatal(http.ListenAndServe(":"+port, router))
@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"context"
+	"expvar"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/apperr"
+)
+
+// ErrHashOverloaded is returned when the password hashing queue is full.
+// Callers should surface it so clients back off instead of piling on.
+var ErrHashOverloaded = apperr.New(apperr.Unavailable, "server is busy, try again later")
+
+type hashJob struct {
+	ctx      context.Context
+	run      func()
+	done     chan struct{}
+	queuedAt time.Time
+}
+
+// hashPool runs password hashing and verification on a fixed number of
+// workers so a burst of logins cannot take every CPU from the server.
+type hashPool struct {
+	jobs    chan *hashJob
+	workers int
+
+	completed   int64
+	rejected    int64
+	canceled    int64
+	waitNanos   int64
+	runNanos    int64
+	maxWaitNano int64
+}
+
+var (
+	pool     *hashPool
+	poolOnce sync.Once
+)
+
+// InitHashPool starts the hashing pool and publishes its metrics. It reads
+// the password policy too, so a bad setting is reported at startup.
+func InitHashPool() {
+	GetPasswordPolicy()
+	getHashPool()
+}
+
+// getHashPool starts the pool once. HASH_WORKERS sets the number of workers,
+// one per CPU by default, and HASH_QUEUE how many jobs may wait for a worker.
+func getHashPool() *hashPool {
+	poolOnce.Do(func() {
+		workers := runtime.NumCPU()
+		if v, err := strconv.Atoi(os.Getenv("HASH_WORKERS")); err == nil && v > 0 {
+			workers = v
+		}
+
+		queue := workers * 4
+		if v, err := strconv.Atoi(os.Getenv("HASH_QUEUE")); err == nil && v >= 0 {
+			queue = v
+		}
+
+		pool = &hashPool{jobs: make(chan *hashJob, queue), workers: workers}
+		for i := 0; i < workers; i++ {
+			go pool.work()
+		}
+
+		expvar.Publish("password_hashing", expvar.Func(pool.metrics))
+	})
+
+	return pool
+}
+
+func (p *hashPool) work() {
+	for job := range p.jobs {
+		// The caller may have given up while the job was queued
+		if job.ctx.Err() != nil {
+			atomic.AddInt64(&p.canceled, 1)
+			close(job.done)
+			continue
+		}
+
+		wait := time.Since(job.queuedAt)
+		atomic.AddInt64(&p.waitNanos, int64(wait))
+		for {
+			max := atomic.LoadInt64(&p.maxWaitNano)
+			if int64(wait) <= max || atomic.CompareAndSwapInt64(&p.maxWaitNano, max, int64(wait)) {
+				break
+			}
+		}
+
+		start := time.Now()
+		job.run()
+		atomic.AddInt64(&p.runNanos, int64(time.Since(start)))
+		atomic.AddInt64(&p.completed, 1)
+
+		close(job.done)
+	}
+}
+
+// do queues run and waits for it. It fails fast with ErrHashOverloaded when
+// the queue is full and returns early if ctx is done.
+func (p *hashPool) do(ctx context.Context, run func()) error {
+	job := &hashJob{ctx: ctx, run: run, done: make(chan struct{}), queuedAt: time.Now()}
+
+	select {
+	case p.jobs <- job:
+	default:
+		atomic.AddInt64(&p.rejected, 1)
+		return ErrHashOverloaded
+	}
+
+	select {
+	case <-job.done:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *hashPool) metrics() interface{} {
+	completed := atomic.LoadInt64(&p.completed)
+
+	var avgWait, avgRun float64
+	if completed > 0 {
+		avgWait = time.Duration(atomic.LoadInt64(&p.waitNanos) / completed).Seconds()
+		avgRun = time.Duration(atomic.LoadInt64(&p.runNanos) / completed).Seconds()
+	}
+
+	return map[string]interface{}{
+		"workers":          p.workers,
+		"queue_capacity":   cap(p.jobs),
+		"queue_depth":      len(p.jobs),
+		"completed":        completed,
+		"rejected":         atomic.LoadInt64(&p.rejected),
+		"canceled":         atomic.LoadInt64(&p.canceled),
+		"avg_wait_seconds": avgWait,
+		"max_wait_seconds": time.Duration(atomic.LoadInt64(&p.maxWaitNano)).Seconds(),
+		"avg_run_seconds":  avgRun,
+	}
+}
+
+// HashPasswordContext hashes a password on the hashing pool.
+func HashPasswordContext(ctx context.Context, password string) (string, error) {
+	var hash string
+	var hashErr error
+
+	err := getHashPool().do(ctx, func() {
+		hash, hashErr = HashPassword(password)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return hash, hashErr
+}
+
+// CheckPasswordHashContext verifies a password on the hashing pool.
+func CheckPasswordHashContext(ctx context.Context, password string, hash string) (bool, error) {
+	var match bool
+
+	err := getHashPool().do(ctx, func() {
+		match = CheckPasswordHash(password, hash)
+	})
+	if err != nil {
+		return false, err
+	}
+
+	return match, nil
+}
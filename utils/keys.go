@@ -0,0 +1,278 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// tokenKey is a single key that tokens are signed or verified with. The
+// signing method is fixed per key so a token can never pick its own algorithm.
+type tokenKey struct {
+	kid     string
+	method  jwt.SigningMethod
+	private interface{}
+	public  interface{}
+}
+
+// keySet holds the key new tokens are signed with and every key that is still
+// accepted for verification, indexed by kid.
+type keySet struct {
+	signing *tokenKey
+	verify  map[string]*tokenKey
+	methods []string
+}
+
+var (
+	keys   *keySet
+	keysMu sync.Mutex
+)
+
+// InitKeys loads the token keys and stops the server when they cannot be
+// loaded, so a bad key setup shows at startup rather than on the first login.
+func InitKeys() {
+	if _, err := getKeySet(); err != nil {
+		log.Fatalf("could not load jwt keys: %v", err)
+	}
+}
+
+// getKeySet loads the token keys on first use. When JWT_KEYS_DIR is set every
+// *.pem file in it is loaded as a key named after the file, and
+// JWT_SIGNING_KID picks the private key used for signing. Without
+// JWT_KEYS_DIR tokens fall back to HS256 with JWT_SECRET. Only a loaded set is
+// kept, a failed load is tried again on the next call.
+func getKeySet() (*keySet, error) {
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	if keys != nil {
+		return keys, nil
+	}
+
+	var set *keySet
+	var err error
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir == "" {
+		set, err = loadSecretKeySet()
+	} else {
+		set, err = loadKeySet(dir, os.Getenv("JWT_SIGNING_KID"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys = set
+	return keys, nil
+}
+
+func loadSecretKeySet() (*keySet, error) {
+	secret, err := GetSecretKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key := &tokenKey{method: jwt.SigningMethodHS256, private: secret, public: secret}
+
+	return &keySet{
+		signing: key,
+		verify:  map[string]*tokenKey{"": key},
+		methods: []string{key.method.Alg()},
+	}, nil
+}
+
+func loadKeySet(dir string, signingKid string) (*keySet, error) {
+	if signingKid == "" {
+		return nil, fmt.Errorf("JWT_SIGNING_KID must be set when JWT_KEYS_DIR is used")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not list jwt keys: %w", err)
+	}
+
+	set := &keySet{verify: map[string]*tokenKey{}}
+	methods := map[string]bool{}
+
+	for _, file := range files {
+		kid := strings.TrimSuffix(filepath.Base(file), ".pem")
+
+		key, err := loadKeyFile(kid, file)
+		if err != nil {
+			return nil, err
+		}
+
+		set.verify[kid] = key
+		methods[key.method.Alg()] = true
+
+		if kid == signingKid {
+			if key.private == nil {
+				return nil, fmt.Errorf("signing key %s has no private key", kid)
+			}
+			set.signing = key
+		}
+	}
+
+	if set.signing == nil {
+		return nil, fmt.Errorf("signing key %s not found in %s", signingKid, dir)
+	}
+
+	for method := range methods {
+		set.methods = append(set.methods, method)
+	}
+	sort.Strings(set.methods)
+
+	return set, nil
+}
+
+// loadKeyFile reads a PEM encoded RSA or Ed25519 key. Private keys can sign
+// and verify, public keys are only accepted for verification so that retired
+// keys can stay around until the tokens signed with them have expired.
+func loadKeyFile(kid string, file string) (*tokenKey, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read jwt key %s: %w", kid, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("could not decode jwt key %s", kid)
+	}
+
+	key := &tokenKey{kid: kid}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse jwt key %s: %w", kid, err)
+		}
+		key.private = private
+		key.public = private.(crypto.Signer).Public()
+	case "RSA PRIVATE KEY":
+		private, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse jwt key %s: %w", kid, err)
+		}
+		key.private = private
+		key.public = private.Public()
+	case "PUBLIC KEY":
+		public, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse jwt key %s: %w", kid, err)
+		}
+		key.public = public
+	default:
+		return nil, fmt.Errorf("unsupported pem block %q in jwt key %s", block.Type, kid)
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return nil, fmt.Errorf("unsupported key type %T in jwt key %s", key.public, kid)
+	}
+
+	return key, nil
+}
+
+// keyFunc resolves the verification key from the kid header and rejects any
+// token whose algorithm does not match the one pinned to that key.
+func (s *keySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	key, ok := s.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+	}
+
+	return key.public, nil
+}
+
+// JSONWebKey is the public half of a token key in JWK form.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JSONWebKeySet is the document served from /.well-known/jwks.json.
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JWKS returns the public verification keys as a JSON Web Key Set. Symmetric
+// keys are never published, so the set is empty when running on JWT_SECRET.
+func JWKS() (JSONWebKeySet, error) {
+	set, err := getKeySet()
+	if err != nil {
+		return JSONWebKeySet{}, err
+	}
+
+	keySet := JSONWebKeySet{Keys: []JSONWebKey{}}
+
+	for _, key := range set.verify {
+		switch public := key.public.(type) {
+		case *rsa.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				Kty: "RSA",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			keySet.Keys = append(keySet.Keys, JSONWebKey{
+				Kty: "OKP",
+				Kid: key.kid,
+				Use: "sig",
+				Alg: key.method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		}
+	}
+
+	sort.Slice(keySet.Keys, func(i, j int) bool { return keySet.Keys[i].Kid < keySet.Keys[j].Kid })
+
+	return keySet, nil
+}
+
+// JWKSHandler serves the key set at /.well-known/jwks.json so other services
+// can verify the tokens we issue.
+func JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	keySet, err := JWKS()
+	if err != nil {
+		http.Error(w, "could not load keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(keySet)
+}
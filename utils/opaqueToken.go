@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewOpaqueToken returns a random single use token, such as a password reset
+// token or a login challenge, for the user and the hash of it that is stored.
+// The token itself is never persisted.
+func NewOpaqueToken() (string, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", fmt.Errorf("could not generate token: %w", err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	return token, HashOpaqueToken(token), nil
+}
+
+// HashOpaqueToken hashes a token for lookup. The token carries 256 bits of
+// randomness, so a fast hash is enough here.
+func HashOpaqueToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
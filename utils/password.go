@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	HashArgon2id = "argon2id"
+	HashBcrypt   = "bcrypt"
+)
+
+// PasswordPolicy decides how new password hashes are made. Hashes are self
+// describing, so hashes made under an older policy still verify and can be
+// upgraded when the user next logs in.
+type PasswordPolicy struct {
+	Algorithm string
+
+	// Argon2id parameters, memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+
+	BcryptCost int
+}
+
+var (
+	passwordPolicy     PasswordPolicy
+	passwordPolicyOnce sync.Once
+)
+
+// GetPasswordPolicy reads the policy from the environment once. It defaults to
+// Argon2id with 64 MiB of memory, 3 iterations and 2 lanes. PASSWORD_HASH
+// selects argon2id or bcrypt, ARGON2_MEMORY, ARGON2_ITERATIONS,
+// ARGON2_PARALLELISM and BCRYPT_COST tune the parameters.
+func GetPasswordPolicy() PasswordPolicy {
+	passwordPolicyOnce.Do(func() {
+		passwordPolicy = passwordPolicyFromEnv(os.Getenv)
+	})
+
+	return passwordPolicy
+}
+
+// passwordPolicyFromEnv builds the policy from the variables getenv returns.
+// A value argon2 or bcrypt cannot work with, such as 0 iterations, is logged
+// and the default is used instead, since hashing with it would panic or fail
+// for every password.
+func passwordPolicyFromEnv(getenv func(string) string) PasswordPolicy {
+	policy := PasswordPolicy{
+		Algorithm:   HashArgon2id,
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 2,
+		SaltLength:  16,
+		KeyLength:   32,
+		BcryptCost:  12,
+	}
+
+	if algorithm := getenv("PASSWORD_HASH"); algorithm == HashBcrypt {
+		policy.Algorithm = HashBcrypt
+	}
+	if v, ok := policyUint(getenv, "ARGON2_ITERATIONS", 1, math.MaxUint32); ok {
+		policy.Iterations = uint32(v)
+	}
+	if v, ok := policyUint(getenv, "ARGON2_PARALLELISM", 1, math.MaxUint8); ok {
+		policy.Parallelism = uint8(v)
+	}
+	// Argon2 needs at least 8 KiB per lane
+	if v, ok := policyUint(getenv, "ARGON2_MEMORY", 8*uint64(policy.Parallelism), math.MaxUint32); ok {
+		policy.Memory = uint32(v)
+	}
+	if v, ok := policyUint(getenv, "BCRYPT_COST", uint64(bcrypt.MinCost), uint64(bcrypt.MaxCost)); ok {
+		policy.BcryptCost = int(v)
+	}
+
+	return policy
+}
+
+// policyUint parses the variable name, reporting false when it is unset or
+// not between min and max.
+func policyUint(getenv func(string) string, name string, min uint64, max uint64) (uint64, bool) {
+	raw := getenv(name)
+	if raw == "" {
+		return 0, false
+	}
+
+	v, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil || v < min || v > max {
+		log.Printf("ignoring %s=%q, it must be between %d and %d", name, raw, min, max)
+		return 0, false
+	}
+
+	return v, true
+}
+
+// HashPassword hashes a password with the current policy.
+func HashPassword(password string) (string, error) {
+	policy := GetPasswordPolicy()
+
+	if policy.Algorithm == HashBcrypt {
+		bytes, err := bcrypt.GenerateFromPassword([]byte(password), policy.BcryptCost)
+		return string(bytes), err
+	}
+
+	salt := make([]byte, policy.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("could not generate salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, policy.Iterations, policy.Memory, policy.Parallelism, policy.KeyLength)
+
+	// PHC string format, the same one the argon2 reference implementation uses
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version,
+		policy.Memory,
+		policy.Iterations,
+		policy.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// CheckPasswordHash reports whether password matches hash, whichever
+// algorithm the hash was made with.
+func CheckPasswordHash(password, hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		params, salt, key, err := decodeArgon2id(hash)
+		if err != nil {
+			return false
+		}
+
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(key, other) == 1
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	return err == nil
+}
+
+// NeedsRehash reports whether hash was made with a different algorithm or
+// weaker parameters than the current policy.
+func NeedsRehash(hash string) bool {
+	policy := GetPasswordPolicy()
+
+	if policy.Algorithm == HashBcrypt {
+		cost, err := bcrypt.Cost([]byte(hash))
+		return err != nil || cost != policy.BcryptCost
+	}
+
+	params, salt, key, err := decodeArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != policy.Memory ||
+		params.Iterations != policy.Iterations ||
+		params.Parallelism != policy.Parallelism ||
+		uint32(len(salt)) != policy.SaltLength ||
+		uint32(len(key)) != policy.KeyLength
+}
+
+func decodeArgon2id(hash string) (PasswordPolicy, []byte, []byte, error) {
+	params := PasswordPolicy{Algorithm: HashArgon2id}
+
+	// "", "argon2id", "v=19", "m=65536,t=3,p=2", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != HashArgon2id {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, fmt.Errorf("unsupported argon2id version")
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters: %w", err)
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id salt: %w", err)
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return params, nil, nil, fmt.Errorf("invalid argon2id key: %w", err)
+	}
+
+	if len(salt) == 0 || len(key) == 0 {
+		return params, nil, nil, fmt.Errorf("invalid argon2id hash")
+	}
+
+	// argon2 panics on these rather than returning an error
+	if params.Iterations < 1 || params.Parallelism < 1 || params.Memory < 8*uint32(params.Parallelism) {
+		return params, nil, nil, fmt.Errorf("invalid argon2id parameters")
+	}
+
+	return params, salt, key, nil
+}
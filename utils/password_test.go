@@ -0,0 +1,63 @@
+package utils
+
+import "testing"
+
+func TestPasswordPolicyFromEnv(t *testing.T) {
+	defaults := passwordPolicyFromEnv(func(string) string { return "" })
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		want func(PasswordPolicy) PasswordPolicy
+	}{
+		{"defaults", nil, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{
+			"tuned",
+			map[string]string{"ARGON2_MEMORY": "19456", "ARGON2_ITERATIONS": "2", "ARGON2_PARALLELISM": "1"},
+			func(p PasswordPolicy) PasswordPolicy {
+				p.Memory, p.Iterations, p.Parallelism = 19456, 2, 1
+				return p
+			},
+		},
+		{
+			"bcrypt",
+			map[string]string{"PASSWORD_HASH": "bcrypt", "BCRYPT_COST": "10"},
+			func(p PasswordPolicy) PasswordPolicy {
+				p.Algorithm, p.BcryptCost = HashBcrypt, 10
+				return p
+			},
+		},
+		{"zero memory", map[string]string{"ARGON2_MEMORY": "0"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{"zero iterations", map[string]string{"ARGON2_ITERATIONS": "0"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{"zero parallelism", map[string]string{"ARGON2_PARALLELISM": "0"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{"parallelism out of range", map[string]string{"ARGON2_PARALLELISM": "256"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{"not a number", map[string]string{"ARGON2_ITERATIONS": "three"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{
+			"memory below 8 KiB per lane",
+			map[string]string{"ARGON2_MEMORY": "31", "ARGON2_PARALLELISM": "4"},
+			func(p PasswordPolicy) PasswordPolicy {
+				p.Parallelism = 4
+				return p
+			},
+		},
+		{
+			"memory of exactly 8 KiB per lane",
+			map[string]string{"ARGON2_MEMORY": "32", "ARGON2_PARALLELISM": "4"},
+			func(p PasswordPolicy) PasswordPolicy {
+				p.Memory, p.Parallelism = 32, 4
+				return p
+			},
+		},
+		{"bcrypt cost too low", map[string]string{"BCRYPT_COST": "3"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+		{"bcrypt cost too high", map[string]string{"BCRYPT_COST": "32"}, func(p PasswordPolicy) PasswordPolicy { return p }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := passwordPolicyFromEnv(func(name string) string { return tt.env[name] })
+			if want := tt.want(defaults); got != want {
+				t.Fatalf("got %+v, want %+v", got, want)
+			}
+		})
+	}
+}
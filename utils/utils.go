@@ -1,12 +1,13 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
 )
 
 var secretKey []byte
@@ -26,21 +27,93 @@ func GetSecretKey() ([]byte, error) {
 	return secretKey, nil
 }
 
-// GenerateToken generates a jwt token and assign a username to it's claims and return it
-func GenerateToken(username string) (string, error) {
-	token := jwt.New(jwt.SigningMethodHS256)
-	/* Create a map to store our claims */
-	claims := token.Claims.(jwt.MapClaims)
-	/* Set token claims */
-	claims["username"] = username
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
+const tokenLifetime = time.Hour * 24
+
+// Claims are the claims carried by every token we issue. The subject is the
+// user's id, so renaming a user does not invalidate their tokens, and the
+// username, role and active organization are included so requests can be
+// authenticated without a lookup.
+type Claims struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Org      string `json:"org,omitempty"`
+	OrgRole  string `json:"org_role,omitempty"`
+	// TwoFactorSetup marks a token restricted until its user sets up the
+	// two factor authentication their role requires
+	TwoFactorSetup bool `json:"tfa_setup,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// TokenUser is who a token is issued to. Org and OrgRole are empty for users
+// that do not belong to any organization.
+type TokenUser struct {
+	ID             string
+	Username       string
+	Role           string
+	Org            string
+	OrgRole        string
+	TwoFactorSetup bool
+}
+
+// GetIssuer returns the iss claim for our tokens, JWT_ISSUER or a default.
+func GetIssuer() string {
+	if issuer := os.Getenv("JWT_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "graphql-api"
+}
+
+// GetAudience returns the aud claim for our tokens, JWT_AUDIENCE or a default.
+func GetAudience() string {
+	if audience := os.Getenv("JWT_AUDIENCE"); audience != "" {
+		return audience
+	}
+	return "graphql-api"
+}
+
+func newTokenId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// GenerateToken generates a jwt token for a user, keyed on their id, and returns it
+func GenerateToken(user TokenUser) (string, error) {
+	set, err := getKeySet()
+	if err != nil {
+		return "", fmt.Errorf("could not get signing key %w", err)
+	}
 
-	secret, err := GetSecretKey()
+	jti, err := newTokenId()
 	if err != nil {
-		return "", fmt.Errorf("could not get private key %w", err)
+		return "", fmt.Errorf("could not generate token id %w", err)
+	}
+
+	now := time.Now()
+	claims := Claims{
+		Username:       user.Username,
+		Role:           user.Role,
+		Org:            user.Org,
+		OrgRole:        user.OrgRole,
+		TwoFactorSetup: user.TwoFactorSetup,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   user.ID,
+			Issuer:    GetIssuer(),
+			Audience:  jwt.ClaimStrings{GetAudience()},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(tokenLifetime)),
+			ID:        jti,
+		},
 	}
 
-	tokenString, err := token.SignedString(secret)
+	token := jwt.NewWithClaims(set.signing.method, claims)
+	if set.signing.kid != "" {
+		token.Header["kid"] = set.signing.kid
+	}
+
+	tokenString, err := token.SignedString(set.signing.private)
 	if err != nil {
 
 		return "", fmt.Errorf("error in Generating key %w", err)
@@ -49,34 +122,29 @@ func GenerateToken(username string) (string, error) {
 	return tokenString, nil
 }
 
-// ParseToken parses a jwt token and returns the username it claims
-func ParseToken(tokenStr string) (string, error) {
-	secret, err := GetSecretKey()
+// ParseToken parses and validates a jwt token and returns its claims. Only the
+// algorithms of the configured keys are accepted, and the issuer and audience
+// must be ours.
+func ParseToken(tokenStr string) (*Claims, error) {
+	set, err := getKeySet()
 	if err != nil {
-		return "", fmt.Errorf("could not get private key %w", err)
+		return nil, fmt.Errorf("could not get verification keys %w", err)
 	}
 
-	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenStr, claims, set.keyFunc,
+		jwt.WithValidMethods(set.methods),
+		jwt.WithIssuer(GetIssuer()),
+		jwt.WithAudience(GetAudience()),
+		jwt.WithIssuedAt(),
+	)
 	if err != nil {
-		return "", fmt.Errorf("could not parse jwt %w", err)
+		return nil, fmt.Errorf("could not parse jwt %w", err)
 	}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		username := claims["username"].(string)
-		return username, nil
-	} else {
-		return "", err
+	if !token.Valid || claims.Subject == "" || claims.ExpiresAt == nil {
+		return nil, fmt.Errorf("invalid jwt claims")
 	}
-}
-
-func HashPassword(password string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(password), 14)
-	return string(bytes), err
-}
 
-func CheckPasswordHash(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
+	return claims, nil
 }
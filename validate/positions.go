@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+func checkPositions(c *checker, player model.InputPlayer) {
+	primary := player.PrimaryPosition
+	switch {
+	case primary != nil && !primary.IsValid():
+		c.fail("primaryPosition", "is not a valid position")
+	case primary != nil && player.Pos != nil && *player.Pos != primary.Group():
+		c.fail("pos", "must be %s, the group of primaryPosition", primary.Group())
+	case primary == nil && player.Pos == nil:
+		c.fail("primaryPosition", "is required")
+	case primary == nil && !player.Pos.IsValid():
+		c.fail("pos", "is not a valid position")
+	}
+
+	eligible := player.EligiblePositions
+	if eligible == nil {
+		return
+	}
+	if len(eligible) == 0 {
+		c.fail("eligiblePositions", "must include at least one position")
+		return
+	}
+
+	seen := map[model.LineupPosition]bool{}
+	for _, position := range eligible {
+		if !position.IsValid() {
+			c.fail("eligiblePositions", "%q is not a valid position", position)
+		} else if seen[position] {
+			c.fail("eligiblePositions", "lists %s twice", position)
+		}
+		seen[position] = true
+	}
+
+	switch {
+	case primary != nil && primary.IsValid() && !seen[*primary]:
+		c.fail("eligiblePositions", "must include primaryPosition")
+	case primary == nil && player.Pos != nil && player.Pos.IsValid() && !inGroup(eligible, *player.Pos):
+		c.fail("eligiblePositions", "must include a position of the %s group", *player.Pos)
+	}
+}
+
+func includes(positions []model.LineupPosition, position model.LineupPosition) bool {
+	for _, p := range positions {
+		if p == position {
+			return true
+		}
+	}
+	return false
+}
+
+func inGroup(positions []model.LineupPosition, group model.Position) bool {
+	for _, p := range positions {
+		if p.Group() == group {
+			return true
+		}
+	}
+	return false
+}
+
+// Positions returns the position group and the eligible positions stored for
+// a player that passed Player: the group of the primary position, and the
+// eligible positions with the primary one first and the rest in lineup order.
+func Positions(player model.InputPlayer) (model.Position, []model.LineupPosition) {
+	primary := player.PrimaryPosition
+
+	var group model.Position
+	eligible := player.EligiblePositions
+	if primary != nil {
+		group = primary.Group()
+		if eligible == nil {
+			eligible = []model.LineupPosition{*primary}
+		}
+	} else {
+		group = *player.Pos
+		if eligible == nil {
+			eligible = group.Positions()
+		}
+	}
+
+	ordered := []model.LineupPosition{}
+	if primary != nil {
+		ordered = append(ordered, *primary)
+	}
+	for _, position := range model.AllLineupPosition {
+		if (primary == nil || position != *primary) && includes(eligible, position) {
+			ordered = append(ordered, position)
+		}
+	}
+
+	return group, ordered
+}
+
+// mergePositions applies the positions an update sets. A position group that
+// the primary position is not in replaces it, and a new primary position is
+// added to the eligible ones when the update does not replace them.
+func mergePositions(current *model.Player, update model.InputUpdatePlayer, merged *model.InputPlayer) {
+	merged.PrimaryPosition = current.PrimaryPosition
+	merged.EligiblePositions = current.EligiblePositions
+	if current.PrimaryPosition == nil {
+		pos := current.Pos
+		merged.Pos = &pos
+	}
+
+	switch {
+	case update.PrimaryPosition != nil:
+		merged.PrimaryPosition = update.PrimaryPosition
+		merged.Pos = update.Pos
+	case update.Pos != nil && (merged.PrimaryPosition == nil || merged.PrimaryPosition.Group() != *update.Pos):
+		merged.PrimaryPosition = nil
+		merged.Pos = update.Pos
+		merged.EligiblePositions = update.Pos.Positions()
+	}
+
+	primary := merged.PrimaryPosition
+	if update.EligiblePositions != nil {
+		merged.EligiblePositions = update.EligiblePositions
+	} else if primary != nil && !includes(merged.EligiblePositions, *primary) {
+		merged.EligiblePositions = append([]model.LineupPosition{*primary}, merged.EligiblePositions...)
+	}
+}
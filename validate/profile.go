@@ -0,0 +1,170 @@
+package validate
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// DateLayout is how dates such as a birth date are written
+const DateLayout = "2006-01-02"
+
+const (
+	firstDraft     = 1947
+	maxTextLength  = 100
+	oldestBirthday = "1900-01-01"
+)
+
+func checkProfile(c *checker, profile *model.InputProfile) {
+	if profile.BirthDate != nil {
+		birthDate, err := time.Parse(DateLayout, *profile.BirthDate)
+		oldest, _ := time.Parse(DateLayout, oldestBirthday)
+		switch {
+		case err != nil:
+			c.fail("profile.birthDate", "must be a date like 1998-03-24")
+		case birthDate.Before(oldest):
+			c.fail("profile.birthDate", "cannot be before %s", oldestBirthday)
+		case birthDate.After(time.Now().AddDate(-minAge, 0, 0)):
+			c.fail("profile.birthDate", "must be at least %d years ago", minAge)
+		}
+	}
+
+	if profile.Height != nil {
+		c.intRange("profile.height", *profile.Height, 150, 250)
+	}
+	if profile.Weight != nil {
+		c.intRange("profile.weight", *profile.Weight, 50, 200)
+	}
+	if profile.College != nil && len(*profile.College) > maxTextLength {
+		c.fail("profile.college", "must be at most %d characters", maxTextLength)
+	}
+	if profile.Country != nil && len(*profile.Country) > maxTextLength {
+		c.fail("profile.country", "must be at most %d characters", maxTextLength)
+	}
+
+	if profile.DraftYear != nil {
+		c.intRange("profile.draftYear", *profile.DraftYear, firstDraft, time.Now().Year())
+	}
+	if profile.DraftRound != nil {
+		c.intRange("profile.draftRound", *profile.DraftRound, 1, 10)
+		if profile.DraftYear == nil {
+			c.fail("profile.draftRound", "requires profile.draftYear")
+		}
+	}
+	if profile.DraftPick != nil {
+		c.intRange("profile.draftPick", *profile.DraftPick, 1, 100)
+		if profile.DraftYear == nil {
+			c.fail("profile.draftPick", "requires profile.draftYear")
+		}
+	}
+
+	if profile.DebutSeason != nil {
+		if message := Season(*profile.DebutSeason); message != "" {
+			c.fail("profile.debutSeason", message)
+		} else if profile.DraftYear != nil && SeasonStart(*profile.DebutSeason) < *profile.DraftYear {
+			c.fail("profile.debutSeason", "cannot start before profile.draftYear")
+		}
+	}
+
+	if profile.JerseyNumber != nil {
+		c.intRange("profile.jerseyNumber", *profile.JerseyNumber, 0, 99)
+	}
+	if profile.Handedness != nil && !profile.Handedness.IsValid() {
+		c.fail("profile.handedness", "is not a valid handedness")
+	}
+}
+
+// SeasonStart returns the year a valid season such as "2023-24" starts in.
+func SeasonStart(season string) int {
+	start, _ := strconv.Atoi(season[:4])
+	return start
+}
+
+// mergeProfile clears the fields an update clears on a stored profile, then
+// applies the fields it sets.
+func mergeProfile(current *model.Profile, update *model.InputProfile, clear []model.ProfileField) *model.InputProfile {
+	merged := &model.InputProfile{}
+	if current != nil {
+		merged = &model.InputProfile{
+			BirthDate:    current.BirthDate,
+			Height:       current.Height,
+			Weight:       current.Weight,
+			College:      current.College,
+			Country:      current.Country,
+			DraftYear:    current.DraftYear,
+			DraftRound:   current.DraftRound,
+			DraftPick:    current.DraftPick,
+			DebutSeason:  current.DebutSeason,
+			JerseyNumber: current.JerseyNumber,
+			Handedness:   current.Handedness,
+		}
+	}
+
+	for _, field := range clear {
+		switch field {
+		case model.ProfileFieldBirthDate:
+			merged.BirthDate = nil
+		case model.ProfileFieldHeight:
+			merged.Height = nil
+		case model.ProfileFieldWeight:
+			merged.Weight = nil
+		case model.ProfileFieldCollege:
+			merged.College = nil
+		case model.ProfileFieldCountry:
+			merged.Country = nil
+		case model.ProfileFieldDraftYear:
+			merged.DraftYear = nil
+		case model.ProfileFieldDraftRound:
+			merged.DraftRound = nil
+		case model.ProfileFieldDraftPick:
+			merged.DraftPick = nil
+		case model.ProfileFieldDebutSeason:
+			merged.DebutSeason = nil
+		case model.ProfileFieldJerseyNumber:
+			merged.JerseyNumber = nil
+		case model.ProfileFieldHandedness:
+			merged.Handedness = nil
+		}
+	}
+
+	if update == nil {
+		return merged
+	}
+
+	if update.BirthDate != nil {
+		merged.BirthDate = update.BirthDate
+	}
+	if update.Height != nil {
+		merged.Height = update.Height
+	}
+	if update.Weight != nil {
+		merged.Weight = update.Weight
+	}
+	if update.College != nil {
+		merged.College = update.College
+	}
+	if update.Country != nil {
+		merged.Country = update.Country
+	}
+	if update.DraftYear != nil {
+		merged.DraftYear = update.DraftYear
+	}
+	if update.DraftRound != nil {
+		merged.DraftRound = update.DraftRound
+	}
+	if update.DraftPick != nil {
+		merged.DraftPick = update.DraftPick
+	}
+	if update.DebutSeason != nil {
+		merged.DebutSeason = update.DebutSeason
+	}
+	if update.JerseyNumber != nil {
+		merged.JerseyNumber = update.JerseyNumber
+	}
+	if update.Handedness != nil {
+		merged.Handedness = update.Handedness
+	}
+
+	return merged
+}
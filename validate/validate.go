@@ -0,0 +1,302 @@
+// Package validate checks player input before it is written. Every check runs
+// so a caller learns about all the problems with an input at once.
+package validate
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattmazer1/graphql-api/graph/model"
+)
+
+// FieldError is a problem with one field. Path is relative to the input, for
+// example "stats.mp".
+type FieldError struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Error lists every field of an input that failed validation.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	messages := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		messages[i] = field.Path + " " + field.Message
+	}
+	return "invalid input: " + strings.Join(messages, ", ")
+}
+
+// Prefix returns the field errors with prefix added to their paths, for
+// inputs nested in an argument.
+func (e *Error) Prefix(prefix string) []FieldError {
+	fields := make([]FieldError, len(e.Fields))
+	for i, field := range e.Fields {
+		fields[i] = FieldError{Path: prefix + "." + field.Path, Message: field.Message}
+	}
+	return fields
+}
+
+type checker struct {
+	fields []FieldError
+}
+
+func (c *checker) fail(path string, format string, args ...interface{}) {
+	c.fields = append(c.fields, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (c *checker) intRange(path string, value int, min int, max int) {
+	if value < min || value > max {
+		c.fail(path, "must be between %d and %d", min, max)
+	}
+}
+
+func (c *checker) floatRange(path string, value float64, min float64, max float64) {
+	if math.IsNaN(value) || value < min || value > max {
+		c.fail(path, "must be between %g and %g", min, max)
+	}
+}
+
+func (c *checker) err() error {
+	if len(c.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: c.fields}
+}
+
+// Stats are per game averages, so the limits are well past any real season
+// but rule out typos such as an extra digit.
+const (
+	minAge        = 15
+	maxAge        = 60
+	maxExperience = 30
+	maxMinutes    = 48
+	maxGames      = 100
+	maxNameLength = 100
+	firstSeason   = 1946
+)
+
+var seasonFormat = regexp.MustCompile(`^(\d{4})-(\d{2})$`)
+
+// Season checks a season is written like "2023-24", with the second year
+// following the first.
+func Season(season string) string {
+	match := seasonFormat.FindStringSubmatch(season)
+	if match == nil {
+		return `must look like "2023-24"`
+	}
+
+	start, _ := strconv.Atoi(match[1])
+	end, _ := strconv.Atoi(match[2])
+	if end != (start+1)%100 {
+		return fmt.Sprintf("must be followed by %02d", (start+1)%100)
+	}
+	if start < firstSeason || start > time.Now().Year() {
+		return fmt.Sprintf("must start between %d and %d", firstSeason, time.Now().Year())
+	}
+
+	return ""
+}
+
+// Player checks a player to be written.
+func Player(player model.InputPlayer) error {
+	c := &checker{}
+
+	name := strings.TrimSpace(player.Name)
+	if name == "" {
+		c.fail("name", "is required")
+	} else if len(name) > maxNameLength {
+		c.fail("name", "must be at most %d characters", maxNameLength)
+	}
+
+	checkPositions(c, player)
+
+	profile := player.Profile
+	if profile == nil {
+		profile = &model.InputProfile{}
+	}
+	checkProfile(c, profile)
+
+	// The stored age and experience are only needed for players whose profile
+	// does not say
+	if player.Age != nil {
+		c.intRange("age", *player.Age, minAge, maxAge)
+	} else if profile.BirthDate == nil {
+		c.fail("age", "is required unless profile.birthDate is set")
+	}
+	if player.Experience != nil {
+		c.intRange("experience", *player.Experience, 0, maxExperience)
+	} else if profile.DraftYear == nil && profile.DebutSeason == nil {
+		c.fail("experience", "is required unless profile.draftYear or profile.debutSeason is set")
+	}
+	if player.Age != nil && player.Experience != nil && *player.Experience > *player.Age-minAge {
+		c.fail("experience", "cannot be more than age minus %d", minAge)
+	}
+
+	if player.Stats == nil {
+		c.fail("stats", "is required")
+		return c.err()
+	}
+
+	stats := player.Stats
+	if message := Season(stats.Season); message != "" {
+		c.fail("stats.season", message)
+	}
+	// Lines written without a season type are for the regular season
+	if stats.SeasonType != "" && !stats.SeasonType.IsValid() {
+		c.fail("stats.seasonType", "is not a valid season type")
+	}
+
+	c.floatRange("stats.mp", stats.Mp, 0, maxMinutes)
+	c.floatRange("stats.points", stats.Points, 0, 100)
+	c.floatRange("stats.threePt", stats.ThreePt, 0, 30)
+	c.floatRange("stats.rebounds", stats.Rebounds, 0, 50)
+	c.floatRange("stats.assists", stats.Assists, 0, 40)
+	c.floatRange("stats.steals", stats.Steals, 0, 15)
+	c.floatRange("stats.blocks", stats.Blocks, 0, 15)
+	c.floatRange("stats.turnOvers", stats.TurnOvers, 0, 15)
+	if stats.GamesPlayed != nil {
+		c.intRange("stats.gamesPlayed", *stats.GamesPlayed, 0, maxGames)
+	}
+
+	// Checks across fields
+	if stats.ThreePt*3 > stats.Points {
+		c.fail("stats.threePt", "cannot be worth more than points")
+	}
+	if stats.Blocks > stats.Mp {
+		c.fail("stats.blocks", "cannot exceed minutes played")
+	}
+	if stats.Steals > stats.Mp {
+		c.fail("stats.steals", "cannot exceed minutes played")
+	}
+	if stats.TurnOvers > stats.Mp {
+		c.fail("stats.turnOvers", "cannot exceed minutes played")
+	}
+	if stats.Mp == 0 && (stats.Points > 0 || stats.Rebounds > 0 || stats.Assists > 0) {
+		c.fail("stats.mp", "cannot be 0 for a player with points, rebounds or assists")
+	}
+	if stats.GamesPlayed != nil && *stats.GamesPlayed == 0 && stats.Mp > 0 {
+		c.fail("stats.gamesPlayed", "cannot be 0 for a player with minutes played")
+	}
+
+	return c.err()
+}
+
+// MergeUpdate applies the fields an update sets to the player it updates, so
+// the result can be checked as a whole.
+func MergeUpdate(current *model.Player, update model.InputUpdatePlayer) model.InputPlayer {
+	merged := model.InputPlayer{
+		Name:    current.Name,
+		Profile: mergeProfile(current.Profile, update.Profile, update.ClearProfile),
+		Stats: &model.InputStats{
+			Season:      current.Stats.Season,
+			SeasonType:  current.Stats.SeasonType,
+			Points:      current.Stats.Points,
+			ThreePt:     current.Stats.ThreePt,
+			Rebounds:    current.Stats.Rebounds,
+			Assists:     current.Stats.Assists,
+			Steals:      current.Stats.Steals,
+			Blocks:      current.Stats.Blocks,
+			TurnOvers:   current.Stats.TurnOvers,
+			Mp:          current.Stats.Mp,
+			GamesPlayed: current.Stats.GamesPlayed,
+		},
+	}
+
+	mergePositions(current, update, &merged)
+	if update.Name != nil {
+		merged.Name = *update.Name
+	}
+
+	// A stored age or experience is carried over for players whose profile
+	// does not replace it, where it is not stored
+	if update.Age != nil {
+		merged.Age = update.Age
+	} else if merged.Profile.BirthDate == nil {
+		age := current.Age
+		merged.Age = &age
+	}
+	if update.Experience != nil {
+		merged.Experience = update.Experience
+	} else if merged.Profile.DraftYear == nil && merged.Profile.DebutSeason == nil {
+		experience := current.Experience
+		merged.Experience = &experience
+	}
+
+	if stats := update.Stats; stats != nil {
+		if stats.Season != nil {
+			merged.Stats.Season = *stats.Season
+		}
+		if stats.SeasonType != nil {
+			merged.Stats.SeasonType = *stats.SeasonType
+		}
+		if stats.Points != nil {
+			merged.Stats.Points = *stats.Points
+		}
+		if stats.ThreePt != nil {
+			merged.Stats.ThreePt = *stats.ThreePt
+		}
+		if stats.Rebounds != nil {
+			merged.Stats.Rebounds = *stats.Rebounds
+		}
+		if stats.Assists != nil {
+			merged.Stats.Assists = *stats.Assists
+		}
+		if stats.Steals != nil {
+			merged.Stats.Steals = *stats.Steals
+		}
+		if stats.Blocks != nil {
+			merged.Stats.Blocks = *stats.Blocks
+		}
+		if stats.TurnOvers != nil {
+			merged.Stats.TurnOvers = *stats.TurnOvers
+		}
+		if stats.Mp != nil {
+			merged.Stats.Mp = *stats.Mp
+		}
+		if stats.GamesPlayed != nil {
+			merged.Stats.GamesPlayed = stats.GamesPlayed
+		}
+	}
+
+	return merged
+}
+
+// Overwrite returns the update that makes an existing player match an input,
+// for upserts. Like for a new player, the eligible positions default to the
+// primary position.
+func Overwrite(player model.InputPlayer) model.InputUpdatePlayer {
+	eligible := player.EligiblePositions
+	if eligible == nil && player.PrimaryPosition != nil {
+		eligible = []model.LineupPosition{*player.PrimaryPosition}
+	}
+
+	return model.InputUpdatePlayer{
+		Pos:               player.Pos,
+		PrimaryPosition:   player.PrimaryPosition,
+		EligiblePositions: eligible,
+		Name:              &player.Name,
+		Age:               player.Age,
+		Experience:        player.Experience,
+		Profile:           player.Profile,
+		Stats: &model.InputUpdateStats{
+			Season:      &player.Stats.Season,
+			SeasonType:  &player.Stats.SeasonType,
+			Points:      &player.Stats.Points,
+			ThreePt:     &player.Stats.ThreePt,
+			Rebounds:    &player.Stats.Rebounds,
+			Assists:     &player.Stats.Assists,
+			Steals:      &player.Stats.Steals,
+			Blocks:      &player.Stats.Blocks,
+			TurnOvers:   &player.Stats.TurnOvers,
+			Mp:          &player.Stats.Mp,
+			GamesPlayed: player.Stats.GamesPlayed,
+		},
+	}
+}